@@ -18,6 +18,7 @@ DISCORD_TOKEN = os.getenv('DISCORD_TOKEN')
 SOCKET_PATH = os.getenv('SOCKET_PATH', '/tmp/intrasudo25.sock')
 BOT_AUTH_TOKEN = os.getenv('DISCORD_BOT_TOKEN')
 BOT_SOCKET_PATH = os.getenv('BOT_SOCKET_PATH', '/tmp/discord_bot.sock')
+ANNOUNCEMENT_CHANNEL = os.getenv('ANNOUNCEMENT_CHANNEL', 'announcements')
 
 intents = discord.Intents.default()
 intents.message_content = True
@@ -83,9 +84,32 @@ async def refresh_channels(request):
         print(f'Error refreshing channels: {e}')
         return JSONResponse({'error': 'Internal server error'}, status_code=500)
 
+async def announce(request):
+    try:
+        body = await request.json()
+        heading = body.get('heading')
+
+        if not heading:
+            return JSONResponse({'error': 'Missing required fields'}, status_code=400)
+
+        channels = [c for guild in bot.guilds for c in guild.text_channels if c.name == ANNOUNCEMENT_CHANNEL]
+        if not channels:
+            return JSONResponse({'error': f'No #{ANNOUNCEMENT_CHANNEL} channel found'}, status_code=404)
+
+        try:
+            for channel in channels:
+                await channel.send(heading)
+            return JSONResponse({'success': True, 'message': 'Announcement sent to Discord'})
+        except Exception as e:
+            return JSONResponse({'error': 'Failed to send to Discord'}, status_code=500)
+
+    except Exception as e:
+        return JSONResponse({'error': 'Internal server error'}, status_code=500)
+
 app = Starlette(routes=[
     Route('/discord/forward', forward_message, methods=['POST']),
     Route('/discord/refresh', refresh_channels, methods=['POST']),
+    Route('/discord/announce', announce, methods=['POST']),
 ])
 
 def get_unix_connector():
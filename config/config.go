@@ -46,6 +46,10 @@ func GetXSecretValue() string {
 	return os.Getenv("X_SECRET_VALUE")
 }
 
+func GetAttachmentSecret() string {
+	return os.Getenv("ATTACHMENT_SECRET")
+}
+
 func GetDiscordBotToken() string {
 	return os.Getenv("DISCORD_BOT_TOKEN")
 }
@@ -62,6 +66,24 @@ func GetDiscordBotURL() string {
 	return url
 }
 
+// GetTrustedProxies lists the proxies whose X-Forwarded-For header is
+// believed, as IPs or CIDRs. "unix" trusts whatever reaches the server's
+// unix socket, which is how the load balancer connects, and is the default.
+func GetTrustedProxies() []string {
+	proxiesStr := os.Getenv("TRUSTED_PROXIES")
+	if proxiesStr == "" {
+		return []string{"unix"}
+	}
+
+	proxies := []string{}
+	for _, proxy := range strings.Split(proxiesStr, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func IsCountdownEnabled() bool {
 	enableStr := os.Getenv("ENABLE_COUNTDOWN")
 	if enableStr == "" {
@@ -117,3 +139,11 @@ func GetCompetitionEndTime() time.Time {
 
 	return endTime
 }
+
+func GetEventName() string {
+	name := os.Getenv("EVENT_NAME")
+	if name == "" {
+		return "Intra Sudo v6.0"
+	}
+	return name
+}
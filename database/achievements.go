@@ -0,0 +1,672 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"intrasudo25/config"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Progress events badge rules are evaluated on.
+const (
+	ProgressLevelSolved = "level_solved"
+	ProgressHiddenPage  = "hidden_page"
+)
+
+// Badge rule kinds admins can pick from. Manual badges are only ever
+// awarded by an admin.
+const (
+	RuleFirstSolve   = "first_solve"
+	RuleFastSolve    = "fast_solve"
+	RuleNightOwl     = "night_owl"
+	RuleNoHints      = "no_hints"
+	RuleHiddenPage   = "hidden_page"
+	RuleLevelsSolved = "levels_solved"
+	RuleManual       = "manual"
+)
+
+// ProgressEvent is something a player did that may earn a badge.
+type ProgressEvent struct {
+	Kind        string
+	UserEmail   string
+	LevelNumber int
+	Path        string
+	At          time.Time
+}
+
+// badgeRule decides whether an event earns a badge. Event names the
+// progress event the rule listens to.
+type badgeRule interface {
+	Event() string
+	Matches(ev ProgressEvent) (bool, error)
+}
+
+var badgeRuleKinds = kindRegistry[badgeRule]{
+	noun: "rule",
+	factories: map[string]func(params json.RawMessage) (badgeRule, error){
+		RuleFirstSolve:   newFirstSolveRule,
+		RuleFastSolve:    newFastSolveRule,
+		RuleNightOwl:     newNightOwlRule,
+		RuleNoHints:      newNoHintsRule,
+		RuleHiddenPage:   newHiddenPageRule,
+		RuleLevelsSolved: newLevelsSolvedRule,
+		RuleManual:       newManualRule,
+	},
+}
+
+// BadgeRuleKinds lists the available rule kinds in a stable order.
+func BadgeRuleKinds() []string {
+	return badgeRuleKinds.kinds()
+}
+
+// Badge is an achievement defined by an admin. Inactive badges are no
+// longer awarded but stay on the profiles of players who hold them.
+type Badge struct {
+	ID                int             `json:"id"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	Icon              string          `json:"icon"`
+	Rule              string          `json:"rule"`
+	Params            json.RawMessage `json:"params,omitempty"`
+	ShowOnLeaderboard bool            `json:"showOnLeaderboard"`
+	Active            bool            `json:"active"`
+	Holders           int             `json:"holders"`
+	CreatedBy         string          `json:"createdBy"`
+	CreatedAt         time.Time       `json:"createdAt"`
+}
+
+// PlayerBadge is a badge as shown on a player's profile.
+type PlayerBadge struct {
+	BadgeID     int       `json:"badgeId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+	LevelNumber *int      `json:"levelNumber,omitempty"`
+	AwardedAt   time.Time `json:"awardedAt"`
+}
+
+// BadgeHolder is a player holding a badge, for the admin view.
+type BadgeHolder struct {
+	UserEmail   string    `json:"userEmail"`
+	LevelNumber *int      `json:"levelNumber,omitempty"`
+	AwardedBy   string    `json:"awardedBy,omitempty"`
+	AwardedAt   time.Time `json:"awardedAt"`
+}
+
+func (b *Badge) rule() (badgeRule, error) {
+	return badgeRuleKinds.build(b.Rule, b.Params)
+}
+
+func validateBadge(b *Badge) error {
+	b.Name = strings.TrimSpace(b.Name)
+	b.Description = strings.TrimSpace(b.Description)
+	b.Icon = strings.TrimSpace(b.Icon)
+	if b.Name == "" || len(b.Name) > 64 {
+		return fmt.Errorf("name must be 1-64 characters")
+	}
+	if len(b.Description) > 280 {
+		return fmt.Errorf("description must be at most 280 characters")
+	}
+	if len([]rune(b.Icon)) > 8 {
+		return fmt.Errorf("icon must be at most 8 characters")
+	}
+	if string(b.Params) == "null" {
+		b.Params = nil
+	}
+	_, err := b.rule()
+	return err
+}
+
+func CreateBadge(b Badge) (*Badge, error) {
+	if err := validateBadge(&b); err != nil {
+		return nil, err
+	}
+
+	var params interface{}
+	if len(b.Params) > 0 {
+		params = string(b.Params)
+	}
+	result, err := db.Exec(`INSERT INTO badges (name, description, icon, rule, params, show_on_leaderboard, active, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.Name, b.Description, b.Icon, b.Rule, params, b.ShowOnLeaderboard, b.Active, b.CreatedBy, time.Now().UTC())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("a badge named %s already exists", b.Name)
+		}
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetBadge(int(id))
+}
+
+// UpdateBadge changes a badge's definition. Badges already awarded are kept
+// even if the new rule would not have awarded them.
+func UpdateBadge(b Badge) (*Badge, error) {
+	if err := validateBadge(&b); err != nil {
+		return nil, err
+	}
+
+	var params interface{}
+	if len(b.Params) > 0 {
+		params = string(b.Params)
+	}
+	result, err := db.Exec("UPDATE badges SET name = ?, description = ?, icon = ?, rule = ?, params = ?, show_on_leaderboard = ?, active = ? WHERE id = ?",
+		b.Name, b.Description, b.Icon, b.Rule, params, b.ShowOnLeaderboard, b.Active, b.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("a badge named %s already exists", b.Name)
+		}
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	// Name, icon or leaderboard visibility may have changed
+	leaderboardBadgesCache.invalidate()
+	return GetBadge(b.ID)
+}
+
+// DeleteBadge removes a badge and takes it away from everyone holding it.
+func DeleteBadge(id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM badges WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM player_badges WHERE badge_id = ?", id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	leaderboardBadgesCache.invalidate()
+	return nil
+}
+
+const badgeColumns = `b.id, b.name, b.description, b.icon, b.rule, b.params, b.show_on_leaderboard, b.active,
+	(SELECT COUNT(*) FROM player_badges pb WHERE pb.badge_id = b.id), b.created_by, b.created_at`
+
+func scanBadge(row rowScanner) (*Badge, error) {
+	var b Badge
+	var params sql.NullString
+	err := row.Scan(&b.ID, &b.Name, &b.Description, &b.Icon, &b.Rule, &params, &b.ShowOnLeaderboard, &b.Active, &b.Holders, &b.CreatedBy, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if params.String != "" {
+		b.Params = json.RawMessage(params.String)
+	}
+	return &b, nil
+}
+
+func GetBadge(id int) (*Badge, error) {
+	return scanBadge(db.QueryRow("SELECT "+badgeColumns+" FROM badges b WHERE b.id = ?", id))
+}
+
+func GetBadges() ([]Badge, error) {
+	rows, err := db.Query("SELECT " + badgeColumns + " FROM badges b ORDER BY b.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	badges := []Badge{}
+	for rows.Next() {
+		b, err := scanBadge(rows)
+		if err != nil {
+			return nil, err
+		}
+		badges = append(badges, *b)
+	}
+	return badges, nil
+}
+
+// AwardBadge gives a player a badge. It reports false when the player
+// already held it, and sql.ErrNoRows when the badge or player is unknown.
+func AwardBadge(badgeID int, userEmail string, levelNumber int, awardedBy string) (bool, error) {
+	var name, icon string
+	err := db.QueryRow("SELECT name, icon FROM badges WHERE id = ?", badgeID).Scan(&name, &icon)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM logins WHERE gmail = ?)", userEmail).Scan(&exists); err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, sql.ErrNoRows
+	}
+
+	var level interface{}
+	if levelNumber > 0 {
+		level = levelNumber
+	}
+	result, err := db.Exec("INSERT OR IGNORE INTO player_badges (user_email, badge_id, level_number, awarded_by, awarded_at) VALUES (?, ?, ?, ?, ?)",
+		userEmail, badgeID, level, awardedBy, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	leaderboardBadgesCache.invalidate()
+
+	label := name
+	if icon != "" {
+		label = icon + " " + name
+	}
+	Create("notification", map[string]interface{}{
+		"userEmail": userEmail,
+		"message":   fmt.Sprintf("You earned the %s badge", label),
+		"type":      "success",
+	})
+	return true, nil
+}
+
+func RevokeBadge(badgeID int, userEmail string) error {
+	result, err := db.Exec("DELETE FROM player_badges WHERE badge_id = ? AND user_email = ?", badgeID, userEmail)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	leaderboardBadgesCache.invalidate()
+	return nil
+}
+
+func GetBadgeHolders(badgeID int) ([]BadgeHolder, error) {
+	rows, err := db.Query("SELECT user_email, level_number, awarded_by, awarded_at FROM player_badges WHERE badge_id = ? ORDER BY awarded_at ASC", badgeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := []BadgeHolder{}
+	for rows.Next() {
+		var h BadgeHolder
+		var level sql.NullInt64
+		if err := rows.Scan(&h.UserEmail, &level, &h.AwardedBy, &h.AwardedAt); err != nil {
+			return nil, err
+		}
+		if level.Valid {
+			n := int(level.Int64)
+			h.LevelNumber = &n
+		}
+		holders = append(holders, h)
+	}
+	return holders, nil
+}
+
+// GetPlayerBadges lists the badges a player holds, oldest first.
+func GetPlayerBadges(userEmail string) ([]PlayerBadge, error) {
+	rows, err := db.Query(`SELECT b.id, b.name, b.description, b.icon, pb.level_number, pb.awarded_at
+		FROM player_badges pb JOIN badges b ON b.id = pb.badge_id
+		WHERE pb.user_email = ? ORDER BY pb.awarded_at ASC`, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPlayerBadges(rows, nil)
+}
+
+// GetLeaderboardBadges returns, per player, the badges marked to show on
+// the leaderboard. Unless live, badges earned after a leaderboard freeze
+// are hidden until the reveal. It is served from memory; the result is
+// shared and must not be modified.
+func GetLeaderboardBadges(live bool) (map[string][]PlayerBadge, error) {
+	byPlayer, err := leaderboardBadgesCache.get(loadLeaderboardBadges)
+	if err != nil || live {
+		return byPlayer, err
+	}
+	freeze, err := GetLeaderboardFreeze()
+	if err != nil || !freeze.Frozen {
+		return byPlayer, nil
+	}
+
+	frozen := map[string][]PlayerBadge{}
+	for email, badges := range byPlayer {
+		// Badges are held oldest first
+		n := sort.Search(len(badges), func(i int) bool { return badges[i].AwardedAt.After(*freeze.FrozenAt) })
+		if n > 0 {
+			frozen[email] = badges[:n:n]
+		}
+	}
+	return frozen, nil
+}
+
+func loadLeaderboardBadges() (map[string][]PlayerBadge, error) {
+	rows, err := db.Query(`SELECT b.id, b.name, b.description, b.icon, pb.level_number, pb.awarded_at, pb.user_email
+		FROM player_badges pb JOIN badges b ON b.id = pb.badge_id
+		WHERE b.show_on_leaderboard = TRUE ORDER BY pb.awarded_at ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byPlayer := map[string][]PlayerBadge{}
+	if _, err := scanPlayerBadges(rows, byPlayer); err != nil {
+		return nil, err
+	}
+	return byPlayer, nil
+}
+
+// scanPlayerBadges reads badge rows. When byPlayer is given the rows carry
+// a trailing user email and are grouped into it.
+func scanPlayerBadges(rows *sql.Rows, byPlayer map[string][]PlayerBadge) ([]PlayerBadge, error) {
+	badges := []PlayerBadge{}
+	for rows.Next() {
+		var pb PlayerBadge
+		var level sql.NullInt64
+		dest := []interface{}{&pb.BadgeID, &pb.Name, &pb.Description, &pb.Icon, &level, &pb.AwardedAt}
+		var email string
+		if byPlayer != nil {
+			dest = append(dest, &email)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if level.Valid {
+			n := int(level.Int64)
+			pb.LevelNumber = &n
+		}
+		if byPlayer != nil {
+			byPlayer[email] = append(byPlayer[email], pb)
+		} else {
+			badges = append(badges, pb)
+		}
+	}
+	return badges, nil
+}
+
+// RecordProgress evaluates every active badge listening to an event and
+// awards the ones it earns. Failures are logged, never returned, so badges
+// can not get in the way of progression.
+func RecordProgress(ev ProgressEvent) {
+	if ev.At.IsZero() {
+		ev.At = time.Now().UTC()
+	}
+
+	rows, err := db.Query(`SELECT `+badgeColumns+` FROM badges b
+		WHERE b.active = TRUE AND b.id NOT IN (SELECT badge_id FROM player_badges WHERE user_email = ?)`, ev.UserEmail)
+	if err != nil {
+		log.Printf("ERROR: Failed to load badges for %s: %v", ev.UserEmail, err)
+		return
+	}
+	var candidates []Badge
+	for rows.Next() {
+		b, err := scanBadge(rows)
+		if err != nil {
+			log.Printf("ERROR: Failed to read badge: %v", err)
+			continue
+		}
+		candidates = append(candidates, *b)
+	}
+	rows.Close()
+
+	for _, b := range candidates {
+		rule, err := b.rule()
+		if err != nil {
+			log.Printf("WARNING: Badge %d has an invalid rule: %v", b.ID, err)
+			continue
+		}
+		if rule.Event() != ev.Kind {
+			continue
+		}
+		earned, err := rule.Matches(ev)
+		if err != nil {
+			log.Printf("ERROR: Failed to evaluate badge %d for %s: %v", b.ID, ev.UserEmail, err)
+			continue
+		}
+		if !earned {
+			continue
+		}
+		if _, err := AwardBadge(b.ID, ev.UserEmail, ev.LevelNumber, ""); err != nil {
+			log.Printf("ERROR: Failed to award badge %d to %s: %v", b.ID, ev.UserEmail, err)
+			continue
+		}
+		log.Printf("INFO: Awarded badge %q to %s", b.Name, ev.UserEmail)
+	}
+}
+
+// levelScope restricts a rule to one level; zero means any level.
+type levelScope struct {
+	Level int `json:"level"`
+}
+
+func (s levelScope) covers(levelNumber int) bool {
+	return s.Level == 0 || s.Level == levelNumber
+}
+
+// firstSolveRule is earned by taking first blood on a level.
+type firstSolveRule struct {
+	levelScope
+}
+
+func newFirstSolveRule(params json.RawMessage) (badgeRule, error) {
+	r := &firstSolveRule{}
+	return r, decodeSettings(params, r, "rule params")
+}
+
+func (r *firstSolveRule) Event() string { return ProgressLevelSolved }
+
+func (r *firstSolveRule) Matches(ev ProgressEvent) (bool, error) {
+	if !r.covers(ev.LevelNumber) {
+		return false, nil
+	}
+	var first string
+	err := db.QueryRow("SELECT user_email FROM first_solves WHERE level_number = ? AND position = 1", ev.LevelNumber).Scan(&first)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return first == ev.UserEmail, nil
+}
+
+// fastSolveRule is earned by solving a level within a number of minutes of
+// its release: its publish time, or the competition start for levels that
+// were never scheduled.
+type fastSolveRule struct {
+	levelScope
+	Minutes int `json:"minutes"`
+}
+
+func newFastSolveRule(params json.RawMessage) (badgeRule, error) {
+	r := &fastSolveRule{}
+	if err := decodeSettings(params, r, "rule params"); err != nil {
+		return nil, err
+	}
+	if r.Minutes <= 0 {
+		return nil, fmt.Errorf("fast_solve needs a positive number of minutes")
+	}
+	return r, nil
+}
+
+func (r *fastSolveRule) Event() string { return ProgressLevelSolved }
+
+func (r *fastSolveRule) Matches(ev ProgressEvent) (bool, error) {
+	if !r.covers(ev.LevelNumber) {
+		return false, nil
+	}
+	schedule, err := GetLevelSchedule(ev.LevelNumber)
+	if err != nil {
+		return false, err
+	}
+	// A level is released when its event starts, or the competition for
+	// levels outside any event, unless it is published later
+	release := config.GetCompetitionStartTime()
+	if eventID := EventIDForLevel(ev.LevelNumber); eventID > 0 {
+		event, err := GetEvent(eventID)
+		if err != nil {
+			return false, err
+		}
+		release = event.StartsAt
+	}
+	if schedule.PublishAt != nil && schedule.PublishAt.After(release) {
+		release = *schedule.PublishAt
+	}
+	return ev.At.Sub(release) <= time.Duration(r.Minutes)*time.Minute, nil
+}
+
+// nightOwlRule is earned by solving between two hours of the day in the
+// given time zone. The window wraps past midnight when From is after To.
+type nightOwlRule struct {
+	levelScope
+	From     int    `json:"fromHour"`
+	To       int    `json:"toHour"`
+	Timezone string `json:"timezone"`
+	location *time.Location
+}
+
+func newNightOwlRule(params json.RawMessage) (badgeRule, error) {
+	r := &nightOwlRule{From: 0, To: 5, Timezone: "Asia/Kolkata"}
+	if err := decodeSettings(params, r, "rule params"); err != nil {
+		return nil, err
+	}
+	if r.From < 0 || r.From > 23 || r.To < 0 || r.To > 24 || r.From == r.To {
+		return nil, fmt.Errorf("night_owl needs distinct hours, fromHour 0-23 and toHour 0-24")
+	}
+	location, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	r.location = location
+	return r, nil
+}
+
+func (r *nightOwlRule) Event() string { return ProgressLevelSolved }
+
+func (r *nightOwlRule) Matches(ev ProgressEvent) (bool, error) {
+	if !r.covers(ev.LevelNumber) {
+		return false, nil
+	}
+	hour := ev.At.In(r.location).Hour()
+	if r.From < r.To {
+		return hour >= r.From && hour < r.To, nil
+	}
+	return hour >= r.From || hour < r.To, nil
+}
+
+// noHintsRule is earned by solving a level before any hint for it reached
+// the player, whether relayed from Discord or released on a schedule.
+type noHintsRule struct {
+	levelScope
+}
+
+func newNoHintsRule(params json.RawMessage) (badgeRule, error) {
+	r := &noHintsRule{}
+	return r, decodeSettings(params, r, "rule params")
+}
+
+func (r *noHintsRule) Event() string { return ProgressLevelSolved }
+
+func (r *noHintsRule) Matches(ev ProgressEvent) (bool, error) {
+	if !r.covers(ev.LevelNumber) {
+		return false, nil
+	}
+
+	var relayed int
+	err := db.QueryRow("SELECT COUNT(*) FROM hint_messages WHERE level_number = ? AND is_deleted = FALSE AND timestamp <= ?",
+		ev.LevelNumber, ev.At.UTC().Format("2006-01-02 15:04:05")).Scan(&relayed)
+	if err != nil {
+		return false, err
+	}
+	if relayed > 0 {
+		return false, nil
+	}
+
+	hints, err := GetScheduledHints(ev.LevelNumber)
+	if err != nil || len(hints) == 0 {
+		return err == nil, err
+	}
+	reachedAt, err := LevelReachedAt(ev.UserEmail, ev.LevelNumber)
+	if err != nil {
+		return false, err
+	}
+	for _, hint := range hints {
+		release := reachedAt.Add(time.Duration(hint.DelayMinutes) * time.Minute)
+		if hint.ReleaseMode == HintReleaseAt {
+			release = *hint.ReleaseAt
+		}
+		if !ev.At.Before(release) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// hiddenPageRule is earned by finding a level's virtual route, optionally
+// one at a specific path.
+type hiddenPageRule struct {
+	levelScope
+	Path string `json:"path"`
+}
+
+func newHiddenPageRule(params json.RawMessage) (badgeRule, error) {
+	r := &hiddenPageRule{}
+	if err := decodeSettings(params, r, "rule params"); err != nil {
+		return nil, err
+	}
+	if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+		return nil, fmt.Errorf("hidden_page path must start with /")
+	}
+	return r, nil
+}
+
+func (r *hiddenPageRule) Event() string { return ProgressHiddenPage }
+
+func (r *hiddenPageRule) Matches(ev ProgressEvent) (bool, error) {
+	return r.covers(ev.LevelNumber) && (r.Path == "" || r.Path == ev.Path), nil
+}
+
+// levelsSolvedRule is earned once a player has solved a number of levels.
+type levelsSolvedRule struct {
+	Count int `json:"count"`
+}
+
+func newLevelsSolvedRule(params json.RawMessage) (badgeRule, error) {
+	r := &levelsSolvedRule{}
+	if err := decodeSettings(params, r, "rule params"); err != nil {
+		return nil, err
+	}
+	if r.Count <= 0 {
+		return nil, fmt.Errorf("levels_solved needs a positive count")
+	}
+	return r, nil
+}
+
+func (r *levelsSolvedRule) Event() string { return ProgressLevelSolved }
+
+func (r *levelsSolvedRule) Matches(ev ProgressEvent) (bool, error) {
+	var solved int
+	err := db.QueryRow("SELECT COUNT(*) FROM level_completions WHERE user_email = ? AND placed IS NOT TRUE", ev.UserEmail).Scan(&solved)
+	return solved >= r.Count, err
+}
+
+// manualRule never matches; admins award these badges by hand.
+type manualRule struct{}
+
+func newManualRule(params json.RawMessage) (badgeRule, error) {
+	r := &manualRule{}
+	return r, decodeSettings(params, r, "rule params")
+}
+
+func (r *manualRule) Event() string { return "" }
+
+func (r *manualRule) Matches(ev ProgressEvent) (bool, error) { return false, nil }
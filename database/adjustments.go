@@ -0,0 +1,225 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+type ScoreAdjustment struct {
+	ID           int    `json:"id"`
+	UserEmail    string `json:"userEmail"`
+	Kind         string `json:"kind"`
+	Amount       int    `json:"amount"`
+	Reason       string `json:"reason"`
+	Actor        string `json:"actor"`
+	CreatedAt    string `json:"createdAt"`
+	Reverted     bool   `json:"reverted"`
+	RevertedBy   string `json:"revertedBy,omitempty"`
+	RevertedAt   string `json:"revertedAt,omitempty"`
+	RevertReason string `json:"revertReason,omitempty"`
+}
+
+type LevelCompletion struct {
+	LevelNumber int    `json:"levelNumber"`
+	CompletedAt string `json:"completedAt"`
+	Placed      bool   `json:"placed"`
+}
+
+type PlayerHistory struct {
+	Email       string            `json:"email"`
+	Level       int               `json:"level"`
+	Completions []LevelCompletion `json:"completions"`
+	Adjustments []ScoreAdjustment `json:"adjustments"`
+	Placements  []LevelPlacement  `json:"placements"`
+	Badges      []PlayerBadge     `json:"badges"`
+	PointsTotal int               `json:"pointsTotal"`
+	LevelsTotal int               `json:"levelsTotal"`
+}
+
+// Adjustment kinds. Points feed the leaderboard score, levels shift the
+// level a player is ranked at without moving their actual progression.
+const (
+	AdjustmentPoints = "points"
+	AdjustmentLevels = "levels"
+)
+
+func CreateScoreAdjustment(userEmail, kind string, amount int, reason, actor string) (*ScoreAdjustment, error) {
+	if kind != AdjustmentPoints && kind != AdjustmentLevels {
+		return nil, fmt.Errorf("invalid adjustment kind: %s", kind)
+	}
+	if amount == 0 {
+		return nil, fmt.Errorf("adjustment amount cannot be zero")
+	}
+
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM logins WHERE gmail = ?)", userEmail).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("user %s not found", userEmail)
+	}
+
+	result, err := db.Exec("INSERT INTO score_adjustments (user_email, kind, amount, reason, actor) VALUES (?, ?, ?, ?, ?)",
+		userEmail, kind, amount, reason, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	refreshLeaderboardPlayer(userEmail)
+
+	log.Printf("INFO: %s adjusted %s for %s by %d: %s", actor, kind, userEmail, amount, reason)
+
+	notification := map[string]interface{}{
+		"userEmail": userEmail,
+		"message":   fmt.Sprintf("An administrator adjusted your %s by %+d: %s", kind, amount, reason),
+		"type":      "info",
+	}
+	Create("notification", notification)
+
+	return GetScoreAdjustment(int(id))
+}
+
+func GetScoreAdjustment(id int) (*ScoreAdjustment, error) {
+	row := db.QueryRow(`SELECT id, user_email, kind, amount, reason, actor, created_at, reverted, reverted_by, reverted_at, revert_reason
+		FROM score_adjustments WHERE id = ?`, id)
+	return scanScoreAdjustment(row)
+}
+
+func GetScoreAdjustments(userEmail string) ([]ScoreAdjustment, error) {
+	query := `SELECT id, user_email, kind, amount, reason, actor, created_at, reverted, reverted_by, reverted_at, revert_reason
+		FROM score_adjustments`
+	args := []interface{}{}
+	if userEmail != "" {
+		query += " WHERE user_email = ?"
+		args = append(args, userEmail)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := []ScoreAdjustment{}
+	for rows.Next() {
+		adj, err := scanScoreAdjustment(rows)
+		if err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, *adj)
+	}
+	return adjustments, nil
+}
+
+// RevertScoreAdjustment marks an adjustment as reverted. Adjustments are
+// never deleted so the ledger keeps a full record of who changed what.
+func RevertScoreAdjustment(id int, actor, reason string) (*ScoreAdjustment, error) {
+	result, err := db.Exec(`UPDATE score_adjustments SET reverted = TRUE, reverted_by = ?, reverted_at = CURRENT_TIMESTAMP, revert_reason = ?
+		WHERE id = ? AND reverted = FALSE`, actor, reason, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("adjustment %d not found or already reverted", id)
+	}
+
+	adj, err := GetScoreAdjustment(id)
+	if err != nil {
+		return nil, err
+	}
+	refreshLeaderboardPlayer(adj.UserEmail)
+
+	log.Printf("INFO: %s reverted adjustment %d for %s", actor, id, adj.UserEmail)
+
+	notification := map[string]interface{}{
+		"userEmail": adj.UserEmail,
+		"message":   fmt.Sprintf("An administrator reverted a %+d %s adjustment", adj.Amount, adj.Kind),
+		"type":      "info",
+	}
+	Create("notification", notification)
+
+	return adj, nil
+}
+
+func GetPlayerHistory(userEmail string) (*PlayerHistory, error) {
+	history := &PlayerHistory{Email: userEmail}
+
+	err := db.QueryRow("SELECT \"on\" FROM logins WHERE gmail = ?", userEmail).Scan(&history.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT level_number, completed_at, COALESCE(placed, FALSE) FROM level_completions WHERE user_email = ? ORDER BY completed_at ASC", userEmail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history.Completions = []LevelCompletion{}
+	for rows.Next() {
+		var c LevelCompletion
+		if err := rows.Scan(&c.LevelNumber, &c.CompletedAt, &c.Placed); err != nil {
+			return nil, err
+		}
+		history.Completions = append(history.Completions, c)
+	}
+
+	history.Adjustments, err = GetScoreAdjustments(userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	history.Placements, err = GetLevelPlacements(userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	history.Badges, err = GetPlayerBadges(userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, adj := range history.Adjustments {
+		if adj.Reverted {
+			continue
+		}
+		if adj.Kind == AdjustmentPoints {
+			history.PointsTotal += adj.Amount
+		} else {
+			history.LevelsTotal += adj.Amount
+		}
+	}
+
+	return history, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScoreAdjustment(row rowScanner) (*ScoreAdjustment, error) {
+	var adj ScoreAdjustment
+	var reason, revertedBy, revertedAt, revertReason sql.NullString
+	err := row.Scan(&adj.ID, &adj.UserEmail, &adj.Kind, &adj.Amount, &reason, &adj.Actor, &adj.CreatedAt,
+		&adj.Reverted, &revertedBy, &revertedAt, &revertReason)
+	if err != nil {
+		return nil, err
+	}
+	adj.Reason = reason.String
+	adj.RevertedBy = revertedBy.String
+	adj.RevertedAt = revertedAt.String
+	adj.RevertReason = revertReason.String
+	return &adj, nil
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"intrasudo25/config"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type HourlySolves struct {
+	Hour   time.Time `json:"hour"`
+	Solves int       `json:"solves"`
+}
+
+// LevelAnalytics summarises how players are getting on with a level. Time
+// to solve runs from when a player could first see the level (previous
+// solve, competition start or publish time, whichever is latest) to the
+// solve itself. Admins are left out of every figure.
+type LevelAnalytics struct {
+	LevelNumber        int            `json:"levelNumber"`
+	Status             string         `json:"status"`
+	Reached            int            `json:"reached"`
+	PlayersOn          int            `json:"playersOn"`
+	Solves             int            `json:"solves"`
+	SolveRatio         float64        `json:"solveRatio"`
+	Attempts           int            `json:"attempts"`
+	AttemptsPerSolve   float64        `json:"attemptsPerSolve"`
+	MedianSolveSeconds float64        `json:"medianSolveSeconds"`
+	P90SolveSeconds    float64        `json:"p90SolveSeconds"`
+	SolvesLastHour     int            `json:"solvesLastHour"`
+	LastSolveAt        *time.Time     `json:"lastSolveAt,omitempty"`
+	HourlySolves       []HourlySolves `json:"hourlySolves"`
+}
+
+type levelCompletionRow struct {
+	email       string
+	level       int
+	completedAt time.Time
+}
+
+// GetLevelAnalytics reports on a single level, or every level when
+// levelNumber is 0.
+func GetLevelAnalytics(levelNumber int) ([]LevelAnalytics, error) {
+	admins := adminEmailSet()
+
+	schedules, err := GetLevelSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	playersOn := map[int]int{}
+	rows, err := db.Query("SELECT gmail, \"on\" FROM logins")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var email string
+		var on int
+		if err := rows.Scan(&email, &on); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !admins[strings.ToLower(email)] {
+			playersOn[on]++
+		}
+	}
+	rows.Close()
+
+	completions, solves, err := loadPlayerCompletions(admins)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := map[int]int{}
+	rows, err = db.Query(`SELECT s.user_email, s.level_number, COUNT(*) FROM submissions s
+		JOIN level_completions lc ON lc.user_email = s.user_email AND lc.level_number = s.level_number
+		WHERE lc.placed IS NOT TRUE
+		GROUP BY s.user_email, s.level_number`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var email string
+		var level, count int
+		if err := rows.Scan(&email, &level, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !admins[strings.ToLower(email)] {
+			attempts[level] += count
+		}
+	}
+	rows.Close()
+
+	levels := []int{}
+	for n := range schedules {
+		if levelNumber == 0 || n == levelNumber {
+			levels = append(levels, n)
+		}
+	}
+	sort.Ints(levels)
+
+	starts, err := loadLevelStarts()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+
+	results := []LevelAnalytics{}
+	for _, n := range levels {
+		a := LevelAnalytics{
+			LevelNumber:  n,
+			Status:       schedules[n].Status,
+			PlayersOn:    playersOn[n],
+			Attempts:     attempts[n],
+			HourlySolves: []HourlySolves{},
+		}
+
+		var durations []float64
+		hourly := map[time.Time]int{}
+		for _, c := range solves {
+			if c.level != n {
+				continue
+			}
+			a.Solves++
+
+			reached := solveStartedAt(completions, schedules, starts, c.email, n)
+			if d := c.completedAt.Sub(reached).Seconds(); d >= 0 {
+				durations = append(durations, d)
+			}
+
+			hourly[c.completedAt.UTC().Truncate(time.Hour)]++
+			if now.Sub(c.completedAt) <= time.Hour {
+				a.SolvesLastHour++
+			}
+			completedAt := c.completedAt
+			a.LastSolveAt = &completedAt
+		}
+
+		a.Reached = a.Solves + a.PlayersOn
+		if a.Reached > 0 {
+			a.SolveRatio = float64(a.Solves) / float64(a.Reached)
+		}
+		if a.Solves > 0 {
+			a.AttemptsPerSolve = float64(a.Attempts) / float64(a.Solves)
+		}
+
+		sort.Float64s(durations)
+		a.MedianSolveSeconds = percentile(durations, 0.5)
+		a.P90SolveSeconds = percentile(durations, 0.9)
+
+		for hour, count := range hourly {
+			a.HourlySolves = append(a.HourlySolves, HourlySolves{Hour: hour, Solves: count})
+		}
+		sort.Slice(a.HourlySolves, func(i, j int) bool {
+			return a.HourlySolves[i].Hour.Before(a.HourlySolves[j].Hour)
+		})
+
+		results = append(results, a)
+	}
+
+	return results, nil
+}
+
+func adminEmailSet() map[string]bool {
+	admins := map[string]bool{}
+	for _, email := range config.GetAdminEmails() {
+		admins[strings.ToLower(email)] = true
+	}
+	return admins
+}
+
+// loadPlayerCompletions returns every non-admin completion indexed by
+// player and level, and the real solves among them as a list ordered by
+// completion time. Completions filled in by a placement only mark when the
+// player could start the next level.
+func loadPlayerCompletions(admins map[string]bool) (map[string]map[int]time.Time, []levelCompletionRow, error) {
+	rows, err := db.Query("SELECT user_email, level_number, completed_at, COALESCE(placed, FALSE) FROM level_completions ORDER BY completed_at")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	completions := map[string]map[int]time.Time{}
+	var solves []levelCompletionRow
+	for rows.Next() {
+		var c levelCompletionRow
+		var placed bool
+		if err := rows.Scan(&c.email, &c.level, &c.completedAt, &placed); err != nil {
+			return nil, nil, err
+		}
+		if admins[strings.ToLower(c.email)] {
+			continue
+		}
+		if completions[c.email] == nil {
+			completions[c.email] = map[int]time.Time{}
+		}
+		completions[c.email][c.level] = c.completedAt
+		if !placed {
+			solves = append(solves, c)
+		}
+	}
+	return completions, solves, nil
+}
+
+// levelStarts knows when each block of levels opened: the owning event's
+// start for event levels, the competition start for the rest.
+type levelStarts struct {
+	competition time.Time
+	events      []Event
+}
+
+func loadLevelStarts() (levelStarts, error) {
+	events, err := GetEvents()
+	if err != nil {
+		return levelStarts{}, err
+	}
+	return levelStarts{competition: config.GetCompetitionStartTime(), events: events}, nil
+}
+
+// of returns the first level of the block holding level and when the block
+// opened.
+func (s levelStarts) of(level int) (int, time.Time) {
+	for _, e := range s.events {
+		if level >= e.FirstLevel && level <= e.LastLevel {
+			return e.FirstLevel, e.StartsAt
+		}
+	}
+	return 1, s.competition
+}
+
+// solveStartedAt is when a player could first see a level: their previous
+// solve, or the start of the level's event or the competition for the first
+// level of a block, moved to the level's publish time if that is later.
+func solveStartedAt(completions map[string]map[int]time.Time, schedules map[int]LevelSchedule, starts levelStarts, email string, level int) time.Time {
+	first, reached := starts.of(level)
+	if level > first {
+		if prev, ok := completions[email][level-1]; ok {
+			reached = prev
+		}
+	}
+	if publishAt := schedules[level].PublishAt; publishAt != nil && publishAt.After(reached) {
+		reached = *publishAt
+	}
+	return reached
+}
+
+// percentile interpolates linearly between the closest ranks of an already
+// sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Answer rules name the step of the answer pipeline that decided a verdict.
+const (
+	AnswerRuleSpaces    = "no_spaces"
+	AnswerRuleUppercase = "lowercase_only"
+	AnswerRuleExact     = "exact_match"
+	AnswerRuleDynamic   = "dynamic_match"
+	AnswerRulePart      = "part_match"
+	AnswerRuleRegex     = "regex_match"
+	AnswerRuleNumeric   = "numeric_match"
+	AnswerRuleTokens    = "token_set_match"
+	AnswerRuleExternal  = "external_match"
+	AnswerRuleChecker   = "checker_unavailable"
+	AnswerRuleNoMatch   = "no_match"
+)
+
+// AnswerVerdict is the outcome of running a submission through the answer
+// pipeline, without any of the progression side effects.
+type AnswerVerdict struct {
+	Correct    bool   `json:"correct"`
+	Rule       string `json:"rule"`
+	Message    string `json:"message"`
+	Normalized string `json:"normalized"`
+	Part       string `json:"part,omitempty"`
+}
+
+// checkAnswerFormat rejects answers players are told never to send. It
+// returns nil when the answer is well-formed.
+func checkAnswerFormat(answer string) *AnswerVerdict {
+	if strings.Contains(answer, " ") {
+		return &AnswerVerdict{
+			Rule:    AnswerRuleSpaces,
+			Message: "Answer cannot contain spaces. Please enter a valid answer without spaces.",
+		}
+	}
+
+	for _, char := range answer {
+		if char >= 'A' && char <= 'Z' {
+			return &AnswerVerdict{
+				Rule:    AnswerRuleUppercase,
+				Message: "Answer must be lowercase only. Please enter the answer in lowercase.",
+			}
+		}
+	}
+	return nil
+}
+
+// ErrCheckerUnavailable means a validator could not judge an answer, for
+// example because an external checker did not respond.
+var ErrCheckerUnavailable = errors.New("answer checker unavailable")
+
+var validatorRules = map[string]string{
+	ValidatorExact:    AnswerRuleExact,
+	ValidatorRegex:    AnswerRuleRegex,
+	ValidatorNumeric:  AnswerRuleNumeric,
+	ValidatorTokens:   AnswerRuleTokens,
+	ValidatorExternal: AnswerRuleExternal,
+}
+
+// matchAnswer runs a submission through a level's validator.
+func matchAnswer(lv *LevelValidator, sub Submission) (AnswerVerdict, error) {
+	sub.Answer = strings.TrimSpace(sub.Answer)
+	verdict := AnswerVerdict{Normalized: sub.Answer}
+
+	validator, err := lv.build()
+	if err != nil {
+		return verdict, fmt.Errorf("%w: %v", ErrCheckerUnavailable, err)
+	}
+	correct, err := validator.Validate(sub)
+	if err != nil {
+		return verdict, fmt.Errorf("%w: %v", ErrCheckerUnavailable, err)
+	}
+
+	if !correct {
+		verdict.Rule = AnswerRuleNoMatch
+		verdict.Message = "Incorrect answer. Try again!"
+		return verdict, nil
+	}
+	verdict.Correct = true
+	verdict.Rule = validatorRules[lv.Kind]
+	verdict.Message = "Correct!"
+	return verdict, nil
+}
+
+// DryRunAnswer runs a candidate answer through the same checks as
+// CheckAnswer for any level, published or not, and records nothing. Dynamic
+// levels are checked against the answer derived for asEmail.
+func DryRunAnswer(levelNumber int, answer, asEmail string) (*AnswerVerdict, error) {
+	var correctAnswer string
+	var answerMode, answerSecret sql.NullString
+	err := db.QueryRow("SELECT answer, answer_mode, answer_secret FROM levels WHERE level_number = ?", levelNumber).Scan(&correctAnswer, &answerMode, &answerSecret)
+	if err != nil {
+		return nil, err
+	}
+	correctAnswer = expectedAnswer(levelNumber, correctAnswer, answerMode, answerSecret, asEmail)
+
+	lv, err := GetLevelValidator(levelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if lv.strictFormat() {
+		if verdict := checkAnswerFormat(answer); verdict != nil {
+			verdict.Normalized = answer
+			return verdict, nil
+		}
+	}
+
+	partsConfig, err := GetLevelPartsConfig(levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	if len(partsConfig.Parts) > 0 {
+		// Parts are matched regardless of order or earlier progress
+		for _, part := range partsConfig.Parts {
+			verdict, err := matchAnswer(lv, Submission{LevelNumber: levelNumber, Part: part.Name, UserEmail: asEmail, Answer: answer, Expected: part.Answer})
+			if err != nil {
+				return checkerUnavailableVerdict(verdict, err), nil
+			}
+			if verdict.Correct {
+				verdict.Rule = AnswerRulePart
+				verdict.Message = "Correct for part " + part.Name
+				verdict.Part = part.Name
+				return &verdict, nil
+			}
+		}
+		return &AnswerVerdict{Rule: AnswerRuleNoMatch, Message: "Incorrect answer. Try again!", Normalized: strings.TrimSpace(answer)}, nil
+	}
+
+	verdict, err := matchAnswer(lv, Submission{LevelNumber: levelNumber, UserEmail: asEmail, Answer: answer, Expected: correctAnswer})
+	if err != nil {
+		return checkerUnavailableVerdict(verdict, err), nil
+	}
+	if verdict.Correct && answerMode.String == AnswerModeDynamic {
+		verdict.Rule = AnswerRuleDynamic
+	}
+	return &verdict, nil
+}
+
+// checkerUnavailableVerdict reports a validator failure to an admin instead
+// of passing it off as a wrong answer.
+func checkerUnavailableVerdict(verdict AnswerVerdict, err error) *AnswerVerdict {
+	verdict.Rule = AnswerRuleChecker
+	verdict.Message = err.Error()
+	return &verdict
+}
+
+// checkerUnavailableResult tells a player their answer could not be judged.
+// Nothing is recorded, so the attempt does not count against them.
+func checkerUnavailableResult(userEmail string, levelNumber int, err error) *SubmitAnswerResult {
+	log.Printf("ERROR: Could not check answer from %s on level %d: %v", userEmail, levelNumber, err)
+	return &SubmitAnswerResult{
+		Correct: false,
+		Message: "Your answer could not be checked right now. Please try again shortly.",
+	}
+}
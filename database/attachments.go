@@ -0,0 +1,229 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"intrasudo25/config"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const attachmentDir = "./data/attachments"
+
+// AttachmentURLTTL is how long a signed attachment link stays valid.
+const AttachmentURLTTL = 15 * time.Minute
+
+type LevelAttachment struct {
+	ID          int    `json:"id"`
+	LevelNumber int    `json:"levelNumber"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	SHA256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+	UploadedBy  string `json:"uploadedBy"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+type AttachmentLink struct {
+	ID          int    `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	URL         string `json:"url"`
+	ExpiresAt   int64  `json:"expiresAt"`
+}
+
+// SaveLevelAttachment streams the upload to disk under its SHA-256 and
+// records it against the level. Identical files share one blob on disk.
+func SaveLevelAttachment(levelNumber int, filename, contentType string, src io.Reader, uploadedBy string) (*LevelAttachment, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM levels WHERE level_number = ?)", levelNumber).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("level %d not found", levelNumber)
+	}
+
+	sum, size, err := storeBlob(attachmentDir, src)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.Exec("INSERT INTO level_attachments (level_number, filename, content_type, sha256, size, uploaded_by) VALUES (?, ?, ?, ?, ?, ?)",
+		levelNumber, filename, contentType, sum, size, uploadedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO: %s uploaded %s (%d bytes, %s) to level %d", uploadedBy, filename, size, sum, levelNumber)
+	return GetLevelAttachment(int(id))
+}
+
+// storeBlob streams src into dir under its SHA-256, keeping one copy of
+// identical content.
+func storeBlob(dir string, src io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), src)
+	closeErr := tmp.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	if closeErr != nil {
+		return "", 0, closeErr
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	blobPath := filepath.Join(dir, sum)
+	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+		if err := os.Rename(tmp.Name(), blobPath); err != nil {
+			return "", 0, err
+		}
+	}
+	return sum, size, nil
+}
+
+func GetLevelAttachment(id int) (*LevelAttachment, error) {
+	var a LevelAttachment
+	err := db.QueryRow("SELECT id, level_number, filename, content_type, sha256, size, uploaded_by, created_at FROM level_attachments WHERE id = ?", id).
+		Scan(&a.ID, &a.LevelNumber, &a.Filename, &a.ContentType, &a.SHA256, &a.Size, &a.UploadedBy, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func GetLevelAttachments(levelNumber int) ([]LevelAttachment, error) {
+	rows, err := db.Query("SELECT id, level_number, filename, content_type, sha256, size, uploaded_by, created_at FROM level_attachments WHERE level_number = ? ORDER BY id ASC", levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []LevelAttachment{}
+	for rows.Next() {
+		var a LevelAttachment
+		if err := rows.Scan(&a.ID, &a.LevelNumber, &a.Filename, &a.ContentType, &a.SHA256, &a.Size, &a.UploadedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+// DeleteLevelAttachment removes the record and drops the blob once no
+// other attachment references the same content.
+func DeleteLevelAttachment(levelNumber, id int) error {
+	attachment, err := GetLevelAttachment(id)
+	if err != nil || attachment.LevelNumber != levelNumber {
+		return fmt.Errorf("attachment %d not found on level %d", id, levelNumber)
+	}
+
+	_, err = db.Exec("DELETE FROM level_attachments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	var refs int
+	err = db.QueryRow("SELECT COUNT(*) FROM level_attachments WHERE sha256 = ?", attachment.SHA256).Scan(&refs)
+	if err == nil && refs == 0 {
+		os.Remove(AttachmentBlobPath(attachment))
+	}
+	return nil
+}
+
+func AttachmentBlobPath(a *LevelAttachment) string {
+	return filepath.Join(attachmentDir, a.SHA256)
+}
+
+// GetAttachmentLinks returns freshly signed download links for every
+// attachment on a level, bound to the requesting player.
+func GetAttachmentLinks(levelNumber int, userEmail string) ([]AttachmentLink, error) {
+	attachments, err := GetLevelAttachments(levelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(AttachmentURLTTL).Unix()
+	links := []AttachmentLink{}
+	for _, a := range attachments {
+		links = append(links, AttachmentLink{
+			ID:          a.ID,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			URL:         fmt.Sprintf("/api/attachments/%d?exp=%d&sig=%s", a.ID, expires, signAttachment(a.ID, userEmail, expires)),
+			ExpiresAt:   expires,
+		})
+	}
+	return links, nil
+}
+
+// VerifyAttachmentSignature checks that a download link was issued to this
+// player for this attachment and has not expired.
+func VerifyAttachmentSignature(id int, userEmail, exp, sig string) bool {
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	expected := signAttachment(id, userEmail, expires)
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
+
+func signAttachment(id int, userEmail string, expires int64) string {
+	mac := hmac.New(sha256.New, attachmentSigningKey())
+	mac.Write([]byte(fmt.Sprintf("%d|%s|%d", id, userEmail, expires)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// attachmentSigningKey prefers ATTACHMENT_SECRET and otherwise generates a
+// key once and keeps it in system_settings so links survive restarts.
+func attachmentSigningKey() []byte {
+	if secret := config.GetAttachmentSecret(); secret != "" {
+		return []byte(secret)
+	}
+
+	const key = "attachment_signing_key"
+	result, err := Get("system_setting", map[string]interface{}{"key": key})
+	if err == nil {
+		if setting, ok := result.(*SystemSetting); ok && setting.Value != "" {
+			return []byte(setting.Value)
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	value := hex.EncodeToString(buf)
+	Create("system_setting", map[string]interface{}{"key": key, "value": value})
+
+	// Re-read in case another request created the key first.
+	result, err = Get("system_setting", map[string]interface{}{"key": key})
+	if err == nil {
+		if setting, ok := result.(*SystemSetting); ok && setting.Value != "" {
+			return []byte(setting.Value)
+		}
+	}
+	return []byte(value)
+}
@@ -0,0 +1,471 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Suspicion signal kinds, each pointing at a different way answers leak
+// between players.
+const (
+	SignalQuickFollow       = "quick_follow"
+	SignalSharedDevice      = "shared_device"
+	SignalSharedIP          = "shared_ip"
+	SignalSharedWrongAnswer = "shared_wrong_answer"
+	SignalFastSolves        = "fast_solves"
+	SignalForeignAnswer     = "foreign_answer"
+)
+
+const (
+	SuspicionReviewDismissed = "dismissed"
+	SuspicionReviewActioned  = "actioned"
+)
+
+const (
+	// A follower has to solve within this long after the same leader on at
+	// least quickFollowMinLevels levels before it counts.
+	quickFollowWindow    = 60 * time.Second
+	quickFollowMinLevels = 2
+
+	// Addresses shared by more players than this are treated as a school
+	// or venue network rather than a shared machine.
+	sharedIPMaxPlayers = 3
+
+	// Wrong answers typed by more players than this are common mistakes.
+	unusualAnswerMaxPlayers = 3
+
+	// A solve is implausibly fast below this fraction of the level median,
+	// once the level has enough solves for the median to mean something.
+	fastSolveFraction   = 0.1
+	fastSolveMinSamples = 5
+	fastSolveMinLevels  = 2
+
+	DefaultSuspicionThreshold = 20
+)
+
+type SuspicionSignal struct {
+	Kind    string `json:"kind"`
+	Detail  string `json:"detail"`
+	Related string `json:"related,omitempty"`
+	Weight  int    `json:"weight"`
+}
+
+type SuspicionReview struct {
+	Status     string    `json:"status"`
+	Score      int       `json:"score"`
+	Note       string    `json:"note"`
+	ReviewedBy string    `json:"reviewedBy"`
+	ReviewedAt time.Time `json:"reviewedAt"`
+}
+
+type SuspicionFlag struct {
+	UserEmail string            `json:"userEmail"`
+	Score     int               `json:"score"`
+	Related   []string          `json:"related"`
+	Signals   []SuspicionSignal `json:"signals"`
+	Review    *SuspicionReview  `json:"review,omitempty"`
+}
+
+// RecordPlayerSession notes the device and address a player is playing
+// from, for spotting accounts that share a machine.
+func RecordPlayerSession(userEmail, deviceID, ip, userAgent string) error {
+	now := time.Now().UTC()
+	_, err := db.Exec(`INSERT INTO player_sessions (user_email, device_id, ip, user_agent, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_email, device_id, ip) DO UPDATE SET user_agent = excluded.user_agent, last_seen = excluded.last_seen`,
+		userEmail, deviceID, ip, userAgent, now, now)
+	return err
+}
+
+// GetSuspicionReport scores every player against the collusion signals and
+// returns those at or above minScore, highest first. Reviewed players only
+// reappear once their score grows past what was reviewed, unless
+// includeReviewed is set.
+func GetSuspicionReport(minScore int, includeReviewed bool) ([]SuspicionFlag, error) {
+	admins := adminEmailSet()
+	flags := map[string]*SuspicionFlag{}
+
+	add := func(email string, signal SuspicionSignal) {
+		flag, ok := flags[email]
+		if !ok {
+			flag = &SuspicionFlag{UserEmail: email, Related: []string{}, Signals: []SuspicionSignal{}}
+			flags[email] = flag
+		}
+		flag.Signals = append(flag.Signals, signal)
+		flag.Score += signal.Weight
+		if signal.Related != "" {
+			for _, r := range flag.Related {
+				if r == signal.Related {
+					return
+				}
+			}
+			flag.Related = append(flag.Related, signal.Related)
+		}
+	}
+
+	completions, solves, err := loadPlayerCompletions(admins)
+	if err != nil {
+		return nil, err
+	}
+	detectQuickFollows(solves, add)
+
+	if err := detectSharedSessions(admins, add); err != nil {
+		return nil, err
+	}
+	if err := detectSharedWrongAnswers(admins, add); err != nil {
+		return nil, err
+	}
+	if err := detectForeignAnswers(admins, add); err != nil {
+		return nil, err
+	}
+
+	schedules, err := GetLevelSchedules()
+	if err != nil {
+		return nil, err
+	}
+	starts, err := loadLevelStarts()
+	if err != nil {
+		return nil, err
+	}
+	detectFastSolves(completions, solves, schedules, starts, add)
+
+	reviews, err := getSuspicionReviews()
+	if err != nil {
+		return nil, err
+	}
+
+	report := []SuspicionFlag{}
+	for email, flag := range flags {
+		if flag.Score < minScore {
+			continue
+		}
+		if review, ok := reviews[email]; ok {
+			flag.Review = review
+			if !includeReviewed && flag.Score <= review.Score {
+				continue
+			}
+		}
+		sort.Slice(flag.Signals, func(i, j int) bool {
+			if flag.Signals[i].Weight != flag.Signals[j].Weight {
+				return flag.Signals[i].Weight > flag.Signals[j].Weight
+			}
+			return flag.Signals[i].Detail < flag.Signals[j].Detail
+		})
+		sort.Strings(flag.Related)
+		report = append(report, *flag)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Score != report[j].Score {
+			return report[i].Score > report[j].Score
+		}
+		return report[i].UserEmail < report[j].UserEmail
+	})
+	return report, nil
+}
+
+// GetSuspicionFlag returns the current flag for one player, reviewed or not.
+func GetSuspicionFlag(userEmail string) (*SuspicionFlag, error) {
+	report, err := GetSuspicionReport(0, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, flag := range report {
+		if strings.EqualFold(flag.UserEmail, userEmail) {
+			return &flag, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// ReviewSuspicion records an admin's decision on a flag at its current score.
+func ReviewSuspicion(userEmail, status string, score int, note, actor string) error {
+	if status != SuspicionReviewDismissed && status != SuspicionReviewActioned {
+		return fmt.Errorf("invalid review status: %s", status)
+	}
+	_, err := db.Exec(`INSERT INTO suspicion_reviews (user_email, status, score, note, reviewed_by, reviewed_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_email) DO UPDATE SET status = excluded.status, score = excluded.score, note = excluded.note,
+		reviewed_by = excluded.reviewed_by, reviewed_at = excluded.reviewed_at`,
+		userEmail, status, score, note, actor, time.Now().UTC())
+	return err
+}
+
+func getSuspicionReviews() (map[string]*SuspicionReview, error) {
+	rows, err := db.Query("SELECT user_email, status, score, note, reviewed_by, reviewed_at FROM suspicion_reviews")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := map[string]*SuspicionReview{}
+	for rows.Next() {
+		var email string
+		var note sql.NullString
+		var review SuspicionReview
+		if err := rows.Scan(&email, &review.Status, &review.Score, &note, &review.ReviewedBy, &review.ReviewedAt); err != nil {
+			return nil, err
+		}
+		review.Note = note.String
+		reviews[email] = &review
+	}
+	return reviews, nil
+}
+
+// detectQuickFollows flags pairs where one player keeps solving levels
+// moments after the other.
+func detectQuickFollows(solves []levelCompletionRow, add func(string, SuspicionSignal)) {
+	byLevel := map[int][]levelCompletionRow{}
+	for _, c := range solves {
+		byLevel[c.level] = append(byLevel[c.level], c)
+	}
+
+	type pair struct{ leader, follower string }
+	followed := map[pair][]int{}
+	for level, levelSolves := range byLevel {
+		for i, leader := range levelSolves {
+			for _, follower := range levelSolves[i+1:] {
+				if follower.completedAt.Sub(leader.completedAt) > quickFollowWindow {
+					break
+				}
+				if follower.email == leader.email {
+					continue
+				}
+				p := pair{leader.email, follower.email}
+				followed[p] = append(followed[p], level)
+			}
+		}
+	}
+
+	for p, levels := range followed {
+		if len(levels) < quickFollowMinLevels {
+			continue
+		}
+		sort.Ints(levels)
+		add(p.follower, SuspicionSignal{
+			Kind:    SignalQuickFollow,
+			Detail:  fmt.Sprintf("Solved levels %s within %s of %s", joinLevels(levels), quickFollowWindow, p.leader),
+			Related: p.leader,
+			Weight:  15 * len(levels),
+		})
+		add(p.leader, SuspicionSignal{
+			Kind:    SignalQuickFollow,
+			Detail:  fmt.Sprintf("Was followed within %s by %s on levels %s", quickFollowWindow, p.follower, joinLevels(levels)),
+			Related: p.follower,
+			Weight:  10 * len(levels),
+		})
+	}
+}
+
+// detectSharedSessions flags accounts used from the same browser, and from
+// the same address when that address is not a busy shared network.
+func detectSharedSessions(admins map[string]bool, add func(string, SuspicionSignal)) error {
+	rows, err := db.Query("SELECT user_email, device_id, ip FROM player_sessions")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	devices := map[string]map[string]bool{}
+	ips := map[string]map[string]bool{}
+	for rows.Next() {
+		var email, deviceID, ip string
+		if err := rows.Scan(&email, &deviceID, &ip); err != nil {
+			return err
+		}
+		if admins[strings.ToLower(email)] {
+			continue
+		}
+		if deviceID != "" {
+			if devices[deviceID] == nil {
+				devices[deviceID] = map[string]bool{}
+			}
+			devices[deviceID][email] = true
+		}
+		if ip != "" {
+			if ips[ip] == nil {
+				ips[ip] = map[string]bool{}
+			}
+			ips[ip][email] = true
+		}
+	}
+
+	sharedDevice := map[[2]string]bool{}
+	for _, emails := range devices {
+		forEachPair(emails, func(a, b string) {
+			if sharedDevice[[2]string{a, b}] {
+				return
+			}
+			sharedDevice[[2]string{a, b}] = true
+			sharedDevice[[2]string{b, a}] = true
+			add(a, SuspicionSignal{Kind: SignalSharedDevice, Detail: "Played from the same browser as " + b, Related: b, Weight: 40})
+			add(b, SuspicionSignal{Kind: SignalSharedDevice, Detail: "Played from the same browser as " + a, Related: a, Weight: 40})
+		})
+	}
+
+	for ip, emails := range ips {
+		if len(emails) > sharedIPMaxPlayers {
+			continue
+		}
+		forEachPair(emails, func(a, b string) {
+			if sharedDevice[[2]string{a, b}] {
+				return
+			}
+			add(a, SuspicionSignal{Kind: SignalSharedIP, Detail: fmt.Sprintf("Shared address %s with %s", ip, b), Related: b, Weight: 10})
+			add(b, SuspicionSignal{Kind: SignalSharedIP, Detail: fmt.Sprintf("Shared address %s with %s", ip, a), Related: a, Weight: 10})
+		})
+	}
+	return nil
+}
+
+// detectSharedWrongAnswers flags players who typed the same wrong answer
+// that hardly anyone else came up with.
+func detectSharedWrongAnswers(admins map[string]bool, add func(string, SuspicionSignal)) error {
+	rows, err := db.Query("SELECT DISTINCT level_number, answer, user_email FROM submissions WHERE correct = FALSE AND answer != ''")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type wrongAnswer struct {
+		level  int
+		answer string
+	}
+	players := map[wrongAnswer]map[string]bool{}
+	for rows.Next() {
+		var wa wrongAnswer
+		var email string
+		if err := rows.Scan(&wa.level, &wa.answer, &email); err != nil {
+			return err
+		}
+		if admins[strings.ToLower(email)] {
+			continue
+		}
+		if players[wa] == nil {
+			players[wa] = map[string]bool{}
+		}
+		players[wa][email] = true
+	}
+
+	for wa, emails := range players {
+		if len(emails) > unusualAnswerMaxPlayers {
+			continue
+		}
+		forEachPair(emails, func(a, b string) {
+			detail := fmt.Sprintf("Submitted the same wrong answer %q on level %d as ", wa.answer, wa.level)
+			add(a, SuspicionSignal{Kind: SignalSharedWrongAnswer, Detail: detail + b, Related: b, Weight: 15})
+			add(b, SuspicionSignal{Kind: SignalSharedWrongAnswer, Detail: detail + a, Related: a, Weight: 15})
+		})
+	}
+	return nil
+}
+
+// detectForeignAnswers flags players who submitted the dynamic answer
+// derived for someone else, and the players whose answers they used.
+func detectForeignAnswers(admins map[string]bool, add func(string, SuspicionSignal)) error {
+	answers, err := GetForeignAnswers()
+	if err != nil {
+		return err
+	}
+
+	type pair struct{ user, owner string }
+	levels := map[pair]map[int]bool{}
+	for _, fa := range answers {
+		if admins[strings.ToLower(fa.UserEmail)] {
+			continue
+		}
+		p := pair{fa.UserEmail, fa.OwnerEmail}
+		if levels[p] == nil {
+			levels[p] = map[int]bool{}
+		}
+		levels[p][fa.LevelNumber] = true
+	}
+
+	for p, set := range levels {
+		var levelList []int
+		for level := range set {
+			levelList = append(levelList, level)
+		}
+		sort.Ints(levelList)
+		add(p.user, SuspicionSignal{
+			Kind:    SignalForeignAnswer,
+			Detail:  fmt.Sprintf("Submitted the answer derived for %s on levels %s", p.owner, joinLevels(levelList)),
+			Related: p.owner,
+			Weight:  50 * len(levelList),
+		})
+		add(p.owner, SuspicionSignal{
+			Kind:    SignalForeignAnswer,
+			Detail:  fmt.Sprintf("Their answer was submitted by %s on levels %s", p.user, joinLevels(levelList)),
+			Related: p.user,
+			Weight:  25 * len(levelList),
+		})
+	}
+	return nil
+}
+
+// detectFastSolves flags players whose solves keep coming in far quicker
+// than the rest of the field manages.
+func detectFastSolves(completions map[string]map[int]time.Time, solves []levelCompletionRow, schedules map[int]LevelSchedule, starts levelStarts, add func(string, SuspicionSignal)) {
+	durations := map[int][]float64{}
+	solveTimes := map[string]map[int]float64{}
+	for _, c := range solves {
+		d := c.completedAt.Sub(solveStartedAt(completions, schedules, starts, c.email, c.level)).Seconds()
+		if d < 0 {
+			continue
+		}
+		durations[c.level] = append(durations[c.level], d)
+		if solveTimes[c.email] == nil {
+			solveTimes[c.email] = map[int]float64{}
+		}
+		solveTimes[c.email][c.level] = d
+	}
+
+	thresholds := map[int]float64{}
+	for level, ds := range durations {
+		if len(ds) < fastSolveMinSamples {
+			continue
+		}
+		sort.Float64s(ds)
+		thresholds[level] = percentile(ds, 0.5) * fastSolveFraction
+	}
+
+	for email, times := range solveTimes {
+		var fast []int
+		for level, d := range times {
+			if threshold, ok := thresholds[level]; ok && d < threshold {
+				fast = append(fast, level)
+			}
+		}
+		if len(fast) < fastSolveMinLevels {
+			continue
+		}
+		sort.Ints(fast)
+		add(email, SuspicionSignal{
+			Kind:   SignalFastSolves,
+			Detail: fmt.Sprintf("Solved levels %s in under %.0f%% of the median time", joinLevels(fast), fastSolveFraction*100),
+			Weight: 10 * len(fast),
+		})
+	}
+}
+
+func forEachPair(set map[string]bool, fn func(a, b string)) {
+	members := make([]string, 0, len(set))
+	for m := range set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	for i := range members {
+		for j := i + 1; j < len(members); j++ {
+			fn(members[i], members[j])
+		}
+	}
+}
+
+func joinLevels(levels []int) string {
+	parts := make([]string, len(levels))
+	for i, l := range levels {
+		parts[i] = fmt.Sprint(l)
+	}
+	return strings.Join(parts, ", ")
+}
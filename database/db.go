@@ -2,9 +2,11 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"intrasudo25/config"
 
@@ -40,9 +42,11 @@ type Login struct {
 }
 
 type Sucker struct {
-	Gmail string
-	Score int
-	On    uint
+	Gmail     string
+	Score     int
+	On        uint
+	Rank      int
+	ReachedAt *time.Time `json:"-"`
 }
 
 type ChatMessage struct {
@@ -124,18 +128,20 @@ func GetAdminStats() (*AdminStats, error) {
 }
 
 type AdminLevelResponse struct {
-	ID         int    `json:"id"`
-	Number     int    `json:"number"`
-	Title      string `json:"title"`
-	Question   string `json:"question"`
-	Answer     string `json:"answer"`
-	SourceHint string `json:"sourceHint"`
-	Active     bool   `json:"active"`
-	Enabled    bool   `json:"enabled"`
+	ID         int        `json:"id"`
+	Number     int        `json:"number"`
+	Title      string     `json:"title"`
+	Question   string     `json:"question"`
+	Answer     string     `json:"answer"`
+	SourceHint string     `json:"sourceHint"`
+	Active     bool       `json:"active"`
+	Enabled    bool       `json:"enabled"`
+	Status     string     `json:"status"`
+	PublishAt  *time.Time `json:"publishAt,omitempty"`
 }
 
 func GetAllLevelsForAdmin() ([]AdminLevelResponse, error) {
-	rows, err := db.Query("SELECT level_number, markdown, src_hint, console_hint, answer, active FROM levels ORDER BY level_number")
+	rows, err := db.Query("SELECT level_number, markdown, src_hint, console_hint, answer, active, status, publish_at FROM levels ORDER BY level_number")
 	if err != nil {
 		return nil, err
 	}
@@ -145,12 +151,20 @@ func GetAllLevelsForAdmin() ([]AdminLevelResponse, error) {
 	for rows.Next() {
 		var level AdminLevelResponse
 		var srcHint, consoleHint string
+		var status sql.NullString
+		var publishAt sql.NullTime
 
-		err := rows.Scan(&level.Number, &level.Question, &srcHint, &consoleHint, &level.Answer, &level.Active)
+		err := rows.Scan(&level.Number, &level.Question, &srcHint, &consoleHint, &level.Answer, &level.Active, &status, &publishAt)
 		if err != nil {
 			continue
 		}
 
+		level.Status = status.String
+		if publishAt.Valid {
+			t := publishAt.Time
+			level.PublishAt = &t
+		}
+
 		level.ID = level.Number
 		level.Title = fmt.Sprintf("Level %d", level.Number)
 		level.SourceHint = srcHint
@@ -208,27 +222,10 @@ func GetAllUsersForAdmin() ([]AdminUserResponse, error) {
 	return users, nil
 }
 
+// CreateLevelSimple creates a level whose HTML comment hint is the question
+// itself.
 func CreateLevelSimple(levelNum int, question, answer string, active bool) error {
-	fmt.Printf("Creating level: number=%d, question=%s, answer=%s, active=%t\n", levelNum, question, answer, active)
-	level := AdminLevel{
-		LevelNumber: levelNum,
-		Markdown:    question,
-		SourceHint:  question,
-		ConsoleHint: question,
-		Answer:      answer,
-		Active:      active,
-	}
-
-	err := Create("level", level)
-	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			err = Update("level", map[string]interface{}{"number": levelNum}, level)
-		}
-		if err != nil {
-			fmt.Printf("Database error in CreateLevelSimple: %v\n", err)
-		}
-	}
-	return err
+	return CreateLevelWithHint(levelNum, question, answer, question, active)
 }
 
 func CreateLevelWithHint(levelNum int, question, answer, srcHint string, active bool) error {
@@ -237,7 +234,6 @@ func CreateLevelWithHint(levelNum int, question, answer, srcHint string, active
 		LevelNumber: levelNum,
 		Markdown:    question,
 		SourceHint:  srcHint,
-		ConsoleHint: question,
 		Answer:      answer,
 		Active:      active,
 	}
@@ -245,13 +241,15 @@ func CreateLevelWithHint(levelNum int, question, answer, srcHint string, active
 	err := Create("level", level)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			level.ConsoleHint = existingConsoleHint(levelNum)
 			err = Update("level", map[string]interface{}{"number": levelNum}, level)
 		}
 		if err != nil {
 			fmt.Printf("Database error in CreateLevelWithHint: %v\n", err)
+			return err
 		}
 	}
-	return err
+	return SetLevelHintChannel(levelNum, HintChannelHTMLComment, srcHint)
 }
 
 func UpdateLevelWithHint(levelNum int, question, answer, srcHint string, active bool) error {
@@ -259,23 +257,32 @@ func UpdateLevelWithHint(levelNum int, question, answer, srcHint string, active
 		LevelNumber: levelNum,
 		Markdown:    question,
 		SourceHint:  srcHint,
-		ConsoleHint: question,
+		ConsoleHint: existingConsoleHint(levelNum),
 		Answer:      answer,
 		Active:      active,
 	}
-	return Update("level", map[string]interface{}{"number": levelNum}, level)
+	err := Update("level", map[string]interface{}{"number": levelNum}, level)
+	if err != nil {
+		return err
+	}
+	return SetLevelHintChannel(levelNum, HintChannelHTMLComment, srcHint)
 }
 
+// UpdateLevelSimple updates a level, resetting its HTML comment hint to the
+// question.
 func UpdateLevelSimple(levelNum int, question, answer string, active bool) error {
-	level := AdminLevel{
-		LevelNumber: levelNum,
-		Markdown:    question,
-		SourceHint:  question,
-		ConsoleHint: question,
-		Answer:      answer,
-		Active:      active,
+	return UpdateLevelWithHint(levelNum, question, answer, question, active)
+}
+
+// existingConsoleHint keeps a level's console hint across edits. Older
+// levels stored a copy of the question there, which is not a real hint.
+func existingConsoleHint(levelNum int) string {
+	var markdown, consoleHint sql.NullString
+	err := db.QueryRow("SELECT markdown, console_hint FROM levels WHERE level_number = ?", levelNum).Scan(&markdown, &consoleHint)
+	if err != nil || consoleHint.String == markdown.String {
+		return ""
 	}
-	return Update("level", map[string]interface{}{"number": levelNum}, level)
+	return consoleHint.String
 }
 
 func DeleteLevelSimple(levelNum int) error {
@@ -328,7 +335,11 @@ func createTables() {
 			src_hint TEXT,
 			console_hint TEXT,
 			answer TEXT NOT NULL,
-			active BOOLEAN DEFAULT TRUE
+			active BOOLEAN DEFAULT TRUE,
+			status TEXT DEFAULT 'published',
+			publish_at DATETIME,
+			answer_mode TEXT DEFAULT 'static',
+			answer_secret TEXT
 		);`,
 		`CREATE TABLE IF NOT EXISTS chat_messages (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -402,7 +413,253 @@ func createTables() {
 		`CREATE TABLE IF NOT EXISTS banned_emails (
 			email TEXT PRIMARY KEY,
 			banned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			banned_by TEXT NOT NULL
+			banned_by TEXT NOT NULL,
+			reason TEXT,
+			expires_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS disqualifications (
+			user_email TEXT PRIMARY KEY,
+			reason TEXT NOT NULL,
+			block_submissions BOOLEAN DEFAULT FALSE,
+			disqualified_by TEXT NOT NULL,
+			disqualified_at DATETIME NOT NULL,
+			expires_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS score_adjustments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			reason TEXT,
+			actor TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			reverted BOOLEAN DEFAULT FALSE,
+			reverted_by TEXT,
+			reverted_at DATETIME,
+			revert_reason TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS level_attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			level_number INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			sha256 TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			uploaded_by TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS level_hint_channels (
+			level_number INTEGER NOT NULL,
+			channel TEXT NOT NULL,
+			content TEXT NOT NULL,
+			PRIMARY KEY (level_number, channel)
+		);`,
+		`CREATE TABLE IF NOT EXISTS submissions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			level_number INTEGER NOT NULL,
+			answer TEXT NOT NULL,
+			correct BOOLEAN DEFAULT FALSE,
+			submitted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_submissions_user_level ON submissions (user_email, level_number, submitted_at);`,
+		`CREATE TABLE IF NOT EXISTS submission_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			level_number INTEGER NOT NULL,
+			attempted_at DATETIME NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_submission_attempts_user_level ON submission_attempts (user_email, level_number, attempted_at);`,
+		`CREATE TABLE IF NOT EXISTS submission_limits (
+			level_number INTEGER PRIMARY KEY,
+			max_attempts INTEGER NOT NULL,
+			window_seconds INTEGER NOT NULL,
+			base_lockout_seconds INTEGER NOT NULL,
+			max_lockout_seconds INTEGER NOT NULL,
+			alert_after_strikes INTEGER DEFAULT 3
+		);`,
+		`CREATE TABLE IF NOT EXISTS submission_lockouts (
+			user_email TEXT NOT NULL,
+			level_number INTEGER NOT NULL,
+			strikes INTEGER DEFAULT 0,
+			locked_until DATETIME,
+			last_strike_at DATETIME,
+			PRIMARY KEY (user_email, level_number)
+		);`,
+		`CREATE TABLE IF NOT EXISTS scheduled_hints (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			level_number INTEGER NOT NULL,
+			message TEXT NOT NULL,
+			release_mode TEXT NOT NULL,
+			release_at DATETIME,
+			delay_minutes INTEGER DEFAULT 0,
+			created_by TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS practice_progress (
+			user_email TEXT NOT NULL,
+			level_number INTEGER NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			solved_at DATETIME,
+			PRIMARY KEY (user_email, level_number)
+		);`,
+		`CREATE TABLE IF NOT EXISTS player_sessions (
+			user_email TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			user_agent TEXT,
+			first_seen DATETIME NOT NULL,
+			last_seen DATETIME NOT NULL,
+			PRIMARY KEY (user_email, device_id, ip)
+		);`,
+		`CREATE TABLE IF NOT EXISTS suspicion_reviews (
+			user_email TEXT PRIMARY KEY,
+			status TEXT NOT NULL,
+			score INTEGER NOT NULL,
+			note TEXT,
+			reviewed_by TEXT NOT NULL,
+			reviewed_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slug TEXT UNIQUE NOT NULL,
+			name TEXT NOT NULL,
+			first_level INTEGER NOT NULL,
+			last_level INTEGER NOT NULL,
+			starts_at DATETIME NOT NULL,
+			ends_at DATETIME NOT NULL,
+			registration TEXT NOT NULL DEFAULT 'open',
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS event_enrollments (
+			event_id INTEGER NOT NULL,
+			user_email TEXT NOT NULL,
+			enrolled_by TEXT NOT NULL,
+			enrolled_at DATETIME NOT NULL,
+			PRIMARY KEY (event_id, user_email)
+		);`,
+		`CREATE TABLE IF NOT EXISTS foreign_answers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			owner_email TEXT NOT NULL,
+			level_number INTEGER NOT NULL,
+			submitted_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS level_placements (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			from_level INTEGER NOT NULL,
+			to_level INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			placed_by TEXT NOT NULL,
+			placed_at DATETIME NOT NULL,
+			completions_added INTEGER DEFAULT 0,
+			completions_removed INTEGER DEFAULT 0
+		);`,
+		`CREATE TABLE IF NOT EXISTS level_parts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			level_number INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			answer TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			required BOOLEAN DEFAULT TRUE,
+			weight INTEGER DEFAULT 0,
+			UNIQUE(level_number, name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS part_completions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			level_number INTEGER NOT NULL,
+			part_name TEXT NOT NULL,
+			practice BOOLEAN DEFAULT FALSE,
+			completed_at DATETIME NOT NULL,
+			UNIQUE(user_email, level_number, part_name, practice)
+		);`,
+		`CREATE TABLE IF NOT EXISTS level_upload_configs (
+			level_number INTEGER PRIMARY KEY,
+			check_mode TEXT NOT NULL,
+			max_size INTEGER NOT NULL,
+			allowed_types TEXT NOT NULL,
+			hashes TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS file_submissions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			level_number INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			sha256 TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			reviewed_by TEXT,
+			review_note TEXT,
+			submitted_at DATETIME NOT NULL,
+			reviewed_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS virtual_routes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			level_number INTEGER NOT NULL,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			headers TEXT,
+			body TEXT NOT NULL,
+			created_by TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			UNIQUE(level_number, method, path)
+		);`,
+		`CREATE TABLE IF NOT EXISTS badges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			description TEXT NOT NULL DEFAULT '',
+			icon TEXT NOT NULL DEFAULT '',
+			rule TEXT NOT NULL,
+			params TEXT,
+			show_on_leaderboard BOOLEAN DEFAULT FALSE,
+			active BOOLEAN DEFAULT TRUE,
+			created_by TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS player_badges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			badge_id INTEGER NOT NULL,
+			level_number INTEGER,
+			awarded_by TEXT NOT NULL DEFAULT '',
+			awarded_at DATETIME NOT NULL,
+			UNIQUE(user_email, badge_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS first_solves (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			level_number INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			user_email TEXT NOT NULL,
+			solved_at DATETIME NOT NULL,
+			UNIQUE(level_number, position),
+			UNIQUE(level_number, user_email)
+		);`,
+		`CREATE TABLE IF NOT EXISTS leaderboard_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			taken_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS leaderboard_snapshot_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			snapshot_id INTEGER NOT NULL,
+			event_id INTEGER NOT NULL DEFAULT 0,
+			gmail TEXT NOT NULL,
+			score INTEGER NOT NULL,
+			ranked_on INTEGER NOT NULL,
+			reached_at DATETIME,
+			rank INTEGER NOT NULL,
+			UNIQUE(snapshot_id, event_id, gmail)
+		);`,
+		`CREATE TABLE IF NOT EXISTS leaderboard_freeze (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			freeze_at DATETIME,
+			revealed_at DATETIME,
+			snapshot_id INTEGER,
+			set_by TEXT NOT NULL DEFAULT ''
 		);`,
 	}
 
@@ -413,6 +670,15 @@ func createTables() {
 	}
 
 	runMigrations()
+	migrateLevelSchedule()
+	migrateBanExpiry()
+	migrateEvents()
+	migrateDynamicAnswers()
+	migrateLevelParts()
+	migrateValidators()
+	migrateFirstSolves()
+	migratePlacements()
+	migrateSnapshotEvents()
 }
 
 func runMigrations() {
@@ -473,6 +739,29 @@ func runMigrations() {
 	}
 }
 
+func columnExists(table, column string) bool {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name, dataType string
+		var notNull, pk int
+		var defaultValue interface{}
+
+		if err := rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk); err != nil {
+			continue
+		}
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
 func Get(entity string, params map[string]interface{}) (interface{}, error) {
 	switch entity {
 	case "login":
@@ -559,46 +848,20 @@ func Get(entity string, params map[string]interface{}) (interface{}, error) {
 		if l, ok := params["limit"].(int); ok {
 			limit = l
 		}
-
-		adminEmails := config.GetAdminEmails()
-
-		whereClause := ""
-		args := []interface{}{}
-		if len(adminEmails) > 0 {
-			placeholders := make([]string, len(adminEmails))
-			for i, email := range adminEmails {
-				placeholders[i] = "?"
-				args = append(args, email)
+		if eventID, ok := params["eventId"].(int); ok && eventID > 0 {
+			event, err := GetEvent(eventID)
+			if err != nil {
+				return nil, err
 			}
-			whereClause = " WHERE gmail NOT IN (" + strings.Join(placeholders, ",") + ")"
-		}
-
-		baseQuery := `SELECT l.gmail, 0 as score, l."on" FROM logins l
-			LEFT JOIN level_completions lc ON l.gmail = lc.user_email AND lc.level_number = l."on" - 1`
-
-		if whereClause != "" {
-			baseQuery += " " + strings.Replace(whereClause, "gmail", "l.gmail", -1)
-		}
-
-		query := baseQuery + ` ORDER BY l."on" DESC, lc.completed_at ASC`
-		if limit > 0 {
-			query += fmt.Sprintf(" LIMIT %d", limit)
+			return selectEventStandings(event, limit)
 		}
-
-		rows, err := db.Query(query, args...)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-		var suckers []Sucker
-		for rows.Next() {
-			var s Sucker
-			if err := rows.Scan(&s.Gmail, &s.Score, &s.On); err != nil {
-				return nil, err
+		if standings, ok := standingsCache.get(); ok {
+			if limit > 0 && len(standings) > limit {
+				standings = standings[:limit]
 			}
-			suckers = append(suckers, s)
+			return standings, nil
 		}
-		return suckers, nil
+		return selectStandings("", nil, limit)
 	case "chat_messages":
 		limit := 50
 		if l, ok := params["limit"].(int); ok {
@@ -679,8 +942,11 @@ func Get(entity string, params map[string]interface{}) (interface{}, error) {
 			return count, nil
 		}
 	case "max_level":
-		var maxLevel int
-		err := db.QueryRow("SELECT MAX(level_number) FROM levels WHERE active = 1").Scan(&maxLevel)
+		maxLevel, err := maxLevelCache.get(func() (int, error) {
+			var maxLevel int
+			err := db.QueryRow("SELECT MAX(level_number) FROM levels WHERE active = 1").Scan(&maxLevel)
+			return maxLevel, err
+		})
 		if err != nil {
 			return 0, err
 		}
@@ -890,12 +1156,16 @@ func Create(entity string, data interface{}) error {
 		if login, ok := data.(Login); ok {
 			_, err := db.Exec("INSERT INTO logins (gmail, hashed, seshTok, CSRFtok, name, verified, verificationNumber, loginCode, \"on\") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
 				login.Gmail, login.Hashed, login.SeshTok, login.CSRFtok, login.Name, login.Verified, login.VerificationNumber, login.LoginCode, login.On)
+			if err == nil {
+				refreshLeaderboardPlayer(login.Gmail)
+			}
 			return err
 		}
 	case "level":
 		if level, ok := data.(AdminLevel); ok {
-			_, err := db.Exec("INSERT INTO levels (level_number, markdown, src_hint, console_hint, answer, active) VALUES (?, ?, ?, ?, ?, ?)",
-				level.LevelNumber, level.Markdown, level.SourceHint, level.ConsoleHint, level.Answer, level.Active)
+			_, err := db.Exec("INSERT INTO levels (level_number, markdown, src_hint, console_hint, answer, active, status) VALUES (?, ?, ?, ?, ?, ?, CASE WHEN ? THEN 'published' ELSE 'draft' END)",
+				level.LevelNumber, level.Markdown, level.SourceHint, level.ConsoleHint, level.Answer, level.Active, level.Active)
+			maxLevelCache.invalidate()
 			return err
 		}
 	case "leaderboard":
@@ -971,6 +1241,9 @@ func Update(entity string, params map[string]interface{}, data interface{}) erro
 				}
 				query := fmt.Sprintf("UPDATE logins SET %s = ? WHERE gmail = ?", field)
 				_, err := db.Exec(query, data, gmail)
+				if err == nil && field == `"on"` {
+					refreshLeaderboardPlayer(gmail)
+				}
 				return err
 			}
 		}
@@ -985,8 +1258,10 @@ func Update(entity string, params map[string]interface{}, data interface{}) erro
 	case "level":
 		if number, ok := params["number"].(int); ok {
 			if level, ok := data.(AdminLevel); ok {
-				_, err := db.Exec(`UPDATE levels SET level_number = ?, markdown = ?, src_hint = ?, console_hint = ?, answer = ?, active = ? WHERE level_number = ?`,
-					level.LevelNumber, level.Markdown, level.SourceHint, level.ConsoleHint, level.Answer, level.Active, number)
+				_, err := db.Exec(`UPDATE levels SET level_number = ?, markdown = ?, src_hint = ?, console_hint = ?, answer = ?, active = ?,
+					status = `+levelStatusFromActive+` WHERE level_number = ?`,
+					level.LevelNumber, level.Markdown, level.SourceHint, level.ConsoleHint, level.Answer, level.Active, level.Active, number)
+				maxLevelCache.invalidate()
 				return err
 			}
 		}
@@ -1010,13 +1285,15 @@ func Update(entity string, params map[string]interface{}, data interface{}) erro
 	case "level_state":
 		if number, ok := params["number"].(int); ok {
 			if state, ok := data.(bool); ok {
-				_, err := db.Exec("UPDATE levels SET active = ? WHERE level_number = ?", state, number)
+				_, err := db.Exec("UPDATE levels SET active = ?, status = "+levelStatusFromActive+" WHERE level_number = ?", state, state, number)
+				maxLevelCache.invalidate()
 				return err
 			}
 		}
 	case "bulk_level_state":
 		if state, ok := data.(bool); ok {
-			_, err := db.Exec("UPDATE levels SET active = ?", state)
+			_, err := db.Exec("UPDATE levels SET active = ?, status = "+levelStatusFromActive, state, state)
+			maxLevelCache.invalidate()
 			return err
 		}
 	case "notification_read":
@@ -1061,11 +1338,18 @@ func Delete(entity string, params map[string]interface{}) error {
 	case "login":
 		if gmail, ok := params["gmail"].(string); ok {
 			_, err := db.Exec("DELETE FROM logins WHERE gmail = ?", gmail)
+			if err == nil {
+				refreshLeaderboardPlayer(gmail)
+			}
 			return err
 		}
 	case "level":
 		if number, ok := params["number"].(int); ok {
 			_, err := db.Exec("DELETE FROM levels WHERE level_number = ?", number)
+			maxLevelCache.invalidate()
+			if err == nil {
+				alignAllPlayerLevels()
+			}
 			return err
 		}
 	case "announcement":
@@ -1078,25 +1362,54 @@ func Delete(entity string, params map[string]interface{}) error {
 }
 
 type GameLevel struct {
-	ID           int    `json:"id"`
-	Number       int    `json:"number"`
-	Description  string `json:"description"`
-	Markdown     string `json:"markdown,omitempty"`
-	MediaURL     string `json:"mediaUrl,omitempty"`
-	MediaType    string `json:"mediaType,omitempty"`
-	AllCompleted bool   `json:"allCompleted,omitempty"`
-	MaxLevel     int    `json:"maxLevel,omitempty"`
+	ID           int               `json:"id"`
+	Number       int               `json:"number"`
+	Description  string            `json:"description"`
+	Markdown     string            `json:"markdown,omitempty"`
+	HTML         string            `json:"html,omitempty"`
+	Revision     string            `json:"revision,omitempty"`
+	MediaURL     string            `json:"mediaUrl,omitempty"`
+	MediaType    string            `json:"mediaType,omitempty"`
+	Attachments  []AttachmentLink  `json:"attachments,omitempty"`
+	AllCompleted bool              `json:"allCompleted,omitempty"`
+	MaxLevel     int               `json:"maxLevel,omitempty"`
+	Parts        []LevelPartStatus `json:"parts,omitempty"`
+	Upload       *UploadConfig     `json:"upload,omitempty"`
+	// PendingReview is set while the player's upload waits for an admin
+	PendingReview bool `json:"pendingReview,omitempty"`
 }
 
 func GetCurrentLevelForUser(userEmail string) (*GameLevel, error) {
-	var level1Exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM levels WHERE level_number = 1 AND active = 1)").Scan(&level1Exists)
+	first, last, inEvent, err := playerLevelRange(userEmail)
+	if err != nil {
+		log.Printf("ERROR: Failed to determine level range for %s: %v", userEmail, err)
+		return nil, fmt.Errorf("database error checking event enrollment")
+	}
+
+	if last < first {
+		return &GameLevel{
+			ID:           0,
+			Description:  "Enroll in an event to start playing.",
+			AllCompleted: true,
+		}, nil
+	}
+
+	var firstLevelExists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM levels WHERE level_number = ? AND active = 1)", first).Scan(&firstLevelExists)
 	if err != nil {
-		log.Printf("ERROR: Failed to check if level 1 exists: %v", err)
-		return nil, fmt.Errorf("database error checking level 1")
+		log.Printf("ERROR: Failed to check if level %d exists: %v", first, err)
+		return nil, fmt.Errorf("database error checking level %d", first)
 	}
 
-	if !level1Exists {
+	if !firstLevelExists {
+		if inEvent {
+			return &GameLevel{
+				ID:           0,
+				Number:       first,
+				Description:  "The first level of this event has not been released yet. Check back soon!",
+				AllCompleted: true,
+			}, nil
+		}
 		log.Printf("ERROR: Level 1 does not exist or is not active")
 		return nil, fmt.Errorf("level 1 must exist and be active for the game to function")
 	}
@@ -1108,32 +1421,20 @@ func GetCurrentLevelForUser(userEmail string) (*GameLevel, error) {
 		return nil, fmt.Errorf("user not found or no level assigned")
 	}
 
+	// Writes that can strand a player outside their range correct it with
+	// alignPlayerLevel; this only keeps another range's levels from showing
+	if int(user.On) < first || int(user.On) > last+1 {
+		log.Printf("WARNING: User %s is on level %d, outside their levels %d-%d; showing level %d", userEmail, user.On, first, last, first)
+		user.On = uint(first)
+	}
+
 	var maxLevelNumber int
-	err = db.QueryRow("SELECT MAX(level_number) FROM levels WHERE active = 1").Scan(&maxLevelNumber)
+	err = db.QueryRow("SELECT MAX(level_number) FROM levels WHERE active = 1 AND level_number BETWEEN ? AND ?", first, last).Scan(&maxLevelNumber)
 	if err != nil {
 		log.Printf("ERROR: Failed to get max level number: %v", err)
 		return nil, fmt.Errorf("error determining maximum level")
 	}
 
-	if user.On > 1 {
-		var allPreviousExist bool
-		err = db.QueryRow("SELECT COUNT(*) = ? FROM levels WHERE level_number BETWEEN 1 AND ? AND active = 1", user.On-1, user.On-1).Scan(&allPreviousExist)
-		if err != nil {
-			log.Printf("ERROR: Failed to check previous levels for user %s: %v", userEmail, err)
-			return nil, fmt.Errorf("database error checking level progression")
-		}
-
-		if !allPreviousExist {
-			log.Printf("ERROR: User %s is on level %d but not all previous levels (1-%d) exist or are active", userEmail, user.On, user.On-1)
-			_, err = db.Exec("UPDATE logins SET \"on\" = 1 WHERE gmail = ?", userEmail)
-			if err != nil {
-				log.Printf("ERROR: Failed to reset user %s to level 1: %v", userEmail, err)
-			}
-			user.On = 1
-			log.Printf("INFO: Reset user %s to level 1 due to broken progression", userEmail)
-		}
-	}
-
 	if int(user.On) > maxLevelNumber {
 		log.Printf("INFO: User %s has completed all available levels (current level: %d, max level: %d)", userEmail, user.On, maxLevelNumber)
 		gameLevel := &GameLevel{
@@ -1146,25 +1447,23 @@ func GetCurrentLevelForUser(userEmail string) (*GameLevel, error) {
 		return gameLevel, nil
 	}
 
+	var unreleased bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM levels WHERE level_number = ? AND active = 0)", user.On).Scan(&unreleased)
+	if err == nil && unreleased {
+		return &GameLevel{
+			ID:           0,
+			Number:       int(user.On),
+			Description:  "The next level has not been released yet. Check back soon!",
+			AllCompleted: true,
+			MaxLevel:     maxLevelNumber,
+		}, nil
+	}
+
 	var level AdminLevel
 	err = db.QueryRow("SELECT level_number, markdown FROM levels WHERE level_number = ? AND active = 1", user.On).Scan(&level.LevelNumber, &level.Markdown)
 	if err != nil {
 		log.Printf("ERROR: Failed to get level %d for user %s: %v", user.On, userEmail, err)
-		if user.On > 1 {
-			_, resetErr := db.Exec("UPDATE logins SET \"on\" = 1 WHERE gmail = ?", userEmail)
-			if resetErr != nil {
-				log.Printf("ERROR: Failed to reset user %s to level 1: %v", userEmail, resetErr)
-			} else {
-				log.Printf("INFO: Reset user %s to level 1 because level %d doesn't exist", userEmail, user.On)
-				err = db.QueryRow("SELECT level_number, markdown FROM levels WHERE level_number = 1 AND active = 1").Scan(&level.LevelNumber, &level.Markdown)
-				if err != nil {
-					log.Printf("ERROR: Failed to get level 1 after reset: %v", err)
-					return nil, fmt.Errorf("level 1 not found after reset")
-				}
-			}
-		} else {
-			return nil, fmt.Errorf("level not found or not active")
-		}
+		return nil, fmt.Errorf("level not found or not active")
 	}
 
 	gameLevel := &GameLevel{
@@ -1174,32 +1473,146 @@ func GetCurrentLevelForUser(userEmail string) (*GameLevel, error) {
 		Markdown:    level.Markdown,
 	}
 
+	attachments, err := GetAttachmentLinks(level.LevelNumber, userEmail)
+	if err != nil {
+		log.Printf("WARNING: Failed to load attachments for level %d: %v", level.LevelNumber, err)
+	} else if len(attachments) > 0 {
+		gameLevel.Attachments = attachments
+		gameLevel.MediaURL = attachments[0].URL
+		gameLevel.MediaType = attachments[0].ContentType
+	}
+	renderLevelHTML(gameLevel, userEmail)
+
+	gameLevel.Parts, err = GetLevelPartStatuses(level.LevelNumber, userEmail, false)
+	if err != nil {
+		log.Printf("WARNING: Failed to load parts for level %d: %v", level.LevelNumber, err)
+	}
+
+	if upload, err := GetUploadConfig(level.LevelNumber); err == nil {
+		gameLevel.Upload = playerUploadView(upload)
+		gameLevel.PendingReview = hasPendingFileSubmission(userEmail, level.LevelNumber)
+	}
+
 	return gameLevel, nil
 }
 
+// alignPlayerLevel moves a player back to the start of their level range
+// when their level has fallen outside it, when a level below theirs is
+// missing, or when their own level was deleted. It runs after the writes
+// that can cause this, so reading a player's level never has to write.
+func alignPlayerLevel(userEmail string) error {
+	first, last, _, err := playerLevelRange(userEmail)
+	if err != nil {
+		return err
+	}
+	if last < first {
+		return nil
+	}
+
+	var on int
+	if err := db.QueryRow("SELECT \"on\" FROM logins WHERE gmail = ?", userEmail).Scan(&on); err != nil {
+		return err
+	}
+
+	var reason string
+	if on < first || on > last+1 {
+		reason = "they are outside their level range"
+	} else if on > first {
+		// Levels moved back to draft still count; only missing levels break progression
+		var brokenProgression bool
+		err := db.QueryRow("SELECT COUNT(*) != ? FROM levels WHERE level_number BETWEEN ? AND ?", on-first, first, on-1).Scan(&brokenProgression)
+		if err != nil {
+			return err
+		}
+		var levelMissing bool
+		err = db.QueryRow(`SELECT NOT EXISTS(SELECT 1 FROM levels WHERE level_number = ?)
+			AND EXISTS(SELECT 1 FROM levels WHERE level_number > ? AND level_number <= ? AND active = 1)`, on, on, last).Scan(&levelMissing)
+		if err != nil {
+			return err
+		}
+		switch {
+		case brokenProgression:
+			reason = "a level before theirs is missing"
+		case levelMissing:
+			reason = fmt.Sprintf("level %d does not exist", on)
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	if _, err := db.Exec("UPDATE logins SET \"on\" = ? WHERE gmail = ?", first, userEmail); err != nil {
+		return err
+	}
+	refreshLeaderboardPlayer(userEmail)
+	log.Printf("INFO: Moved user %s from level %d to level %d because %s", userEmail, on, first, reason)
+	return nil
+}
+
+// alignAllPlayerLevels runs alignPlayerLevel for every player, after a
+// change to levels or event ranges that can affect many of them.
+func alignAllPlayerLevels() {
+	rows, err := db.Query("SELECT gmail FROM logins")
+	if err != nil {
+		log.Printf("ERROR: Failed to list players to align levels: %v", err)
+		return
+	}
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err == nil {
+			emails = append(emails, email)
+		}
+	}
+	rows.Close()
+
+	for _, email := range emails {
+		if err := alignPlayerLevel(email); err != nil {
+			log.Printf("ERROR: Failed to align level for %s: %v", email, err)
+		}
+	}
+}
+
 type SubmitAnswerResult struct {
 	Correct    bool   `json:"correct"`
 	Message    string `json:"message"`
 	ReloadPage bool   `json:"reload_page"`
+	Cooldown   bool   `json:"cooldown,omitempty"`
+	RetryAfter int    `json:"retryAfter,omitempty"`
+	RetryAt    string `json:"retryAt,omitempty"`
+	// Part names the part of a multi-part level the answer solved
+	Part  string            `json:"part,omitempty"`
+	Parts []LevelPartStatus `json:"parts,omitempty"`
 }
 
-func CheckAnswer(userEmail string, levelID int, answer string) (*SubmitAnswerResult, error) {
-	if strings.Contains(answer, " ") {
-		return &SubmitAnswerResult{
+// precheckAnswer returns the level's validator, or the result to send back
+// when the answer can not be judged as typed: the level takes file uploads,
+// or the answer breaks the format rules players are told about.
+func precheckAnswer(levelID int, submitted string) (*LevelValidator, *SubmitAnswerResult, error) {
+	if _, err := GetUploadConfig(levelID); err == nil {
+		return nil, &SubmitAnswerResult{
 			Correct: false,
-			Message: "Answer cannot contain spaces. Please enter a valid answer without spaces.",
+			Message: "This level is solved by uploading a file.",
 		}, nil
 	}
 
-	for _, char := range answer {
-		if char >= 'A' && char <= 'Z' {
-			return &SubmitAnswerResult{
+	validator, err := GetLevelValidator(levelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if validator.strictFormat() {
+		if verdict := checkAnswerFormat(submitted); verdict != nil {
+			return nil, &SubmitAnswerResult{
 				Correct: false,
-				Message: "Answer must be lowercase only. Please enter the answer in lowercase.",
+				Message: verdict.Message,
 			}, nil
 		}
 	}
+	return validator, nil, nil
+}
 
+func CheckAnswer(userEmail string, levelID int, answer string) (*SubmitAnswerResult, error) {
+	submitted := answer
 	answer = strings.TrimSpace(answer)
 
 	var currentLevel uint
@@ -1228,8 +1641,20 @@ func CheckAnswer(userEmail string, levelID int, answer string) (*SubmitAnswerRes
 		}, nil
 	}
 
+	first, last, _, err := playerLevelRange(userEmail)
+	if err != nil {
+		return nil, err
+	}
+	if levelID < first || levelID > last {
+		return &SubmitAnswerResult{
+			Correct: false,
+			Message: "Level not found",
+		}, nil
+	}
+
 	var correctAnswer string
-	err = db.QueryRow("SELECT answer FROM levels WHERE level_number = ? AND active = 1", levelID).Scan(&correctAnswer)
+	var answerMode, answerSecret sql.NullString
+	err = db.QueryRow("SELECT answer, answer_mode, answer_secret FROM levels WHERE level_number = ? AND active = 1", levelID).Scan(&correctAnswer, &answerMode, &answerSecret)
 	if err != nil {
 		return &SubmitAnswerResult{
 			Correct: false,
@@ -1237,51 +1662,70 @@ func CheckAnswer(userEmail string, levelID int, answer string) (*SubmitAnswerRes
 		}, nil
 	}
 
-	if answer == strings.TrimSpace(correctAnswer) {
-		var maxLevelNumber int
-		err = db.QueryRow("SELECT MAX(level_number) FROM levels WHERE active = 1").Scan(&maxLevelNumber)
-		if err != nil {
-			log.Printf("ERROR: Failed to get max level number: %v", err)
-			return nil, err
-		}
+	validator, rejected, err := precheckAnswer(levelID, submitted)
+	if err != nil {
+		return nil, err
+	}
+	if rejected != nil {
+		return rejected, nil
+	}
 
-		// Record level completion time
-		_, err = db.Exec("INSERT OR IGNORE INTO level_completions (user_email, level_number) VALUES (?, ?)", userEmail, levelID)
-		if err != nil {
-			log.Printf("ERROR: Failed to record level completion time: %v", err)
-		}
+	partsConfig, err := GetLevelPartsConfig(levelID)
+	if err != nil {
+		return nil, err
+	}
 
-		if levelID == maxLevelNumber {
-			_, err = db.Exec("UPDATE logins SET \"on\" = ? WHERE gmail = ?", maxLevelNumber+1, userEmail)
-		} else {
-			_, err = db.Exec("UPDATE logins SET \"on\" = \"on\" + 1 WHERE gmail = ?", userEmail)
+	var correct bool
+	var solvedPart string
+	if len(partsConfig.Parts) > 0 {
+		part, complete, err := solveLevelPart(partsConfig, validator, userEmail, answer, false)
+		if errors.Is(err, ErrCheckerUnavailable) {
+			return checkerUnavailableResult(userEmail, levelID, err), nil
 		}
-
 		if err != nil {
 			return nil, err
 		}
-
-		log.Printf("DEBUG CheckAnswer: User %s answered correctly for level %d, promoted to level %d", userEmail, levelID, levelID+1)
-
-		// Delete lead messages for the completed level since user won't be revisiting it
-		err = DeleteUserMessagesForLevel(userEmail, levelID, "lead")
+		recordSubmission(userEmail, levelID, answer, part != nil)
+		if part != nil {
+			solvedPart = part.Name
+			if !complete {
+				parts, _ := GetLevelPartStatuses(levelID, userEmail, false)
+				return &SubmitAnswerResult{
+					Correct: true,
+					Message: fmt.Sprintf("Part %s solved! Keep going.", part.Name),
+					Part:    part.Name,
+					Parts:   parts,
+				}, nil
+			}
+		}
+		correct = complete
+	} else {
+		verdict, err := matchAnswer(validator, Submission{
+			LevelNumber: levelID,
+			UserEmail:   userEmail,
+			Answer:      answer,
+			Expected:    expectedAnswer(levelID, correctAnswer, answerMode, answerSecret, userEmail),
+		})
 		if err != nil {
-			log.Printf("WARNING: Failed to delete lead messages for user %s level %d: %v", userEmail, levelID, err)
-			// Don't return error here as this is cleanup and shouldn't prevent level advancement
-		} else {
-			log.Printf("DEBUG: Successfully deleted lead messages for user %s level %d", userEmail, levelID)
+			return checkerUnavailableResult(userEmail, levelID, err), nil
 		}
+		correct = verdict.Correct
+		recordSubmission(userEmail, levelID, answer, correct)
 
-		notification := map[string]interface{}{
-			"userEmail": userEmail,
-			"message":   fmt.Sprintf("Congratulations! You completed Level %d", levelID),
-			"type":      "success",
+		if !correct && answerMode.String == AnswerModeDynamic && answerSecret.String != "" {
+			recordForeignAnswer(userEmail, levelID, answer, answerSecret.String)
+		}
+	}
+
+	if correct {
+		if err := completeLevel(userEmail, levelID, first, last); err != nil {
+			return nil, err
 		}
-		Create("notification", notification)
 
 		return &SubmitAnswerResult{
 			Correct: true,
 			Message: "Correct! Moving to next level...",
+			Part:    solvedPart,
 		}, nil
 	}
 
@@ -1291,12 +1735,64 @@ func CheckAnswer(userEmail string, levelID int, answer string) (*SubmitAnswerRes
 	}, nil
 }
 
+// completeLevel records a player's completion of the level they are on and
+// moves them to the next one within their level range.
+func completeLevel(userEmail string, levelID, first, last int) error {
+	var maxLevelNumber int
+	err := db.QueryRow("SELECT MAX(level_number) FROM levels WHERE active = 1 AND level_number BETWEEN ? AND ?", first, last).Scan(&maxLevelNumber)
+	if err != nil {
+		log.Printf("ERROR: Failed to get max level number: %v", err)
+		return err
+	}
+
+	// Record level completion time
+	_, err = db.Exec("INSERT OR IGNORE INTO level_completions (user_email, level_number) VALUES (?, ?)", userEmail, levelID)
+	if err != nil {
+		log.Printf("ERROR: Failed to record level completion time: %v", err)
+	}
+
+	if levelID == maxLevelNumber {
+		_, err = db.Exec("UPDATE logins SET \"on\" = ? WHERE gmail = ?", maxLevelNumber+1, userEmail)
+	} else {
+		_, err = db.Exec("UPDATE logins SET \"on\" = \"on\" + 1 WHERE gmail = ?", userEmail)
+	}
+
+	if err != nil {
+		return err
+	}
+	refreshLeaderboardPlayer(userEmail)
+
+	log.Printf("DEBUG completeLevel: User %s completed level %d, promoted to level %d", userEmail, levelID, levelID+1)
+
+	// Delete lead messages for the completed level since user won't be revisiting it
+	err = DeleteUserMessagesForLevel(userEmail, levelID, "lead")
+	if err != nil {
+		log.Printf("WARNING: Failed to delete lead messages for user %s level %d: %v", userEmail, levelID, err)
+		// Don't return error here as this is cleanup and shouldn't prevent level advancement
+	} else {
+		log.Printf("DEBUG: Successfully deleted lead messages for user %s level %d", userEmail, levelID)
+	}
+
+	notification := map[string]interface{}{
+		"userEmail": userEmail,
+		"message":   fmt.Sprintf("Congratulations! You completed Level %d", levelID),
+		"type":      "success",
+	}
+	Create("notification", notification)
+
+	trackFirstSolve(userEmail, levelID)
+	RecordProgress(ProgressEvent{Kind: ProgressLevelSolved, UserEmail: userEmail, LevelNumber: levelID})
+
+	return nil
+}
+
 type Announcement struct {
 	ID        int    `json:"id" db:"id"`
 	Heading   string `json:"heading" db:"heading"`
 	CreatedAt string `json:"created_at" db:"created_at"`
 	UpdatedAt string `json:"updated_at" db:"updated_at"`
 	Active    bool   `json:"active" db:"active"`
+	EventID   *int   `json:"eventId,omitempty" db:"event_id"`
 }
 
 func CreateAnnouncement(heading string) error {
@@ -1307,7 +1803,7 @@ func CreateAnnouncement(heading string) error {
 
 func GetAllAnnouncements() ([]Announcement, error) {
 	var announcements []Announcement
-	query := `SELECT id, heading, created_at, updated_at, active FROM announcements WHERE active = TRUE ORDER BY created_at DESC`
+	query := `SELECT id, heading, created_at, updated_at, active, event_id FROM announcements WHERE active = TRUE ORDER BY created_at DESC`
 
 	rows, err := db.Query(query)
 	if err != nil {
@@ -1316,27 +1812,19 @@ func GetAllAnnouncements() ([]Announcement, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var announcement Announcement
-		err := rows.Scan(&announcement.ID, &announcement.Heading, &announcement.CreatedAt, &announcement.UpdatedAt, &announcement.Active)
+		announcement, err := scanAnnouncement(rows)
 		if err != nil {
 			return nil, err
 		}
-		announcements = append(announcements, announcement)
+		announcements = append(announcements, *announcement)
 	}
 
 	return announcements, nil
 }
 
 func GetAnnouncementByID(id int) (*Announcement, error) {
-	var announcement Announcement
-	query := `SELECT id, heading, created_at, updated_at, active FROM announcements WHERE id = ?`
-
-	err := db.QueryRow(query, id).Scan(&announcement.ID, &announcement.Heading, &announcement.CreatedAt, &announcement.UpdatedAt, &announcement.Active)
-	if err != nil {
-		return nil, err
-	}
-
-	return &announcement, nil
+	query := `SELECT id, heading, created_at, updated_at, active, event_id FROM announcements WHERE id = ?`
+	return scanAnnouncement(db.QueryRow(query, id))
 }
 
 func UpdateAnnouncement(id int, heading string) error {
@@ -1351,9 +1839,20 @@ func DeleteAnnouncement(id int) error {
 	return err
 }
 
+// ResetUserLevel sends a player back to the first level of their range.
+// Their completions in that range go with it; progress in other events'
+// ranges is kept so those standings do not change.
 func ResetUserLevel(userEmail string) error {
 	log.Printf("Resetting level for user %s", userEmail)
-	result, err := db.Exec("UPDATE logins SET \"on\" = 1 WHERE gmail = ?", userEmail)
+	first, last, _, err := playerLevelRange(userEmail)
+	if err != nil {
+		return err
+	}
+	if last < first {
+		// No levels of their own to clear
+		first, last = 1, 0
+	}
+	result, err := db.Exec("UPDATE logins SET \"on\" = ? WHERE gmail = ?", first, userEmail)
 	if err != nil {
 		log.Printf("ERROR: Failed to reset level for user %s: %v", userEmail, err)
 		return err
@@ -1368,9 +1867,19 @@ func ResetUserLevel(userEmail string) error {
 		return fmt.Errorf("user %s not found", userEmail)
 	}
 
+	_, err = db.Exec("DELETE FROM level_completions WHERE user_email = ? AND level_number BETWEEN ? AND ?", userEmail, first, last)
+	if err != nil {
+		log.Printf("WARNING: Failed to clear completions for user %s: %v", userEmail, err)
+	}
+	_, err = db.Exec("DELETE FROM part_completions WHERE user_email = ? AND practice = FALSE", userEmail)
+	if err != nil {
+		log.Printf("WARNING: Failed to clear part progress for user %s: %v", userEmail, err)
+	}
+	refreshLeaderboardPlayer(userEmail)
+
 	notification := map[string]interface{}{
 		"userEmail": userEmail,
-		"message":   "Your level has been reset to Level 1 by an administrator",
+		"message":   fmt.Sprintf("Your level has been reset to Level %d by an administrator", first),
 		"type":      "info",
 	}
 	Create("notification", notification)
@@ -1504,13 +2013,12 @@ func SetLevelChatStatus(level int, status string) error {
 }
 
 func BanEmail(email, bannedBy string) error {
-	_, err := db.Exec("INSERT OR REPLACE INTO banned_emails (email, banned_by, banned_at) VALUES (?, ?, CURRENT_TIMESTAMP)", email, bannedBy)
-	return err
+	return BanEmailUntil(email, bannedBy, "", nil)
 }
 
 func IsEmailBanned(email string) (bool, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM banned_emails WHERE email = ?", email).Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM banned_emails WHERE email = ? AND (expires_at IS NULL OR expires_at > ?)", email, time.Now().UTC()).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -1518,13 +2026,13 @@ func IsEmailBanned(email string) (bool, error) {
 }
 
 func GetLevelHint(levelNumber int) (string, error) {
-	var srcHint sql.NullString
-	err := db.QueryRow("SELECT src_hint FROM levels WHERE level_number = ? AND active = 1", levelNumber).Scan(&srcHint)
+	var active bool
+	err := db.QueryRow("SELECT active FROM levels WHERE level_number = ?", levelNumber).Scan(&active)
 	if err != nil {
 		return "", err
 	}
-	if !srcHint.Valid || srcHint.String == "" {
-		return "", nil
+	if !active {
+		return "", sql.ErrNoRows
 	}
-	return srcHint.String, nil
+	return GetLevelHintChannel(levelNumber, HintChannelHTMLComment)
 }
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// excludedFromRankings selects players kept off public rankings: anyone
+// with an active disqualification or ban. It takes the current time twice.
+const excludedFromRankings = `SELECT user_email FROM disqualifications WHERE expires_at IS NULL OR expires_at > ?
+	UNION SELECT email FROM banned_emails WHERE expires_at IS NULL OR expires_at > ?`
+
+type EmailBan struct {
+	Email     string     `json:"email"`
+	Reason    string     `json:"reason"`
+	BannedBy  string     `json:"bannedBy"`
+	BannedAt  time.Time  `json:"bannedAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Disqualification hides a player from public rankings while keeping their
+// account and progress. BlockSubmissions additionally stops them answering.
+type Disqualification struct {
+	UserEmail        string     `json:"userEmail"`
+	Reason           string     `json:"reason"`
+	BlockSubmissions bool       `json:"blockSubmissions"`
+	DisqualifiedBy   string     `json:"disqualifiedBy"`
+	DisqualifiedAt   time.Time  `json:"disqualifiedAt"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+}
+
+func migrateBanExpiry() {
+	if !columnExists("banned_emails", "reason") {
+		db.Exec("ALTER TABLE banned_emails ADD COLUMN reason TEXT")
+	}
+	if !columnExists("banned_emails", "expires_at") {
+		db.Exec("ALTER TABLE banned_emails ADD COLUMN expires_at DATETIME")
+	}
+}
+
+// BanEmailUntil bans an email, optionally until expiresAt, and revokes the
+// player's current session so the ban takes effect immediately.
+func BanEmailUntil(email, bannedBy, reason string, expiresAt *time.Time) error {
+	var expires interface{}
+	if expiresAt != nil {
+		if !expiresAt.After(time.Now()) {
+			return fmt.Errorf("expiresAt must be in the future")
+		}
+		expires = expiresAt.UTC()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT OR REPLACE INTO banned_emails (email, banned_by, banned_at, reason, expires_at) VALUES (?, ?, CURRENT_TIMESTAMP, ?, ?)",
+		email, bannedBy, reason, expires)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE logins SET seshTok = '', CSRFtok = '' WHERE gmail = ?", email)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// Reloaded rather than refreshed so the cache learns the new expiry
+	reloadLeaderboardCache()
+	return nil
+}
+
+func UnbanEmail(email string) error {
+	result, err := db.Exec("DELETE FROM banned_emails WHERE email = ?", email)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	reloadLeaderboardCache()
+	return nil
+}
+
+func GetActiveBans() ([]EmailBan, error) {
+	rows, err := db.Query("SELECT email, reason, banned_by, banned_at, expires_at FROM banned_emails WHERE expires_at IS NULL OR expires_at > ? ORDER BY banned_at DESC",
+		time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []EmailBan{}
+	for rows.Next() {
+		var ban EmailBan
+		var reason sql.NullString
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&ban.Email, &reason, &ban.BannedBy, &ban.BannedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		ban.Reason = reason.String
+		if expiresAt.Valid {
+			t := expiresAt.Time
+			ban.ExpiresAt = &t
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+func DisqualifyPlayer(userEmail, reason string, blockSubmissions bool, actor string, expiresAt *time.Time) error {
+	var expires interface{}
+	if expiresAt != nil {
+		if !expiresAt.After(time.Now()) {
+			return fmt.Errorf("expiresAt must be in the future")
+		}
+		expires = expiresAt.UTC()
+	}
+
+	_, err := db.Exec(`INSERT INTO disqualifications (user_email, reason, block_submissions, disqualified_by, disqualified_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_email) DO UPDATE SET reason = excluded.reason, block_submissions = excluded.block_submissions,
+		disqualified_by = excluded.disqualified_by, disqualified_at = excluded.disqualified_at, expires_at = excluded.expires_at`,
+		userEmail, reason, blockSubmissions, actor, time.Now().UTC(), expires)
+	if err != nil {
+		return err
+	}
+	reloadLeaderboardCache()
+	return nil
+}
+
+func RequalifyPlayer(userEmail string) error {
+	result, err := db.Exec("DELETE FROM disqualifications WHERE user_email = ?", userEmail)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	reloadLeaderboardCache()
+	return nil
+}
+
+// GetDisqualification returns the player's active disqualification, or nil
+// when they are in good standing.
+func GetDisqualification(userEmail string) (*Disqualification, error) {
+	row := db.QueryRow(`SELECT user_email, reason, block_submissions, disqualified_by, disqualified_at, expires_at FROM disqualifications
+		WHERE user_email = ? AND (expires_at IS NULL OR expires_at > ?)`, userEmail, time.Now().UTC())
+	d, err := scanDisqualification(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+func GetDisqualifications() ([]Disqualification, error) {
+	rows, err := db.Query(`SELECT user_email, reason, block_submissions, disqualified_by, disqualified_at, expires_at FROM disqualifications
+		WHERE expires_at IS NULL OR expires_at > ? ORDER BY disqualified_at DESC`, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	disqualifications := []Disqualification{}
+	for rows.Next() {
+		d, err := scanDisqualification(rows)
+		if err != nil {
+			return nil, err
+		}
+		disqualifications = append(disqualifications, *d)
+	}
+	return disqualifications, nil
+}
+
+func scanDisqualification(row rowScanner) (*Disqualification, error) {
+	var d Disqualification
+	var expiresAt sql.NullTime
+	err := row.Scan(&d.UserEmail, &d.Reason, &d.BlockSubmissions, &d.DisqualifiedBy, &d.DisqualifiedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		t := expiresAt.Time
+		d.ExpiresAt = &t
+	}
+	return &d, nil
+}
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AnswerModeStatic  = "static"
+	AnswerModeDynamic = "dynamic"
+)
+
+// PlayerTokenVariable is replaced in dynamic level content with the
+// viewer's own token.
+const PlayerTokenVariable = "{{player_token}}"
+
+// playerAnswerPattern matches {{player_answer}} and its encoded forms such
+// as {{player_answer:base64}}, which level authors hide in the puzzle so
+// each player has their own answer to dig out.
+var playerAnswerPattern = regexp.MustCompile(`\{\{player_answer(?::([a-z0-9]+))?\}\}`)
+
+// AnswerEncodings are the forms {{player_answer:<encoding>}} can take.
+var AnswerEncodings = map[string]func(string) string{
+	"base64":  func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"hex":     func(s string) string { return hex.EncodeToString([]byte(s)) },
+	"binary":  encodeBinary,
+	"reverse": reverseString,
+	"rot13":   rot13,
+}
+
+// dynamicValueLength is how many hex characters of each HMAC are kept for
+// tokens and answers.
+const dynamicValueLength = 16
+
+// DynamicAnswerConfig describes how a level's answer is checked. In dynamic
+// mode each player gets their own answer, the HMAC of their token under the
+// level secret, which the level content carries through {{player_answer}},
+// so one leaked answer only fits one player.
+type DynamicAnswerConfig struct {
+	LevelNumber int    `json:"levelNumber"`
+	Mode        string `json:"mode"`
+	Secret      string `json:"secret,omitempty"`
+}
+
+type ForeignAnswer struct {
+	UserEmail   string    `json:"userEmail"`
+	OwnerEmail  string    `json:"ownerEmail"`
+	LevelNumber int       `json:"levelNumber"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+func migrateDynamicAnswers() {
+	if !columnExists("levels", "answer_mode") {
+		db.Exec("ALTER TABLE levels ADD COLUMN answer_mode TEXT DEFAULT 'static'")
+	}
+	if !columnExists("levels", "answer_secret") {
+		db.Exec("ALTER TABLE levels ADD COLUMN answer_secret TEXT")
+	}
+}
+
+func GetDynamicAnswerConfig(levelNumber int) (*DynamicAnswerConfig, error) {
+	config := DynamicAnswerConfig{LevelNumber: levelNumber}
+	var mode, secret sql.NullString
+	err := db.QueryRow("SELECT answer_mode, answer_secret FROM levels WHERE level_number = ?", levelNumber).Scan(&mode, &secret)
+	if err != nil {
+		return nil, err
+	}
+	config.Mode = AnswerModeStatic
+	if mode.String == AnswerModeDynamic {
+		config.Mode = AnswerModeDynamic
+	}
+	config.Secret = secret.String
+	return &config, nil
+}
+
+// SetAnswerMode switches a level between static and dynamic answers. A
+// secret is generated the first time a level goes dynamic, or whenever
+// rotate is set, which invalidates every player's previous answer.
+func SetAnswerMode(levelNumber int, mode string, rotate bool) (*DynamicAnswerConfig, error) {
+	if mode != AnswerModeStatic && mode != AnswerModeDynamic {
+		return nil, fmt.Errorf("mode must be static or dynamic")
+	}
+
+	current, err := GetDynamicAnswerConfig(levelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := current.Secret
+	if mode == AnswerModeDynamic && (secret == "" || rotate) {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	_, err = db.Exec("UPDATE levels SET answer_mode = ?, answer_secret = ? WHERE level_number = ?", mode, secret, levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	return GetDynamicAnswerConfig(levelNumber)
+}
+
+func dynamicHMAC(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))[:dynamicValueLength]
+}
+
+// PlayerToken is the per-player value shown in place of {{player_token}}.
+func PlayerToken(secret string, levelNumber int, userEmail string) string {
+	return dynamicHMAC(secret, fmt.Sprintf("token:%d:%s", levelNumber, strings.ToLower(userEmail)))
+}
+
+// DynamicAnswer is the answer a player must submit on a dynamic level: the
+// HMAC of their token keyed by the level secret. Players learn it from the
+// {{player_answer}} variable in the level content.
+func DynamicAnswer(secret string, levelNumber int, userEmail string) string {
+	return dynamicHMAC(secret, PlayerToken(secret, levelNumber, userEmail))
+}
+
+// expectedAnswer returns the answer a player must submit for a level given
+// its stored answer, mode and secret.
+func expectedAnswer(levelNumber int, answer string, mode, secret sql.NullString, userEmail string) string {
+	if mode.String == AnswerModeDynamic && secret.String != "" {
+		return DynamicAnswer(secret.String, levelNumber, userEmail)
+	}
+	return answer
+}
+
+func encodeBinary(s string) string {
+	bits := make([]string, len(s))
+	for i := 0; i < len(s); i++ {
+		bits[i] = fmt.Sprintf("%08b", s[i])
+	}
+	return strings.Join(bits, " ")
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func rot13(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, s)
+}
+
+// fillPlayerVariables replaces the dynamic level variables in content. An
+// unknown encoding is left as written so the author notices it in preview.
+func fillPlayerVariables(content, token, answer string) string {
+	content = strings.ReplaceAll(content, PlayerTokenVariable, token)
+	return playerAnswerPattern.ReplaceAllStringFunc(content, func(match string) string {
+		encoding := playerAnswerPattern.FindStringSubmatch(match)[1]
+		if encoding == "" {
+			return answer
+		}
+		if encode, ok := AnswerEncodings[encoding]; ok {
+			return encode(answer)
+		}
+		return match
+	})
+}
+
+// applyPlayerToken fills the viewer's token and answer into dynamic level
+// content.
+func applyPlayerToken(level *GameLevel, userEmail string) {
+	config, err := GetDynamicAnswerConfig(level.Number)
+	if err != nil || config.Mode != AnswerModeDynamic || config.Secret == "" {
+		return
+	}
+	token := PlayerToken(config.Secret, level.Number, userEmail)
+	answer := DynamicAnswer(config.Secret, level.Number, userEmail)
+	level.Markdown = fillPlayerVariables(level.Markdown, token, answer)
+	level.Description = fillPlayerVariables(level.Description, token, answer)
+	level.HTML = fillPlayerVariables(level.HTML, token, answer)
+	answerOwners.remember(level.Number, config.Secret, userEmail)
+}
+
+// answerOwnerIndex maps each dynamic level's answers back to the players
+// they were derived for, so a wrong guess is matched with one lookup.
+type answerOwnerIndex struct {
+	mu     sync.Mutex
+	levels map[int]*levelAnswerOwners
+}
+
+type levelAnswerOwners struct {
+	secret string
+	owners map[string]string
+}
+
+var answerOwners = answerOwnerIndex{levels: map[int]*levelAnswerOwners{}}
+
+// load returns the level's index, building it from the players who have
+// reached the level when it is missing or the secret has been rotated. The
+// caller holds mu.
+func (idx *answerOwnerIndex) load(levelNumber int, secret string) (*levelAnswerOwners, error) {
+	if level, ok := idx.levels[levelNumber]; ok && level.secret == secret {
+		return level, nil
+	}
+
+	rows, err := db.Query(`SELECT gmail FROM logins WHERE "on" >= ?`, levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	level := &levelAnswerOwners{secret: secret, owners: map[string]string{}}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		level.owners[DynamicAnswer(secret, levelNumber, email)] = email
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	idx.levels[levelNumber] = level
+	return level, nil
+}
+
+// remember adds a player whose answer has just been shown to them. Players
+// who reach the level later are only indexed once they view it, which is
+// the earliest their answer can be passed on.
+func (idx *answerOwnerIndex) remember(levelNumber int, secret, userEmail string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if level, ok := idx.levels[levelNumber]; ok && level.secret == secret {
+		level.owners[DynamicAnswer(secret, levelNumber, userEmail)] = userEmail
+	}
+}
+
+func (idx *answerOwnerIndex) owner(levelNumber int, secret, answer string) (string, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	level, err := idx.load(levelNumber, secret)
+	if err != nil {
+		return "", err
+	}
+	return level.owners[answer], nil
+}
+
+// recordForeignAnswer records a wrong answer on a dynamic level that matches
+// the answer derived for another player, which means it was passed on.
+func recordForeignAnswer(userEmail string, levelNumber int, answer, secret string) {
+	owner, err := answerOwners.owner(levelNumber, secret, answer)
+	if err != nil {
+		log.Printf("ERROR: Failed to check foreign answer for %s: %v", userEmail, err)
+		return
+	}
+	if owner == "" || owner == userEmail {
+		return
+	}
+
+	log.Printf("WARNING: %s submitted the level %d answer derived for %s", userEmail, levelNumber, owner)
+	_, err = db.Exec("INSERT INTO foreign_answers (user_email, owner_email, level_number, submitted_at) VALUES (?, ?, ?, ?)",
+		userEmail, owner, levelNumber, time.Now().UTC())
+	if err != nil {
+		log.Printf("ERROR: Failed to record foreign answer for %s: %v", userEmail, err)
+	}
+}
+
+func GetForeignAnswers() ([]ForeignAnswer, error) {
+	rows, err := db.Query("SELECT user_email, owner_email, level_number, submitted_at FROM foreign_answers ORDER BY submitted_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := []ForeignAnswer{}
+	for rows.Next() {
+		var fa ForeignAnswer
+		if err := rows.Scan(&fa.UserEmail, &fa.OwnerEmail, &fa.LevelNumber, &fa.SubmittedAt); err != nil {
+			return nil, err
+		}
+		answers = append(answers, fa)
+	}
+	return answers, nil
+}
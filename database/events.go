@@ -0,0 +1,442 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"intrasudo25/config"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	EventRegistrationOpen   = "open"
+	EventRegistrationInvite = "invite"
+	EventRegistrationClosed = "closed"
+)
+
+var eventSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Event is one competition run by this deployment, such as a qualifier and a
+// final, or junior and senior rounds. Each event owns a contiguous block of
+// level numbers and its own schedule; enrolled players progress only through
+// their event's levels.
+type Event struct {
+	ID           int       `json:"id"`
+	Slug         string    `json:"slug"`
+	Name         string    `json:"name"`
+	FirstLevel   int       `json:"firstLevel"`
+	LastLevel    int       `json:"lastLevel"`
+	StartsAt     time.Time `json:"startsAt"`
+	EndsAt       time.Time `json:"endsAt"`
+	Registration string    `json:"registration"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Enrolled     int       `json:"enrolled"`
+}
+
+type EventEnrollment struct {
+	EventID    int       `json:"eventId"`
+	UserEmail  string    `json:"userEmail"`
+	EnrolledBy string    `json:"enrolledBy"`
+	EnrolledAt time.Time `json:"enrolledAt"`
+	On         int       `json:"on"`
+}
+
+// CompetitionWindow is the name and schedule a player is held to. Players
+// outside any event fall back to the deployment-wide countdown settings.
+type CompetitionWindow struct {
+	EventID  int       `json:"eventId,omitempty"`
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Enforced bool      `json:"enforced"`
+}
+
+func migrateEvents() {
+	if !columnExists("announcements", "event_id") {
+		db.Exec("ALTER TABLE announcements ADD COLUMN event_id INTEGER")
+	}
+}
+
+func validateEvent(e *Event) error {
+	e.Slug = strings.ToLower(strings.TrimSpace(e.Slug))
+	e.Name = strings.TrimSpace(e.Name)
+	if !eventSlugPattern.MatchString(e.Slug) {
+		return fmt.Errorf("slug may only contain lowercase letters, digits and dashes")
+	}
+	if e.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if e.FirstLevel < 1 || e.LastLevel < e.FirstLevel {
+		return fmt.Errorf("level range must start at 1 or above and end at or after its first level")
+	}
+	if !e.EndsAt.After(e.StartsAt) {
+		return fmt.Errorf("event must end after it starts")
+	}
+	switch e.Registration {
+	case EventRegistrationOpen, EventRegistrationInvite, EventRegistrationClosed:
+	case "":
+		e.Registration = EventRegistrationOpen
+	default:
+		return fmt.Errorf("registration must be open, invite or closed")
+	}
+
+	var overlapping string
+	err := db.QueryRow("SELECT slug FROM events WHERE id != ? AND first_level <= ? AND last_level >= ? LIMIT 1",
+		e.ID, e.LastLevel, e.FirstLevel).Scan(&overlapping)
+	if err == nil {
+		return fmt.Errorf("levels %d-%d overlap event %s", e.FirstLevel, e.LastLevel, overlapping)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+func CreateEvent(e *Event) (int, error) {
+	if err := validateEvent(e); err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(`INSERT INTO events (slug, name, first_level, last_level, starts_at, ends_at, registration, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Slug, e.Name, e.FirstLevel, e.LastLevel, e.StartsAt.UTC(), e.EndsAt.UTC(), e.Registration, time.Now().UTC())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, fmt.Errorf("an event with slug %s already exists", e.Slug)
+		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	// Players outside any event may now be past the end of their range
+	alignAllPlayerLevels()
+	return int(id), nil
+}
+
+func UpdateEvent(e *Event) error {
+	if err := validateEvent(e); err != nil {
+		return err
+	}
+
+	result, err := db.Exec(`UPDATE events SET slug = ?, name = ?, first_level = ?, last_level = ?, starts_at = ?, ends_at = ?, registration = ?
+		WHERE id = ?`,
+		e.Slug, e.Name, e.FirstLevel, e.LastLevel, e.StartsAt.UTC(), e.EndsAt.UTC(), e.Registration, e.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("an event with slug %s already exists", e.Slug)
+		}
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	alignAllPlayerLevels()
+	return nil
+}
+
+// DeleteEvent removes an event with no enrolled players. Its announcements
+// are retired with it.
+func DeleteEvent(id int) error {
+	var enrolled int
+	if err := db.QueryRow("SELECT COUNT(*) FROM event_enrollments WHERE event_id = ?", id).Scan(&enrolled); err != nil {
+		return err
+	}
+	if enrolled > 0 {
+		return fmt.Errorf("event still has %d enrolled players", enrolled)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM events WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec("UPDATE announcements SET active = FALSE WHERE event_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	alignAllPlayerLevels()
+	return nil
+}
+
+const eventColumns = `e.id, e.slug, e.name, e.first_level, e.last_level, e.starts_at, e.ends_at, e.registration, e.created_at,
+	(SELECT COUNT(*) FROM event_enrollments en WHERE en.event_id = e.id)`
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var e Event
+	err := row.Scan(&e.ID, &e.Slug, &e.Name, &e.FirstLevel, &e.LastLevel, &e.StartsAt, &e.EndsAt, &e.Registration, &e.CreatedAt, &e.Enrolled)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func GetEvent(id int) (*Event, error) {
+	return scanEvent(db.QueryRow("SELECT "+eventColumns+" FROM events e WHERE e.id = ?", id))
+}
+
+func GetEventBySlug(slug string) (*Event, error) {
+	return scanEvent(db.QueryRow("SELECT "+eventColumns+" FROM events e WHERE e.slug = ?", strings.ToLower(slug)))
+}
+
+func GetEvents() ([]Event, error) {
+	rows, err := db.Query("SELECT " + eventColumns + " FROM events e ORDER BY e.starts_at, e.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, nil
+}
+
+// GetPlayerEvent returns the event the player most recently enrolled in, or
+// nil when they are not enrolled anywhere.
+func GetPlayerEvent(userEmail string) (*Event, error) {
+	e, err := scanEvent(db.QueryRow("SELECT "+eventColumns+` FROM events e
+		JOIN event_enrollments en ON en.event_id = e.id
+		WHERE en.user_email = ? ORDER BY en.enrolled_at DESC LIMIT 1`, userEmail))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// EnrollPlayer enrolls a player in an event and moves them onto the event's
+// first level. A player can only be enrolled in one running event at a time.
+func EnrollPlayer(eventID int, userEmail, enrolledBy string) error {
+	event, err := GetEvent(eventID)
+	if err != nil {
+		return err
+	}
+
+	current, err := GetPlayerEvent(userEmail)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		if current.ID == eventID {
+			return fmt.Errorf("already enrolled in %s", event.Name)
+		}
+		if time.Now().Before(current.EndsAt) {
+			return fmt.Errorf("already enrolled in %s until it ends", current.Name)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO event_enrollments (event_id, user_email, enrolled_by, enrolled_at) VALUES (?, ?, ?, ?)",
+		eventID, userEmail, enrolledBy, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE logins SET \"on\" = ? WHERE gmail = ? AND (\"on\" < ? OR \"on\" > ?)",
+		event.FirstLevel, userEmail, event.FirstLevel, event.LastLevel+1)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM logins WHERE gmail = ?)", userEmail).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	refreshLeaderboardPlayer(userEmail)
+	return nil
+}
+
+// UnenrollPlayer removes a player from an event and moves them onto the
+// start of their new level range if they are outside it.
+func UnenrollPlayer(eventID int, userEmail string) error {
+	result, err := db.Exec("DELETE FROM event_enrollments WHERE event_id = ? AND user_email = ?", eventID, userEmail)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return alignPlayerLevel(userEmail)
+}
+
+func GetEventEnrollments(eventID int) ([]EventEnrollment, error) {
+	rows, err := db.Query(`SELECT en.event_id, en.user_email, en.enrolled_by, en.enrolled_at, COALESCE(l."on", 0) FROM event_enrollments en
+		LEFT JOIN logins l ON l.gmail = en.user_email
+		WHERE en.event_id = ? ORDER BY en.enrolled_at`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []EventEnrollment{}
+	for rows.Next() {
+		var en EventEnrollment
+		if err := rows.Scan(&en.EventID, &en.UserEmail, &en.EnrolledBy, &en.EnrolledAt, &en.On); err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, en)
+	}
+	return enrollments, nil
+}
+
+// EventIDForLevel returns the event owning a level number, or 0 when the
+// level belongs to no event.
+func EventIDForLevel(levelNumber int) int {
+	var id int
+	err := db.QueryRow("SELECT id FROM events WHERE ? BETWEEN first_level AND last_level", levelNumber).Scan(&id)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+func DefaultCompetitionWindow() CompetitionWindow {
+	return CompetitionWindow{
+		Name:     config.GetEventName(),
+		Start:    config.GetCompetitionStartTime(),
+		End:      config.GetCompetitionEndTime(),
+		Enforced: config.IsCountdownEnabled(),
+	}
+}
+
+// GetPlayerWindow returns the schedule of the player's event, which is
+// always enforced, or the deployment-wide window when they have none.
+func GetPlayerWindow(userEmail string) CompetitionWindow {
+	event, err := GetPlayerEvent(userEmail)
+	if err != nil || event == nil {
+		return DefaultCompetitionWindow()
+	}
+	return CompetitionWindow{
+		EventID:  event.ID,
+		Name:     event.Name,
+		Start:    event.StartsAt,
+		End:      event.EndsAt,
+		Enforced: true,
+	}
+}
+
+// playerLevelRange returns the level numbers a player may progress through.
+// Enrolled players are confined to their event; everyone else plays the
+// levels below the first event-owned block, which is every level when no
+// events exist.
+func playerLevelRange(userEmail string) (first, last int, inEvent bool, err error) {
+	event, err := GetPlayerEvent(userEmail)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if event != nil {
+		return event.FirstLevel, event.LastLevel, true, nil
+	}
+
+	var lowest sql.NullInt64
+	if err := db.QueryRow("SELECT MIN(first_level) FROM events").Scan(&lowest); err != nil {
+		return 0, 0, false, err
+	}
+	if !lowest.Valid {
+		return 1, math.MaxInt32, false, nil
+	}
+	return 1, int(lowest.Int64) - 1, false, nil
+}
+
+// PlayerReachedLevel reports whether a player on level on has reached
+// levelNumber: it must be in their range and no later than their level.
+func PlayerReachedLevel(userEmail string, on, levelNumber int) (bool, error) {
+	first, _, _, err := playerLevelRange(userEmail)
+	if err != nil {
+		return false, err
+	}
+	return first <= levelNumber && levelNumber <= on, nil
+}
+
+// CreateEventAnnouncement posts an announcement visible only to an event's
+// players; eventID 0 posts a global announcement.
+func CreateEventAnnouncement(eventID int, heading string) error {
+	var event interface{}
+	if eventID > 0 {
+		event = eventID
+	}
+	_, err := db.Exec("INSERT INTO announcements (heading, event_id) VALUES (?, ?)", heading, event)
+	return err
+}
+
+// GetAnnouncementsForEvent returns global announcements plus those posted
+// to the given event.
+func GetAnnouncementsForEvent(eventID int) ([]Announcement, error) {
+	rows, err := db.Query(`SELECT id, heading, created_at, updated_at, active, event_id FROM announcements
+		WHERE active = TRUE AND (event_id IS NULL OR event_id = ?) ORDER BY created_at DESC`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := []Announcement{}
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, *a)
+	}
+	return announcements, nil
+}
+
+func scanAnnouncement(row rowScanner) (*Announcement, error) {
+	var a Announcement
+	var eventID sql.NullInt64
+	err := row.Scan(&a.ID, &a.Heading, &a.CreatedAt, &a.UpdatedAt, &a.Active, &eventID)
+	if err != nil {
+		return nil, err
+	}
+	if eventID.Valid {
+		id := int(eventID.Int64)
+		a.EventID = &id
+	}
+	return &a, nil
+}
@@ -0,0 +1,408 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const submissionDir = "./data/submissions"
+
+// How an uploaded file is judged.
+const (
+	UploadCheckHash      = "hash"
+	UploadCheckValidator = "validator"
+	UploadCheckManual    = "manual"
+)
+
+const (
+	FileSubmissionPending  = "pending"
+	FileSubmissionAccepted = "accepted"
+	FileSubmissionRejected = "rejected"
+)
+
+// MaxUploadSize caps every level's upload limit.
+const MaxUploadSize = 25 << 20
+
+const defaultUploadSize = 5 << 20
+
+var ErrAlreadyReviewed = errors.New("submission has already been reviewed")
+
+var (
+	sha256Pattern   = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	mimeTypePattern = regexp.MustCompile(`^[a-z0-9.+-]+/([a-z0-9.+-]+|\*)$`)
+)
+
+// UploadConfig turns a level into one solved by uploading a file. Hashes
+// are the SHA-256 digests accepted in hash mode; validator mode hands the
+// file to the level's validator and manual mode queues it for an admin.
+type UploadConfig struct {
+	LevelNumber  int      `json:"levelNumber"`
+	Check        string   `json:"check"`
+	MaxSize      int64    `json:"maxSize"`
+	AllowedTypes []string `json:"allowedTypes"`
+	Hashes       []string `json:"hashes,omitempty"`
+}
+
+type FileSubmission struct {
+	ID          int        `json:"id"`
+	UserEmail   string     `json:"userEmail"`
+	LevelNumber int        `json:"levelNumber"`
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"contentType"`
+	SHA256      string     `json:"sha256"`
+	Size        int64      `json:"size"`
+	Status      string     `json:"status"`
+	ReviewedBy  string     `json:"reviewedBy,omitempty"`
+	ReviewNote  string     `json:"reviewNote,omitempty"`
+	SubmittedAt time.Time  `json:"submittedAt"`
+	ReviewedAt  *time.Time `json:"reviewedAt,omitempty"`
+}
+
+func GetUploadConfig(levelNumber int) (*UploadConfig, error) {
+	config := UploadConfig{LevelNumber: levelNumber}
+	var types string
+	var hashes sql.NullString
+	err := db.QueryRow("SELECT check_mode, max_size, allowed_types, hashes FROM level_upload_configs WHERE level_number = ?", levelNumber).
+		Scan(&config.Check, &config.MaxSize, &types, &hashes)
+	if err != nil {
+		return nil, err
+	}
+	config.AllowedTypes = splitList(types)
+	config.Hashes = splitList(hashes.String)
+	return &config, nil
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// SetUploadConfig makes a level take file uploads, replacing any earlier
+// upload settings. A zero MaxSize means the default limit.
+func SetUploadConfig(config UploadConfig) (*UploadConfig, error) {
+	switch config.Check {
+	case UploadCheckHash, UploadCheckValidator, UploadCheckManual:
+	default:
+		return nil, fmt.Errorf("check must be hash, validator or manual")
+	}
+
+	if config.MaxSize == 0 {
+		config.MaxSize = defaultUploadSize
+	}
+	if config.MaxSize < 0 || config.MaxSize > MaxUploadSize {
+		return nil, fmt.Errorf("maxSize must be between 1 and %d bytes", MaxUploadSize)
+	}
+
+	if len(config.AllowedTypes) == 0 {
+		return nil, fmt.Errorf("at least one allowed type is required")
+	}
+	for i, t := range config.AllowedTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !mimeTypePattern.MatchString(t) {
+			return nil, fmt.Errorf("invalid content type %q", t)
+		}
+		config.AllowedTypes[i] = t
+	}
+
+	for i, h := range config.Hashes {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !sha256Pattern.MatchString(h) {
+			return nil, fmt.Errorf("invalid SHA-256 digest %q", h)
+		}
+		config.Hashes[i] = h
+	}
+	if config.Check == UploadCheckHash && len(config.Hashes) == 0 {
+		return nil, fmt.Errorf("hash mode needs at least one accepted digest")
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM levels WHERE level_number = ?)", config.LevelNumber).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	_, err := db.Exec(`INSERT INTO level_upload_configs (level_number, check_mode, max_size, allowed_types, hashes) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(level_number) DO UPDATE SET check_mode = excluded.check_mode, max_size = excluded.max_size,
+			allowed_types = excluded.allowed_types, hashes = excluded.hashes`,
+		config.LevelNumber, config.Check, config.MaxSize, strings.Join(config.AllowedTypes, ","), strings.Join(config.Hashes, ","))
+	if err != nil {
+		return nil, err
+	}
+	return GetUploadConfig(config.LevelNumber)
+}
+
+// DeleteUploadConfig turns a level back into a text-answer level. Stored
+// submissions are kept.
+func DeleteUploadConfig(levelNumber int) error {
+	_, err := db.Exec("DELETE FROM level_upload_configs WHERE level_number = ?", levelNumber)
+	return err
+}
+
+// AllowsType reports whether a detected content type is accepted. Entries
+// like image/* accept a whole family.
+func (c *UploadConfig) AllowsType(contentType string) bool {
+	for _, allowed := range c.AllowedTypes {
+		if allowed == contentType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// playerUploadView is the upload config as shown to players, without the
+// accepted digests.
+func playerUploadView(config *UploadConfig) *UploadConfig {
+	view := *config
+	view.Hashes = nil
+	return &view
+}
+
+func hasPendingFileSubmission(userEmail string, levelNumber int) bool {
+	var pending bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM file_submissions WHERE user_email = ? AND level_number = ? AND status = ?)",
+		userEmail, levelNumber, FileSubmissionPending).Scan(&pending)
+	return pending
+}
+
+// SubmitFile stores a player's upload for the level they are on and judges
+// it according to the level's upload settings. Files the validator cannot
+// judge fall back to the review queue.
+func SubmitFile(userEmail string, levelNumber int, filename, contentType string, src io.Reader) (*SubmitAnswerResult, error) {
+	var currentLevel int
+	err := db.QueryRow("SELECT \"on\" FROM logins WHERE gmail = ?", userEmail).Scan(&currentLevel)
+	if err != nil {
+		return nil, err
+	}
+	if currentLevel != levelNumber {
+		return &SubmitAnswerResult{Correct: false, Message: "Validating...", ReloadPage: true}, nil
+	}
+
+	first, last, _, err := playerLevelRange(userEmail)
+	if err != nil {
+		return nil, err
+	}
+	var active bool
+	err = db.QueryRow("SELECT active FROM levels WHERE level_number = ?", levelNumber).Scan(&active)
+	if err != nil || !active || levelNumber < first || levelNumber > last {
+		return &SubmitAnswerResult{Correct: false, Message: "Level not found"}, nil
+	}
+
+	config, err := GetUploadConfig(levelNumber)
+	if err == sql.ErrNoRows {
+		return &SubmitAnswerResult{Correct: false, Message: "This level does not take file uploads."}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !config.AllowsType(contentType) {
+		return &SubmitAnswerResult{Correct: false, Message: fmt.Sprintf("Files of type %s are not accepted for this level.", contentType)}, nil
+	}
+	if hasPendingFileSubmission(userEmail, levelNumber) {
+		return &SubmitAnswerResult{Correct: false, Message: "Your previous upload is still awaiting review."}, nil
+	}
+
+	sum, size, err := storeBlob(submissionDir, io.LimitReader(src, config.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > config.MaxSize {
+		var refs int
+		if err := db.QueryRow("SELECT COUNT(*) FROM file_submissions WHERE sha256 = ?", sum).Scan(&refs); err == nil && refs == 0 {
+			os.Remove(filepath.Join(submissionDir, sum))
+		}
+		return &SubmitAnswerResult{Correct: false, Message: fmt.Sprintf("File exceeds the %d byte limit for this level.", config.MaxSize)}, nil
+	}
+
+	status := FileSubmissionPending
+	switch config.Check {
+	case UploadCheckHash:
+		status = FileSubmissionRejected
+		for _, h := range config.Hashes {
+			if h == sum {
+				status = FileSubmissionAccepted
+				break
+			}
+		}
+	case UploadCheckValidator:
+		validator, err := GetLevelValidator(levelNumber)
+		if err != nil {
+			return nil, err
+		}
+		path, _ := filepath.Abs(filepath.Join(submissionDir, sum))
+		verdict, err := matchAnswer(validator, Submission{LevelNumber: levelNumber, UserEmail: userEmail, Answer: sum, File: path})
+		if errors.Is(err, ErrCheckerUnavailable) {
+			log.Printf("WARNING: Could not check upload from %s on level %d, queueing for review: %v", userEmail, levelNumber, err)
+		} else if err != nil {
+			return nil, err
+		} else if verdict.Correct {
+			status = FileSubmissionAccepted
+		} else {
+			status = FileSubmissionRejected
+		}
+	}
+
+	now := time.Now().UTC()
+	var reviewedAt interface{}
+	if status != FileSubmissionPending {
+		reviewedAt = now
+	}
+	_, err = db.Exec(`INSERT INTO file_submissions (user_email, level_number, filename, content_type, sha256, size, status, submitted_at, reviewed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userEmail, levelNumber, filename, contentType, sum, size, status, now, reviewedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO: %s uploaded %s (%d bytes, %s) for level %d: %s", userEmail, filename, size, sum, levelNumber, status)
+	recordSubmission(userEmail, levelNumber, "file:"+sum, status == FileSubmissionAccepted)
+
+	switch status {
+	case FileSubmissionAccepted:
+		if err := completeLevel(userEmail, levelNumber, first, last); err != nil {
+			return nil, err
+		}
+		return &SubmitAnswerResult{Correct: true, Message: "Correct! Moving to next level..."}, nil
+	case FileSubmissionRejected:
+		return &SubmitAnswerResult{Correct: false, Message: "That file is not what this level is looking for. Try again!"}, nil
+	}
+	return &SubmitAnswerResult{Correct: false, Message: "Upload received. An admin will review it shortly."}, nil
+}
+
+const fileSubmissionColumns = `id, user_email, level_number, filename, content_type, sha256, size, status,
+	COALESCE(reviewed_by, ''), COALESCE(review_note, ''), submitted_at, reviewed_at`
+
+func scanFileSubmission(row rowScanner) (*FileSubmission, error) {
+	var fs FileSubmission
+	var reviewedAt sql.NullTime
+	err := row.Scan(&fs.ID, &fs.UserEmail, &fs.LevelNumber, &fs.Filename, &fs.ContentType, &fs.SHA256, &fs.Size, &fs.Status,
+		&fs.ReviewedBy, &fs.ReviewNote, &fs.SubmittedAt, &reviewedAt)
+	if err != nil {
+		return nil, err
+	}
+	if reviewedAt.Valid {
+		t := reviewedAt.Time
+		fs.ReviewedAt = &t
+	}
+	return &fs, nil
+}
+
+func GetFileSubmission(id int) (*FileSubmission, error) {
+	return scanFileSubmission(db.QueryRow("SELECT "+fileSubmissionColumns+" FROM file_submissions WHERE id = ?", id))
+}
+
+// GetFileSubmissions lists uploads, optionally filtered by status. Pending
+// uploads come oldest first so the review queue is worked in order.
+func GetFileSubmissions(status string) ([]FileSubmission, error) {
+	query := "SELECT " + fileSubmissionColumns + " FROM file_submissions"
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	if status == FileSubmissionPending {
+		query += " ORDER BY submitted_at ASC, id ASC"
+	} else {
+		query += " ORDER BY submitted_at DESC, id DESC"
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	submissions := []FileSubmission{}
+	for rows.Next() {
+		fs, err := scanFileSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, *fs)
+	}
+	return submissions, nil
+}
+
+// ReviewFileSubmission settles a pending upload. Accepting it completes the
+// level if the player is still on it.
+func ReviewFileSubmission(id int, accept bool, note, reviewer string) (*FileSubmission, error) {
+	status := FileSubmissionRejected
+	if accept {
+		status = FileSubmissionAccepted
+	}
+
+	result, err := db.Exec("UPDATE file_submissions SET status = ?, reviewed_by = ?, review_note = ?, reviewed_at = ? WHERE id = ? AND status = ?",
+		status, reviewer, note, time.Now().UTC(), id, FileSubmissionPending)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		if _, err := GetFileSubmission(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyReviewed
+	}
+
+	fs, err := GetFileSubmission(id)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: %s %s upload %d from %s for level %d", reviewer, status, id, fs.UserEmail, fs.LevelNumber)
+
+	if !accept {
+		message := fmt.Sprintf("Your upload for Level %d was not accepted.", fs.LevelNumber)
+		if note != "" {
+			message += " " + note
+		}
+		Create("notification", map[string]interface{}{
+			"userEmail": fs.UserEmail,
+			"message":   message,
+			"type":      "info",
+		})
+		return fs, nil
+	}
+
+	var currentLevel int
+	err = db.QueryRow("SELECT \"on\" FROM logins WHERE gmail = ?", fs.UserEmail).Scan(&currentLevel)
+	if err != nil {
+		return nil, err
+	}
+	if currentLevel != fs.LevelNumber {
+		Create("notification", map[string]interface{}{
+			"userEmail": fs.UserEmail,
+			"message":   fmt.Sprintf("Your upload for Level %d was accepted.", fs.LevelNumber),
+			"type":      "success",
+		})
+		return fs, nil
+	}
+
+	first, last, _, err := playerLevelRange(fs.UserEmail)
+	if err != nil {
+		return nil, err
+	}
+	if err := completeLevel(fs.UserEmail, fs.LevelNumber, first, last); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func FileSubmissionPath(fs *FileSubmission) string {
+	return filepath.Join(submissionDir, fs.SHA256)
+}
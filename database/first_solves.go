@@ -0,0 +1,216 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"intrasudo25/config"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FirstSolvePositions is how many solvers per level are tracked.
+const FirstSolvePositions = 3
+
+const firstSolveAnnounceKey = "first_solve_announcements"
+
+var firstSolveTitles = [FirstSolvePositions]string{"First blood", "Second solve", "Third solve"}
+
+// FirstSolve is one of the first players to solve a level.
+type FirstSolve struct {
+	LevelNumber int       `json:"levelNumber"`
+	Position    int       `json:"position"`
+	UserEmail   string    `json:"userEmail"`
+	SolvedAt    time.Time `json:"solvedAt"`
+}
+
+// migrateFirstSolves marks announcements still waiting to be relayed to
+// Discord. Announcements posted before this existed are never relayed.
+func migrateFirstSolves() {
+	if !columnExists("announcements", "discord_pending") {
+		db.Exec("ALTER TABLE announcements ADD COLUMN discord_pending BOOLEAN DEFAULT FALSE")
+	}
+}
+
+// recordFirstSolve claims the next free position on a level for a player.
+// Admins and players kept off the rankings never take a position. It
+// returns 0 when the player did not place.
+func recordFirstSolve(userEmail string, levelNumber int) (int, error) {
+	for _, admin := range config.GetAdminEmails() {
+		if strings.EqualFold(admin, userEmail) {
+			return 0, nil
+		}
+	}
+
+	now := time.Now().UTC()
+	var excluded bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM ("+excludedFromRankings+") x WHERE x.user_email = ?)", now, now, userEmail).Scan(&excluded)
+	if err != nil {
+		return 0, err
+	}
+	if excluded {
+		return 0, nil
+	}
+
+	// The position is counted and claimed in one statement so concurrent
+	// solves can not take the same place.
+	result, err := db.Exec(`INSERT OR IGNORE INTO first_solves (level_number, position, user_email, solved_at)
+		SELECT ?, COUNT(*) + 1, ?, ? FROM first_solves WHERE level_number = ? HAVING COUNT(*) < ?`,
+		levelNumber, userEmail, now, levelNumber, FirstSolvePositions)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, nil
+	}
+
+	var position int
+	err = db.QueryRow("SELECT position FROM first_solves WHERE level_number = ? AND user_email = ?", levelNumber, userEmail).Scan(&position)
+	if err != nil {
+		return 0, err
+	}
+	return position, nil
+}
+
+// trackFirstSolve records a solve's position, tells the player and posts
+// the announcement when enabled. Failures are logged so they never hold up
+// progression.
+func trackFirstSolve(userEmail string, levelNumber int) {
+	position, err := recordFirstSolve(userEmail, levelNumber)
+	if err != nil {
+		log.Printf("ERROR: Failed to record first solve of level %d by %s: %v", levelNumber, userEmail, err)
+		return
+	}
+	if position == 0 {
+		return
+	}
+	title := firstSolveTitles[position-1]
+	log.Printf("INFO: %s on level %d by %s", title, levelNumber, userEmail)
+
+	Create("notification", map[string]interface{}{
+		"userEmail": userEmail,
+		"message":   fmt.Sprintf("%s! You were solver #%d of Level %d", title, position, levelNumber),
+		"type":      "success",
+	})
+
+	// Announcing solves would give away the standings while they are frozen
+	if position > GetFirstSolveAnnouncements() || leaderboardFrozen() {
+		return
+	}
+	username := strings.Split(userEmail, "@")[0]
+	heading := fmt.Sprintf("%s on Level %d: %s", title, levelNumber, username)
+	if err := createRelayedAnnouncement(EventIDForLevel(levelNumber), heading); err != nil {
+		log.Printf("ERROR: Failed to announce first solve of level %d: %v", levelNumber, err)
+	}
+}
+
+// GetFirstSolveAnnouncements returns how many positions per level are
+// announced automatically; 0 means none.
+func GetFirstSolveAnnouncements() int {
+	result, err := Get("system_setting", map[string]interface{}{"key": firstSolveAnnounceKey})
+	if err != nil {
+		return 0
+	}
+	if setting, ok := result.(*SystemSetting); ok {
+		n, _ := strconv.Atoi(setting.Value)
+		return n
+	}
+	return 0
+}
+
+func SetFirstSolveAnnouncements(positions int) error {
+	if positions < 0 || positions > FirstSolvePositions {
+		return fmt.Errorf("positions must be between 0 and %d", FirstSolvePositions)
+	}
+	value := strconv.Itoa(positions)
+	_, err := Get("system_setting", map[string]interface{}{"key": firstSolveAnnounceKey})
+	if err != nil {
+		return Create("system_setting", map[string]interface{}{
+			"key":   firstSolveAnnounceKey,
+			"value": value,
+		})
+	}
+	return Update("system_setting", map[string]interface{}{"key": firstSolveAnnounceKey}, map[string]interface{}{
+		"value": value,
+	})
+}
+
+// GetFirstSolves lists the first solvers of every level, or of one level
+// when levelNumber is positive. Players since dropped from the rankings are
+// left out without moving anyone else up. Unless live, solves after a
+// leaderboard freeze are hidden until the reveal.
+func GetFirstSolves(levelNumber int, live bool) ([]FirstSolve, error) {
+	now := time.Now().UTC()
+	query := "SELECT level_number, position, user_email, solved_at FROM first_solves WHERE user_email NOT IN (" + excludedFromRankings + ")"
+	args := []interface{}{now, now}
+	if levelNumber > 0 {
+		query += " AND level_number = ?"
+		args = append(args, levelNumber)
+	}
+	if !live {
+		if freeze, err := GetLeaderboardFreeze(); err == nil && freeze.Frozen {
+			query += " AND solved_at <= ?"
+			args = append(args, freeze.FrozenAt.UTC())
+		}
+	}
+	query += " ORDER BY level_number, position"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	solves := []FirstSolve{}
+	for rows.Next() {
+		var s FirstSolve
+		if err := rows.Scan(&s.LevelNumber, &s.Position, &s.UserEmail, &s.SolvedAt); err != nil {
+			return nil, err
+		}
+		solves = append(solves, s)
+	}
+	return solves, nil
+}
+
+// createRelayedAnnouncement posts an announcement that is also queued for
+// the Discord bot.
+func createRelayedAnnouncement(eventID int, heading string) error {
+	var event interface{}
+	if eventID > 0 {
+		event = eventID
+	}
+	_, err := db.Exec("INSERT INTO announcements (heading, event_id, discord_pending) VALUES (?, ?, TRUE)", heading, event)
+	return err
+}
+
+// GetPendingDiscordAnnouncements returns announcements queued for Discord,
+// oldest first.
+func GetPendingDiscordAnnouncements() ([]Announcement, error) {
+	rows, err := db.Query("SELECT id, heading, created_at, updated_at, active, event_id FROM announcements WHERE discord_pending = TRUE AND active = TRUE ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := []Announcement{}
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, *a)
+	}
+	return announcements, nil
+}
+
+func MarkAnnouncementRelayed(id int) error {
+	result, err := db.Exec("UPDATE announcements SET discord_pending = FALSE WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
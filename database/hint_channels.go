@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Hint channels a level can deliver its hints through. Each channel has its
+// own per-level content and only reaches players currently on that level.
+const (
+	HintChannelHTMLComment = "html_comment"
+	HintChannelConsole     = "console"
+	HintChannelHeader      = "header"
+	HintChannelCookie      = "cookie"
+	HintChannelRobots      = "robots"
+)
+
+var HintChannels = []string{HintChannelHTMLComment, HintChannelConsole, HintChannelHeader, HintChannelCookie, HintChannelRobots}
+
+type LevelHintChannel struct {
+	LevelNumber int    `json:"levelNumber"`
+	Channel     string `json:"channel"`
+	Content     string `json:"content"`
+}
+
+func IsValidHintChannel(channel string) bool {
+	for _, c := range HintChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLevelHintChannels returns the configured channels for a level. Levels
+// that predate channel configuration fall back to their src_hint and
+// console_hint columns, ignoring console hints that merely copy the question.
+func GetLevelHintChannels(levelNumber int) ([]LevelHintChannel, error) {
+	rows, err := db.Query("SELECT level_number, channel, content FROM level_hint_channels WHERE level_number = ? ORDER BY channel", levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []LevelHintChannel{}
+	for rows.Next() {
+		var c LevelHintChannel
+		if err := rows.Scan(&c.LevelNumber, &c.Channel, &c.Content); err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	if len(channels) > 0 {
+		return channels, nil
+	}
+
+	var markdown, srcHint, consoleHint sql.NullString
+	err = db.QueryRow("SELECT markdown, src_hint, console_hint FROM levels WHERE level_number = ?", levelNumber).Scan(&markdown, &srcHint, &consoleHint)
+	if err == sql.ErrNoRows {
+		return channels, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if srcHint.String != "" {
+		channels = append(channels, LevelHintChannel{LevelNumber: levelNumber, Channel: HintChannelHTMLComment, Content: srcHint.String})
+	}
+	if consoleHint.String != "" && consoleHint.String != markdown.String {
+		channels = append(channels, LevelHintChannel{LevelNumber: levelNumber, Channel: HintChannelConsole, Content: consoleHint.String})
+	}
+	return channels, nil
+}
+
+// GetLevelHintChannel returns the content for one channel, or "" when the
+// level does not use it.
+func GetLevelHintChannel(levelNumber int, channel string) (string, error) {
+	channels, err := GetLevelHintChannels(levelNumber)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range channels {
+		if c.Channel == channel {
+			return c.Content, nil
+		}
+	}
+	return "", nil
+}
+
+// GetHintChannelsByType returns every level's content for one channel type.
+func GetHintChannelsByType(channel string) ([]LevelHintChannel, error) {
+	rows, err := db.Query("SELECT level_number, channel, content FROM level_hint_channels WHERE channel = ? ORDER BY level_number", channel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []LevelHintChannel{}
+	for rows.Next() {
+		var c LevelHintChannel
+		if err := rows.Scan(&c.LevelNumber, &c.Channel, &c.Content); err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	return channels, nil
+}
+
+// SetLevelHintChannels replaces a level's channel configuration. The legacy
+// src_hint and console_hint columns are kept in step for older readers.
+func SetLevelHintChannels(levelNumber int, channels []LevelHintChannel) error {
+	for _, c := range channels {
+		if !IsValidHintChannel(c.Channel) {
+			return fmt.Errorf("invalid hint channel: %s", c.Channel)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM level_hint_channels WHERE level_number = ?", levelNumber); err != nil {
+		return err
+	}
+
+	srcHint, consoleHint := "", ""
+	for _, c := range channels {
+		if c.Content == "" {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO level_hint_channels (level_number, channel, content) VALUES (?, ?, ?)", levelNumber, c.Channel, c.Content); err != nil {
+			return err
+		}
+		switch c.Channel {
+		case HintChannelHTMLComment:
+			srcHint = c.Content
+		case HintChannelConsole:
+			consoleHint = c.Content
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE levels SET src_hint = ?, console_hint = ? WHERE level_number = ?", srcHint, consoleHint, levelNumber); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetLevelHintChannel sets or clears a single channel, leaving the others
+// untouched.
+func SetLevelHintChannel(levelNumber int, channel, content string) error {
+	channels, err := GetLevelHintChannels(levelNumber)
+	if err != nil {
+		return err
+	}
+
+	updated := []LevelHintChannel{}
+	for _, c := range channels {
+		if c.Channel != channel {
+			updated = append(updated, c)
+		}
+	}
+	if content != "" {
+		updated = append(updated, LevelHintChannel{LevelNumber: levelNumber, Channel: channel, Content: content})
+	}
+	return SetLevelHintChannels(levelNumber, updated)
+}
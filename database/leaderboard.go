@@ -0,0 +1,321 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"intrasudo25/config"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a leaderboard cursor that was not
+// issued by GetLeaderboardPage.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Leaderboard page sizes.
+const (
+	DefaultLeaderboardLimit = 50
+	MaxLeaderboardLimit     = 200
+)
+
+// rankSuckers numbers an ordered leaderboard. Players are ordered by the
+// level they are ranked on, then score, then who reached that level first;
+// players equal on all three share a rank and are listed by email. Ranks
+// skip after a tie, so two players sharing 2nd are followed by 4th.
+func rankSuckers(suckers []Sucker) {
+	for i := range suckers {
+		if i > 0 && sameStanding(suckers[i-1], suckers[i]) {
+			suckers[i].Rank = suckers[i-1].Rank
+		} else {
+			suckers[i].Rank = i + 1
+		}
+	}
+}
+
+func sameStanding(a, b Sucker) bool {
+	if a.On != b.On || a.Score != b.Score {
+		return false
+	}
+	if a.ReachedAt == nil || b.ReachedAt == nil {
+		return a.ReachedAt == nil && b.ReachedAt == nil
+	}
+	return a.ReachedAt.Equal(*b.ReachedAt)
+}
+
+// rankingsWhere returns the WHERE clause keeping banned, disqualified and
+// admin players off the rankings, with its args.
+func rankingsWhere() (string, []interface{}) {
+	// Disqualified and banned players keep their data but drop out of the rankings
+	whereClause := " WHERE l.gmail NOT IN (" + excludedFromRankings + ")"
+	now := time.Now().UTC()
+	args := []interface{}{now, now}
+
+	if adminEmails := config.GetAdminEmails(); len(adminEmails) > 0 {
+		placeholders := make([]string, len(adminEmails))
+		for i, email := range adminEmails {
+			placeholders[i] = "?"
+			args = append(args, email)
+		}
+		whereClause += " AND l.gmail NOT IN (" + strings.Join(placeholders, ",") + ")"
+	}
+	return whereClause, args
+}
+
+// scanStandings reads gmail, score, ranked_on and reached-at rows in
+// leaderboard order and ranks them.
+func scanStandings(rows *sql.Rows) ([]Sucker, error) {
+	standings := []Sucker{}
+	for rows.Next() {
+		var s Sucker
+		var reachedAt sql.NullTime
+		if err := rows.Scan(&s.Gmail, &s.Score, &s.On, &reachedAt); err != nil {
+			return nil, err
+		}
+		if reachedAt.Valid {
+			t := reachedAt.Time
+			s.ReachedAt = &t
+		}
+		standings = append(standings, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rankSuckers(standings)
+	return standings, nil
+}
+
+// selectStandings ranks players straight from the database. filter is
+// appended to the WHERE clause, e.g. " AND l.gmail = ?", with its args.
+func selectStandings(filter string, filterArgs []interface{}, limit int) ([]Sucker, error) {
+	whereClause, args := rankingsWhere()
+	whereClause += filter
+	args = append(args, filterArgs...)
+
+	// Parts solved on the level a player is working on earn partial credit
+	query := `SELECT l.gmail, COALESCE(adj.points, 0) + COALESCE(pc.credit, 0) as score, MAX(l."on" + COALESCE(adj.levels, 0), 1) as ranked_on, lc.completed_at FROM logins l
+		LEFT JOIN level_completions lc ON l.gmail = lc.user_email AND lc.level_number = l."on" - 1
+		LEFT JOIN (SELECT user_email,
+			SUM(CASE WHEN kind = 'points' THEN amount ELSE 0 END) as points,
+			SUM(CASE WHEN kind = 'levels' THEN amount ELSE 0 END) as levels
+			FROM score_adjustments WHERE reverted = FALSE GROUP BY user_email) adj ON l.gmail = adj.user_email
+		LEFT JOIN (SELECT c.user_email, c.level_number, SUM(p.weight) as credit
+			FROM part_completions c JOIN level_parts p ON p.level_number = c.level_number AND p.name = c.part_name
+			WHERE c.practice = FALSE GROUP BY c.user_email, c.level_number) pc ON l.gmail = pc.user_email AND pc.level_number = l."on"` +
+		whereClause + ` ORDER BY ranked_on DESC, score DESC, lc.completed_at ASC, l.gmail ASC`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanStandings(rows)
+}
+
+// selectEventStandings ranks an event's players by the levels they
+// completed in the event's range, so a player's later events, resets
+// elsewhere or changes to their current level leave it untouched.
+func selectEventStandings(event *Event, limit int) ([]Sucker, error) {
+	whereClause, whereArgs := rankingsWhere()
+	args := []interface{}{event.FirstLevel, event.ID, event.FirstLevel, event.LastLevel, event.FirstLevel}
+	args = append(args, whereArgs...)
+
+	// A player is on the level after the last one they completed in range
+	query := `SELECT l.gmail, COALESCE(adj.points, 0) + COALESCE(pc.credit, 0) as score,
+			MAX(COALESCE(prog.solved + 1, ?) + COALESCE(adj.levels, 0), 1) as ranked_on, lc.completed_at
+		FROM logins l
+		JOIN event_enrollments en ON en.user_email = l.gmail AND en.event_id = ?
+		LEFT JOIN (SELECT user_email, MAX(level_number) as solved FROM level_completions
+			WHERE level_number BETWEEN ? AND ? GROUP BY user_email) prog ON l.gmail = prog.user_email
+		LEFT JOIN level_completions lc ON l.gmail = lc.user_email AND lc.level_number = prog.solved
+		LEFT JOIN (SELECT user_email,
+			SUM(CASE WHEN kind = 'points' THEN amount ELSE 0 END) as points,
+			SUM(CASE WHEN kind = 'levels' THEN amount ELSE 0 END) as levels
+			FROM score_adjustments WHERE reverted = FALSE GROUP BY user_email) adj ON l.gmail = adj.user_email
+		LEFT JOIN (SELECT c.user_email, c.level_number, SUM(p.weight) as credit
+			FROM part_completions c JOIN level_parts p ON p.level_number = c.level_number AND p.name = c.part_name
+			WHERE c.practice = FALSE GROUP BY c.user_email, c.level_number) pc
+			ON l.gmail = pc.user_email AND pc.level_number = COALESCE(prog.solved + 1, ?)` +
+		whereClause + ` ORDER BY ranked_on DESC, score DESC, lc.completed_at ASC, l.gmail ASC`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanStandings(rows)
+}
+
+// LeaderboardQuery selects a page of the leaderboard. Search matches the
+// part of a player's email before the @. Cursor is the NextCursor of the
+// previous page. Live skips the freeze snapshot, for admins.
+type LeaderboardQuery struct {
+	EventID int
+	Search  string
+	Cursor  string
+	Limit   int
+	Live    bool
+}
+
+// LeaderboardPage is one page of ranked players. Ranks are always those of
+// the full leaderboard, also when searching. NextCursor is empty on the
+// last page.
+type LeaderboardPage struct {
+	Entries    []Sucker
+	Total      int
+	NextCursor string
+}
+
+// GetLeaderboard returns the whole ranked leaderboard, optionally for one
+// event's players only.
+func GetLeaderboard(eventID int) ([]Sucker, error) {
+	params := map[string]interface{}{"limit": 0}
+	if eventID > 0 {
+		params["eventId"] = eventID
+	}
+	result, err := Get("leaderboard", params)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]Sucker), nil
+}
+
+// leaderboardCursor is the standing of the last player on a page. It is
+// kept instead of an offset so a page resumes at the right place even when
+// players ahead have moved or dropped off.
+type leaderboardCursor struct {
+	On        uint   `json:"o"`
+	Score     int    `json:"s"`
+	ReachedAt *int64 `json:"r,omitempty"`
+	Gmail     string `json:"g"`
+}
+
+func encodeLeaderboardCursor(s Sucker) string {
+	c := leaderboardCursor{On: s.On, Score: s.Score, Gmail: s.Gmail}
+	if s.ReachedAt != nil {
+		n := s.ReachedAt.UnixNano()
+		c.ReachedAt = &n
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLeaderboardCursor(cursor string) (*leaderboardCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c leaderboardCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Gmail == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// after reports whether a player sorts after the cursor, following the
+// leaderboard order. A missing arrival time sorts first, as in SQL.
+func (c *leaderboardCursor) after(s Sucker) bool {
+	if s.On != c.On {
+		return s.On < c.On
+	}
+	if s.Score != c.Score {
+		return s.Score < c.Score
+	}
+	switch {
+	case c.ReachedAt == nil && s.ReachedAt != nil:
+		return true
+	case c.ReachedAt != nil && s.ReachedAt == nil:
+		return false
+	case c.ReachedAt != nil && s.ReachedAt.UnixNano() != *c.ReachedAt:
+		return s.ReachedAt.UnixNano() > *c.ReachedAt
+	}
+	return s.Gmail > c.Gmail
+}
+
+// GetLeaderboardPage returns the players after the cursor that match the
+// search.
+func GetLeaderboardPage(q LeaderboardQuery) (*LeaderboardPage, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLeaderboardLimit
+	}
+	if q.Limit > MaxLeaderboardLimit {
+		q.Limit = MaxLeaderboardLimit
+	}
+
+	var cursor *leaderboardCursor
+	if q.Cursor != "" {
+		var err error
+		if cursor, err = decodeLeaderboardCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	all, err := GetStandings(q.EventID, q.Live)
+	if err != nil {
+		return nil, err
+	}
+
+	search := strings.ToLower(strings.TrimSpace(q.Search))
+	matches := make([]Sucker, 0, len(all))
+	for _, s := range all {
+		if search == "" || strings.Contains(strings.ToLower(strings.Split(s.Gmail, "@")[0]), search) {
+			matches = append(matches, s)
+		}
+	}
+
+	start := 0
+	if cursor != nil {
+		start = len(matches)
+		for i, s := range matches {
+			if cursor.after(s) {
+				start = i
+				break
+			}
+		}
+	}
+
+	end := start + q.Limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	page := &LeaderboardPage{Entries: matches[start:end], Total: len(matches)}
+	if end < len(matches) {
+		page.NextCursor = encodeLeaderboardCursor(matches[end-1])
+	}
+	return page, nil
+}
+
+// GetLeaderboardAround finds a player on the leaderboard along with up to
+// radius players on either side. The player is nil when they are not
+// ranked, e.g. admins and disqualified players.
+func GetLeaderboardAround(userEmail string, eventID, radius int, live bool) (*Sucker, []Sucker, int, error) {
+	all, err := GetStandings(eventID, live)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	for i, s := range all {
+		if s.Gmail != userEmail {
+			continue
+		}
+		from, to := i-radius, i+radius+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(all) {
+			to = len(all)
+		}
+		me := s
+		return &me, all[from:to], len(all), nil
+	}
+	return nil, nil, len(all), nil
+}
@@ -0,0 +1,293 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// leaderboardCache keeps the global standings in memory so leaderboard
+// reads never reach SQLite. Writers refresh just the players they touched;
+// anything that can move many players at once reloads it.
+type leaderboardCache struct {
+	// write serialises refreshes so an older row can not land after a
+	// newer one.
+	write sync.Mutex
+
+	mu        sync.RWMutex
+	loaded    bool
+	standings []Sucker
+	// nextExpiry is when the next timed ban or disqualification lapses,
+	// bringing a player back onto the leaderboard.
+	nextExpiry *time.Time
+}
+
+var standingsCache leaderboardCache
+
+// cachedValue holds one value read alongside the leaderboard, such as the
+// freeze state, until a write to it invalidates it. A load racing an
+// invalidation is not kept, so a stale value can not outlive the write.
+type cachedValue[T any] struct {
+	mu      sync.Mutex
+	loaded  bool
+	version uint64
+	value   T
+}
+
+var (
+	freezeCache            cachedValue[LeaderboardFreeze]
+	maxLevelCache          cachedValue[int]
+	leaderboardBadgesCache cachedValue[map[string][]PlayerBadge]
+)
+
+// get returns the cached value, loading it first if needed. The value is
+// shared, so callers must not modify it.
+func (c *cachedValue[T]) get(load func() (T, error)) (T, error) {
+	c.mu.Lock()
+	if c.loaded {
+		value := c.value
+		c.mu.Unlock()
+		return value, nil
+	}
+	version := c.version
+	c.mu.Unlock()
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	if c.version == version {
+		c.value = value
+		c.loaded = true
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+func (c *cachedValue[T]) invalidate() {
+	c.mu.Lock()
+	var zero T
+	c.value = zero
+	c.loaded = false
+	c.version++
+	c.mu.Unlock()
+}
+
+// get returns a copy of the cached standings, or false before the cache
+// has been loaded.
+func (c *leaderboardCache) get() ([]Sucker, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return nil, false
+	}
+	return append([]Sucker(nil), c.standings...), true
+}
+
+// standingBefore reports whether a sorts ahead of b, following the ORDER BY
+// of selectStandings. A missing arrival time sorts first, as in SQL.
+func standingBefore(a, b Sucker) bool {
+	if a.On != b.On {
+		return a.On > b.On
+	}
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	switch {
+	case a.ReachedAt == nil && b.ReachedAt != nil:
+		return true
+	case a.ReachedAt != nil && b.ReachedAt == nil:
+		return false
+	case a.ReachedAt != nil && !a.ReachedAt.Equal(*b.ReachedAt):
+		return a.ReachedAt.Before(*b.ReachedAt)
+	}
+	return a.Gmail < b.Gmail
+}
+
+// LoadLeaderboardCache fills the cache from a full recomputation. It is
+// called at startup and whenever many players may have moved.
+func LoadLeaderboardCache() error {
+	standingsCache.write.Lock()
+	defer standingsCache.write.Unlock()
+
+	standings, err := selectStandings("", nil, 0)
+	if err != nil {
+		return err
+	}
+	nextExpiry, err := nextExclusionExpiry()
+	if err != nil {
+		return err
+	}
+
+	standingsCache.mu.Lock()
+	standingsCache.standings = standings
+	standingsCache.nextExpiry = nextExpiry
+	standingsCache.loaded = true
+	standingsCache.mu.Unlock()
+	return nil
+}
+
+// reloadLeaderboardCache reloads the cache after a change that can move
+// many players. On failure the cache is dropped, so reads fall back to the
+// database until MaintainLeaderboardCache loads it again.
+func reloadLeaderboardCache() {
+	if err := LoadLeaderboardCache(); err != nil {
+		log.Printf("ERROR: Failed to reload leaderboard cache: %v", err)
+		standingsCache.mu.Lock()
+		standingsCache.drop()
+		standingsCache.mu.Unlock()
+	}
+}
+
+// drop empties the cache. The caller holds mu.
+func (c *leaderboardCache) drop() {
+	c.loaded = false
+	c.standings = nil
+	c.nextExpiry = nil
+}
+
+// refreshLeaderboardPlayer recomputes one player's standing and moves them
+// to their new place, or off the leaderboard if they no longer rank.
+func refreshLeaderboardPlayer(userEmail string) {
+	standingsCache.write.Lock()
+	defer standingsCache.write.Unlock()
+
+	standingsCache.mu.RLock()
+	loaded := standingsCache.loaded
+	standingsCache.mu.RUnlock()
+	if !loaded {
+		return
+	}
+
+	rows, err := selectStandings(" AND l.gmail = ?", []interface{}{userEmail}, 0)
+
+	standingsCache.mu.Lock()
+	defer standingsCache.mu.Unlock()
+	if err != nil {
+		log.Printf("ERROR: Failed to refresh leaderboard cache for %s: %v", userEmail, err)
+		standingsCache.drop()
+		return
+	}
+
+	standings := standingsCache.standings
+	for i, s := range standings {
+		if s.Gmail == userEmail {
+			standings = append(standings[:i:i], standings[i+1:]...)
+			break
+		}
+	}
+	if len(rows) == 1 {
+		row := rows[0]
+		at := sort.Search(len(standings), func(i int) bool { return standingBefore(row, standings[i]) })
+		standings = append(standings[:at:at], append([]Sucker{row}, standings[at:]...)...)
+	}
+	rankSuckers(standings)
+	standingsCache.standings = standings
+}
+
+// nextExclusionExpiry returns when the next timed ban or disqualification
+// lapses, or nil if none will.
+func nextExclusionExpiry() (*time.Time, error) {
+	now := time.Now().UTC()
+	var next *time.Time
+	for _, query := range []string{
+		"SELECT expires_at FROM disqualifications WHERE expires_at > ? ORDER BY expires_at LIMIT 1",
+		"SELECT expires_at FROM banned_emails WHERE expires_at > ? ORDER BY expires_at LIMIT 1",
+	} {
+		var expiresAt time.Time
+		err := db.QueryRow(query, now).Scan(&expiresAt)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if next == nil || expiresAt.Before(*next) {
+			next = &expiresAt
+		}
+	}
+	return next, nil
+}
+
+// MaintainLeaderboardCache loads the cache if it was dropped and reloads it
+// once a timed ban or disqualification has lapsed.
+func MaintainLeaderboardCache() {
+	standingsCache.mu.RLock()
+	due := !standingsCache.loaded || (standingsCache.nextExpiry != nil && !time.Now().Before(*standingsCache.nextExpiry))
+	standingsCache.mu.RUnlock()
+	if due {
+		reloadLeaderboardCache()
+	}
+}
+
+// LeaderboardCacheCheck compares the cache with a full recomputation.
+type LeaderboardCacheCheck struct {
+	Loaded     bool      `json:"loaded"`
+	Consistent bool      `json:"consistent"`
+	Cached     int       `json:"cached"`
+	Computed   int       `json:"computed"`
+	Mismatches []string  `json:"mismatches"`
+	Repaired   bool      `json:"repaired"`
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// maxCacheMismatches caps how many differences a check lists.
+const maxCacheMismatches = 20
+
+// CheckLeaderboardCache recomputes the standings and lists where the cache
+// differs, reloading it when repair is set and it is off.
+func CheckLeaderboardCache(repair bool) (*LeaderboardCacheCheck, error) {
+	// Hold off writers so both sides see the same data
+	standingsCache.write.Lock()
+	computed, err := selectStandings("", nil, 0)
+	cached, loaded := standingsCache.get()
+	standingsCache.write.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	check := &LeaderboardCacheCheck{
+		Loaded:     loaded,
+		Cached:     len(cached),
+		Computed:   len(computed),
+		Mismatches: []string{},
+		CheckedAt:  time.Now().UTC(),
+	}
+	if loaded {
+		for i := 0; i < len(cached) || i < len(computed); i++ {
+			if len(check.Mismatches) == maxCacheMismatches {
+				break
+			}
+			switch {
+			case i >= len(cached):
+				check.Mismatches = append(check.Mismatches, fmt.Sprintf("#%d: missing %s", i+1, computed[i].Gmail))
+			case i >= len(computed):
+				check.Mismatches = append(check.Mismatches, fmt.Sprintf("#%d: extra %s", i+1, cached[i].Gmail))
+			case !sameCachedStanding(cached[i], computed[i]):
+				check.Mismatches = append(check.Mismatches, fmt.Sprintf("#%d: cached %s, computed %s", i+1, describeStanding(cached[i]), describeStanding(computed[i])))
+			}
+		}
+	}
+	check.Consistent = loaded && len(check.Mismatches) == 0
+
+	if !check.Consistent && repair {
+		if err := LoadLeaderboardCache(); err != nil {
+			return nil, err
+		}
+		check.Repaired = true
+	}
+	return check, nil
+}
+
+func sameCachedStanding(a, b Sucker) bool {
+	return a.Gmail == b.Gmail && a.Rank == b.Rank && sameStanding(a, b)
+}
+
+func describeStanding(s Sucker) string {
+	return fmt.Sprintf("%s (rank %d, level %d, score %d)", s.Gmail, s.Rank, s.On, s.Score)
+}
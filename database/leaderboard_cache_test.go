@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB points the package at a fresh SQLite file with the full schema
+// and empty leaderboard caches.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	testDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db
+	db = testDB
+	createTables()
+	resetCaches := func() {
+		standingsCache.mu.Lock()
+		standingsCache.drop()
+		standingsCache.mu.Unlock()
+		freezeCache.invalidate()
+		maxLevelCache.invalidate()
+		leaderboardBadgesCache.invalidate()
+	}
+	resetCaches()
+	t.Cleanup(func() {
+		testDB.Close()
+		db = previous
+		resetCaches()
+	})
+}
+
+func assertCacheConsistent(t *testing.T, step string) {
+	t.Helper()
+	check, err := CheckLeaderboardCache(false)
+	if err != nil {
+		t.Fatalf("%s: check failed: %v", step, err)
+	}
+	if !check.Loaded {
+		t.Fatalf("%s: cache was dropped", step)
+	}
+	if !check.Consistent {
+		t.Fatalf("%s: cache drifted: %v", step, check.Mismatches)
+	}
+}
+
+func cachedStanding(t *testing.T, email string) *Sucker {
+	t.Helper()
+	standings, _ := standingsCache.get()
+	for _, s := range standings {
+		if s.Gmail == email {
+			return &s
+		}
+	}
+	return nil
+}
+
+func TestLeaderboardCacheFollowsWrites(t *testing.T) {
+	openTestDB(t)
+
+	for i, answer := range []string{"alpha", "beta", "gamma"} {
+		level := AdminLevel{LevelNumber: i + 1, Markdown: "# Level", Answer: answer, Active: true}
+		if err := Create("level", level); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := LoadLeaderboardCache(); err != nil {
+		t.Fatal(err)
+	}
+	players := []string{"a@x.com", "b@x.com", "c@x.com"}
+	for _, email := range players {
+		if err := Create("login", Login{Gmail: email, Hashed: "x", Verified: true, On: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertCacheConsistent(t, "create logins")
+
+	submit := func(email string, level int, answer string) {
+		t.Helper()
+		result, err := CheckAnswer(email, level, answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Correct {
+			t.Fatalf("%s on level %d: %s", email, level, result.Message)
+		}
+	}
+
+	submit("a@x.com", 1, "alpha")
+	submit("b@x.com", 1, "alpha")
+	submit("a@x.com", 2, "beta")
+	assertCacheConsistent(t, "CheckAnswer")
+	if s := cachedStanding(t, "a@x.com"); s == nil || s.On != 3 || s.Rank != 1 {
+		t.Fatalf("CheckAnswer: a@x.com cached as %+v, want level 3 rank 1", s)
+	}
+
+	if err := ResetUserLevel("a@x.com"); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "ResetUserLevel")
+
+	if err := BanEmailUntil("b@x.com", "admin@x.com", "testing", nil); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "BanEmailUntil")
+	if cachedStanding(t, "b@x.com") != nil {
+		t.Fatal("BanEmailUntil: banned player is still cached")
+	}
+	if err := UnbanEmail("b@x.com"); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "UnbanEmail")
+
+	adjustment, err := CreateScoreAdjustment("c@x.com", "points", 5, "testing", "admin@x.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "CreateScoreAdjustment")
+	if _, err := RevertScoreAdjustment(adjustment.ID, "admin@x.com", "testing"); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "RevertScoreAdjustment")
+
+	if _, err := PlacePlayer("c@x.com", 2, "testing", "admin@x.com"); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "PlacePlayer")
+
+	parts := []LevelPart{
+		{Name: "first", Answer: "one", Required: true, Weight: 3},
+		{Name: "second", Answer: "two", Required: true, Weight: 2},
+	}
+	if _, err := SetLevelParts(2, false, parts); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "SetLevelParts")
+	submit("c@x.com", 2, "one")
+	assertCacheConsistent(t, "part credit")
+	if s := cachedStanding(t, "c@x.com"); s == nil || s.Score != 3 {
+		t.Fatalf("part credit: c@x.com cached as %+v, want score 3", s)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	if err := DisqualifyPlayer("c@x.com", "testing", false, "admin@x.com", &expires); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "DisqualifyPlayer")
+	if cachedStanding(t, "c@x.com") != nil {
+		t.Fatal("DisqualifyPlayer: disqualified player is still cached")
+	}
+	if err := RequalifyPlayer("c@x.com"); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheConsistent(t, "RequalifyPlayer")
+}
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Snapshot kinds.
+const (
+	SnapshotFreeze  = "freeze"
+	SnapshotHistory = "history"
+)
+
+var (
+	ErrLeaderboardFrozen    = errors.New("the leaderboard is frozen; reveal it first")
+	ErrLeaderboardNotFrozen = errors.New("the leaderboard is not frozen")
+)
+
+// LeaderboardFreeze is the freeze schedule. Once FreezeAt passes a snapshot
+// of the standings is taken and served to players until an admin reveals
+// the live standings.
+type LeaderboardFreeze struct {
+	FreezeAt   *time.Time `json:"freezeAt"`
+	FrozenAt   *time.Time `json:"frozenAt,omitempty"`
+	RevealedAt *time.Time `json:"revealedAt,omitempty"`
+	SnapshotID int        `json:"snapshotId,omitempty"`
+	Frozen     bool       `json:"frozen"`
+	SetBy      string     `json:"setBy,omitempty"`
+}
+
+// freezeMu keeps two callers from both taking the freeze snapshot.
+var freezeMu sync.Mutex
+
+// GetLeaderboardFreeze returns the freeze schedule, taking the snapshot
+// first if the freeze time has just passed. It is served from memory.
+func GetLeaderboardFreeze() (*LeaderboardFreeze, error) {
+	freeze, err := freezeCache.get(func() (LeaderboardFreeze, error) {
+		freeze, err := loadLeaderboardFreeze()
+		if err != nil {
+			return LeaderboardFreeze{}, err
+		}
+		return *freeze, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if freeze.FreezeAt == nil || freeze.SnapshotID != 0 || time.Now().Before(*freeze.FreezeAt) {
+		return &freeze, nil
+	}
+	return FreezeLeaderboardIfDue()
+}
+
+func loadLeaderboardFreeze() (*LeaderboardFreeze, error) {
+	var freeze LeaderboardFreeze
+	var freezeAt, revealedAt, frozenAt sql.NullTime
+	var snapshotID sql.NullInt64
+	err := db.QueryRow(`SELECT f.freeze_at, f.revealed_at, f.snapshot_id, f.set_by, s.taken_at
+		FROM leaderboard_freeze f LEFT JOIN leaderboard_snapshots s ON s.id = f.snapshot_id WHERE f.id = 1`).
+		Scan(&freezeAt, &revealedAt, &snapshotID, &freeze.SetBy, &frozenAt)
+	if err == sql.ErrNoRows {
+		return &freeze, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if freezeAt.Valid {
+		t := freezeAt.Time
+		freeze.FreezeAt = &t
+	}
+	if revealedAt.Valid {
+		t := revealedAt.Time
+		freeze.RevealedAt = &t
+	}
+	if frozenAt.Valid {
+		t := frozenAt.Time
+		freeze.FrozenAt = &t
+	}
+	freeze.SnapshotID = int(snapshotID.Int64)
+	freeze.Frozen = freeze.SnapshotID != 0 && freeze.RevealedAt == nil
+	return &freeze, nil
+}
+
+// ScheduleLeaderboardFreeze sets when the leaderboard freezes. A time in
+// the past freezes it straight away. It can not be moved once frozen.
+func ScheduleLeaderboardFreeze(freezeAt time.Time, setBy string) (*LeaderboardFreeze, error) {
+	freezeMu.Lock()
+	current, err := loadLeaderboardFreeze()
+	if err == nil && current.Frozen {
+		err = ErrLeaderboardFrozen
+	}
+	if err == nil {
+		_, err = db.Exec(`INSERT INTO leaderboard_freeze (id, freeze_at, revealed_at, snapshot_id, set_by) VALUES (1, ?, NULL, NULL, ?)
+			ON CONFLICT(id) DO UPDATE SET freeze_at = excluded.freeze_at, revealed_at = NULL, snapshot_id = NULL, set_by = excluded.set_by`,
+			freezeAt.UTC(), setBy)
+		freezeCache.invalidate()
+	}
+	freezeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return GetLeaderboardFreeze()
+}
+
+// CancelLeaderboardFreeze drops a freeze that has not happened yet.
+func CancelLeaderboardFreeze() error {
+	freezeMu.Lock()
+	defer freezeMu.Unlock()
+
+	current, err := loadLeaderboardFreeze()
+	if err != nil {
+		return err
+	}
+	if current.Frozen {
+		return ErrLeaderboardFrozen
+	}
+	if current.FreezeAt == nil || current.SnapshotID != 0 {
+		return sql.ErrNoRows
+	}
+	_, err = db.Exec("DELETE FROM leaderboard_freeze WHERE id = 1")
+	freezeCache.invalidate()
+	return err
+}
+
+// FreezeLeaderboardIfDue takes the freeze snapshot once the freeze time has
+// passed. It is safe to call repeatedly.
+func FreezeLeaderboardIfDue() (*LeaderboardFreeze, error) {
+	freezeMu.Lock()
+	defer freezeMu.Unlock()
+
+	freeze, err := loadLeaderboardFreeze()
+	if err != nil || freeze.FreezeAt == nil || freeze.SnapshotID != 0 || time.Now().Before(*freeze.FreezeAt) {
+		return freeze, err
+	}
+
+	snapshotID, err := takeLeaderboardSnapshot(SnapshotFreeze)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("UPDATE leaderboard_freeze SET snapshot_id = ? WHERE id = 1", snapshotID); err != nil {
+		return nil, err
+	}
+	freezeCache.invalidate()
+	log.Printf("INFO: Leaderboard frozen with snapshot %d", snapshotID)
+	return loadLeaderboardFreeze()
+}
+
+// RevealLeaderboard ends a freeze so everyone sees the live standings
+// again, and announces it.
+func RevealLeaderboard() (*LeaderboardFreeze, error) {
+	freezeMu.Lock()
+	current, err := loadLeaderboardFreeze()
+	if err == nil && !current.Frozen {
+		err = ErrLeaderboardNotFrozen
+	}
+	if err == nil {
+		_, err = db.Exec("UPDATE leaderboard_freeze SET revealed_at = ? WHERE id = 1", time.Now().UTC())
+		freezeCache.invalidate()
+	}
+	freezeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createRelayedAnnouncement(0, "The final standings have been revealed!"); err != nil {
+		log.Printf("ERROR: Failed to announce leaderboard reveal: %v", err)
+	}
+	return loadLeaderboardFreeze()
+}
+
+// leaderboardFrozen reports whether players currently see the freeze
+// snapshot.
+func leaderboardFrozen() bool {
+	freeze, err := GetLeaderboardFreeze()
+	return err == nil && freeze.Frozen
+}
+
+// migrateSnapshotEvents lets snapshot entries belong to an event board.
+// The table is rebuilt because the uniqueness constraint has to include the
+// event.
+func migrateSnapshotEvents() {
+	if columnExists("leaderboard_snapshot_entries", "event_id") {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("ERROR: Failed to migrate leaderboard snapshots: %v", err)
+		return
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"ALTER TABLE leaderboard_snapshot_entries RENAME TO leaderboard_snapshot_entries_old",
+		`CREATE TABLE leaderboard_snapshot_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			snapshot_id INTEGER NOT NULL,
+			event_id INTEGER NOT NULL DEFAULT 0,
+			gmail TEXT NOT NULL,
+			score INTEGER NOT NULL,
+			ranked_on INTEGER NOT NULL,
+			reached_at DATETIME,
+			rank INTEGER NOT NULL,
+			UNIQUE(snapshot_id, event_id, gmail)
+		)`,
+		`INSERT INTO leaderboard_snapshot_entries (id, snapshot_id, gmail, score, ranked_on, reached_at, rank)
+			SELECT id, snapshot_id, gmail, score, ranked_on, reached_at, rank FROM leaderboard_snapshot_entries_old`,
+		"DROP TABLE leaderboard_snapshot_entries_old",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			log.Printf("ERROR: Failed to migrate leaderboard snapshots: %v", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("ERROR: Failed to migrate leaderboard snapshots: %v", err)
+	}
+}
+
+// takeLeaderboardSnapshot stores the current global standings. Freeze
+// snapshots also store each event's standings, ranked the way the live
+// event board ranks them.
+func takeLeaderboardSnapshot(kind string) (int, error) {
+	boards := map[int][]Sucker{}
+	standings, err := GetLeaderboard(0)
+	if err != nil {
+		return 0, err
+	}
+	boards[0] = standings
+
+	if kind == SnapshotFreeze {
+		events, err := GetEvents()
+		if err != nil {
+			return 0, err
+		}
+		for _, event := range events {
+			standings, err := GetLeaderboard(event.ID)
+			if err != nil {
+				return 0, err
+			}
+			boards[event.ID] = standings
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO leaderboard_snapshots (kind, taken_at) VALUES (?, ?)", kind, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	id, _ := result.LastInsertId()
+
+	for eventID, standings := range boards {
+		for _, s := range standings {
+			var reachedAt interface{}
+			if s.ReachedAt != nil {
+				reachedAt = s.ReachedAt.UTC()
+			}
+			_, err := tx.Exec("INSERT INTO leaderboard_snapshot_entries (snapshot_id, event_id, gmail, score, ranked_on, reached_at, rank) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				id, eventID, s.Gmail, s.Score, s.On, reachedAt, s.Rank)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// getSnapshotStandings returns a snapshot's standings for the global board,
+// or for an event's board when eventID is set, in leaderboard order.
+func getSnapshotStandings(snapshotID, eventID int) ([]Sucker, error) {
+	rows, err := db.Query(`SELECT gmail, score, ranked_on, reached_at, rank FROM leaderboard_snapshot_entries
+		WHERE snapshot_id = ? AND event_id = ? ORDER BY rank, gmail`, snapshotID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := []Sucker{}
+	for rows.Next() {
+		var s Sucker
+		var reachedAt sql.NullTime
+		if err := rows.Scan(&s.Gmail, &s.Score, &s.On, &reachedAt, &s.Rank); err != nil {
+			return nil, err
+		}
+		if reachedAt.Valid {
+			t := reachedAt.Time
+			s.ReachedAt = &t
+		}
+		standings = append(standings, s)
+	}
+	return standings, nil
+}
+
+// GetStandings returns the leaderboard players should see: the live one,
+// or the freeze snapshot while the leaderboard is frozen. Admins pass live
+// to always see the live standings.
+func GetStandings(eventID int, live bool) ([]Sucker, error) {
+	if !live {
+		freeze, err := GetLeaderboardFreeze()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load leaderboard freeze: %v", err)
+		}
+		if freeze.Frozen {
+			return getSnapshotStandings(freeze.SnapshotID, eventID)
+		}
+	}
+	return GetLeaderboard(eventID)
+}
@@ -0,0 +1,366 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Leaderboard history limits.
+const (
+	DefaultHistoryTop    = 10
+	MaxHistoryTop        = 50
+	DefaultHistoryPoints = 100
+	MaxHistoryPoints     = 500
+)
+
+var ErrInvalidHistoryRange = errors.New("from must be before to")
+
+// HistoryQuery selects the progress of the top players over time. Zero
+// times default to the competition window, so after the competition ends
+// the whole of it is replayed. Step is raised to at least MinStep. Live
+// skips the leaderboard freeze and rebuilds samples taken before the first
+// snapshot, for admins.
+type HistoryQuery struct {
+	From    time.Time
+	To      time.Time
+	Step    time.Duration
+	MinStep time.Duration
+	Top     int
+	Live    bool
+}
+
+// HistoryPoint is a player's standing at one point in time.
+type HistoryPoint struct {
+	Rank  int  `json:"rank"`
+	On    uint `json:"on"`
+	Score int  `json:"score"`
+}
+
+// LeaderboardSeries is one player's standing at each of the history's
+// times; a point is nil while the player was not on the leaderboard.
+type LeaderboardSeries struct {
+	Gmail  string          `json:"gmail"`
+	Points []*HistoryPoint `json:"points"`
+}
+
+// LeaderboardHistory is the progress of the players who lead at To.
+type LeaderboardHistory struct {
+	From    time.Time           `json:"from"`
+	To      time.Time           `json:"to"`
+	Step    int                 `json:"stepSeconds"`
+	Times   []time.Time         `json:"times"`
+	Players []LeaderboardSeries `json:"players"`
+}
+
+// competitionRunning reports whether the main competition or any event is
+// underway, which is when history snapshots are worth taking.
+func competitionRunning(now time.Time) bool {
+	window := DefaultCompetitionWindow()
+	if !now.Before(window.Start) && now.Before(window.End) {
+		return true
+	}
+	events, err := GetEvents()
+	if err != nil {
+		return false
+	}
+	for _, e := range events {
+		if !now.Before(e.StartsAt) && now.Before(e.EndsAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// TakeHistorySnapshotIfRunning stores the current standings for the
+// history while a competition is underway. It returns the snapshot ID, or
+// 0 when none was taken.
+func TakeHistorySnapshotIfRunning() (int, error) {
+	if !competitionRunning(time.Now()) {
+		return 0, nil
+	}
+	return takeLeaderboardSnapshot(SnapshotHistory)
+}
+
+// GetStandingsAt rebuilds the leaderboard as it stood at a moment, from
+// when levels and parts were solved and adjustments made. Players are kept
+// off by today's bans and disqualifications, and progress since undone by a
+// reset or placement is not recovered.
+func GetStandingsAt(at time.Time) ([]Sucker, error) {
+	if !at.Before(time.Now()) {
+		return GetLeaderboard(0)
+	}
+
+	// Completion times default to SQLite's CURRENT_TIMESTAMP format
+	t := at.UTC().Format("2006-01-02 15:04:05")
+	args := []interface{}{t, t, t, t, t}
+
+	whereClause, whereArgs := rankingsWhere()
+	args = append(args, whereArgs...)
+
+	// Every completion moved a player up one level, whatever their level
+	// range, so their level back then is today's less those made since.
+	query := `SELECT l.gmail, COALESCE(adj.points, 0) + COALESCE(pc.credit, 0) as score,
+			MAX(l."on" - COALESCE(since.solved, 0) + COALESCE(adj.levels, 0), 1) as ranked_on, lc.completed_at
+		FROM logins l
+		LEFT JOIN (SELECT user_email, COUNT(*) as solved FROM level_completions
+			WHERE completed_at > ? GROUP BY user_email) since ON l.gmail = since.user_email
+		LEFT JOIN level_completions lc ON lc.id = (SELECT c.id FROM level_completions c
+			WHERE c.user_email = l.gmail AND c.completed_at <= ? ORDER BY c.completed_at DESC, c.id DESC LIMIT 1)
+		LEFT JOIN (SELECT user_email,
+			SUM(CASE WHEN kind = 'points' THEN amount ELSE 0 END) as points,
+			SUM(CASE WHEN kind = 'levels' THEN amount ELSE 0 END) as levels
+			FROM score_adjustments WHERE created_at <= ? AND (reverted = FALSE OR reverted_at > ?)
+			GROUP BY user_email) adj ON l.gmail = adj.user_email
+		LEFT JOIN (SELECT c.user_email, c.level_number, SUM(p.weight) as credit
+			FROM part_completions c JOIN level_parts p ON p.level_number = c.level_number AND p.name = c.part_name
+			WHERE c.practice = FALSE AND c.completed_at <= ? GROUP BY c.user_email, c.level_number) pc
+			ON l.gmail = pc.user_email AND pc.level_number = l."on" - COALESCE(since.solved, 0)` +
+		whereClause + ` ORDER BY ranked_on DESC, score DESC, lc.completed_at ASC, l.gmail ASC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanStandings(rows)
+}
+
+// GetSnapshotStandingsAt returns the standings at a moment without
+// rebuilding them: the leaderboard players see for the present or, while
+// frozen, anything after the freeze; otherwise
+// the latest history snapshot taken by then, less players since banned or
+// disqualified. It also returns when those standings were taken, which is
+// nil when no snapshot is that old.
+func GetSnapshotStandingsAt(at time.Time) ([]Sucker, *time.Time, error) {
+	takenAt := time.Now()
+	freeze, err := GetLeaderboardFreeze()
+	if err != nil {
+		return nil, nil, err
+	}
+	if freeze.Frozen {
+		takenAt = *freeze.FrozenAt
+	}
+	if !at.Before(takenAt) {
+		standings, err := GetStandings(0, false)
+		return standings, &takenAt, err
+	}
+
+	var snapshot historySnapshot
+	err = db.QueryRow("SELECT id, taken_at FROM leaderboard_snapshots WHERE kind = ? AND taken_at <= ? ORDER BY taken_at DESC LIMIT 1",
+		SnapshotHistory, at.UTC()).Scan(&snapshot.id, &snapshot.takenAt)
+	if err == sql.ErrNoRows {
+		return []Sucker{}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	standings, err := getSnapshotStandings(snapshot.id, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	excluded, err := excludedNow()
+	if err != nil {
+		return nil, nil, err
+	}
+	return withoutExcluded(standings, excluded), &snapshot.takenAt, nil
+}
+
+// historySnapshot is a history snapshot available for a time series.
+type historySnapshot struct {
+	id      int
+	takenAt time.Time
+}
+
+// getHistorySnapshots returns the history snapshots taken between from and
+// to, led by the last one taken before from if there is one.
+func getHistorySnapshots(from, to time.Time) ([]historySnapshot, error) {
+	rows, err := db.Query(`SELECT id, taken_at FROM leaderboard_snapshots WHERE kind = ? AND taken_at <= ? AND taken_at >= COALESCE(
+			(SELECT MAX(taken_at) FROM leaderboard_snapshots WHERE kind = ? AND taken_at <= ?), ?)
+		ORDER BY taken_at`,
+		SnapshotHistory, to.UTC(), SnapshotHistory, from.UTC(), from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []historySnapshot{}
+	for rows.Next() {
+		var s historySnapshot
+		if err := rows.Scan(&s.id, &s.takenAt); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+// excludedNow returns the players currently kept off the rankings.
+func excludedNow() (map[string]bool, error) {
+	now := time.Now().UTC()
+	rows, err := db.Query(excludedFromRankings, now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	excluded := map[string]bool{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		excluded[email] = true
+	}
+	return excluded, nil
+}
+
+// GetLeaderboardHistory samples the standings from From to To every Step.
+// A sample uses the latest history snapshot taken at or before it, and a
+// last sample at the present is the leaderboard itself. Only live queries
+// rebuild samples from the progress tables, for the last sample and for
+// samples from before the first snapshot, since a rebuild is far more
+// expensive than reading a snapshot.
+func GetLeaderboardHistory(q HistoryQuery) (*LeaderboardHistory, error) {
+	window := DefaultCompetitionWindow()
+	if q.From.IsZero() {
+		q.From = window.Start
+	}
+	if q.To.IsZero() {
+		q.To = window.End
+		if !q.To.After(q.From) {
+			q.To = time.Now()
+		}
+	}
+	// current is set when the last sample is what the leaderboard shows now
+	current := false
+	if now := time.Now(); !q.To.Before(now) {
+		q.To = now
+		current = true
+	}
+	if !q.Live {
+		freeze, err := GetLeaderboardFreeze()
+		if err != nil {
+			return nil, err
+		}
+		if freeze.Frozen && !q.To.Before(*freeze.FrozenAt) {
+			q.To = *freeze.FrozenAt
+			current = true
+		}
+	}
+	if !q.From.Before(q.To) {
+		return nil, ErrInvalidHistoryRange
+	}
+
+	span := q.To.Sub(q.From)
+	if q.Step <= 0 {
+		q.Step = span / DefaultHistoryPoints
+	}
+	q.Step = q.Step.Round(time.Second)
+	// Whole seconds, rounded up, so there are never more than the maximum
+	unit := MaxHistoryPoints * time.Second
+	if min := (span + unit - 1) / unit * time.Second; q.Step < min {
+		q.Step = min
+	}
+	if q.Step < q.MinStep {
+		q.Step = q.MinStep
+	}
+	if q.Top <= 0 {
+		q.Top = DefaultHistoryTop
+	}
+	if q.Top > MaxHistoryTop {
+		q.Top = MaxHistoryTop
+	}
+
+	times := []time.Time{}
+	for t := q.From; t.Before(q.To); t = t.Add(q.Step) {
+		times = append(times, t.UTC())
+	}
+	times = append(times, q.To.UTC())
+
+	snapshots, err := getHistorySnapshots(q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := excludedNow()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([][]Sucker, len(times))
+	loaded := map[int][]Sucker{}
+	next := 0
+	var snapshot *historySnapshot
+	for i, t := range times {
+		for next < len(snapshots) && !snapshots[next].takenAt.After(t) {
+			snapshot = &snapshots[next]
+			next++
+		}
+
+		switch {
+		case i == len(times)-1 && current:
+			// The last sample matches the leaderboard, which is in memory
+			samples[i], err = GetStandings(0, q.Live)
+		case i == len(times)-1 && q.Live:
+			samples[i], err = GetStandingsAt(t)
+		case snapshot != nil:
+			// Consecutive samples often share a snapshot
+			if _, ok := loaded[snapshot.id]; !ok {
+				standings, err := getSnapshotStandings(snapshot.id, 0)
+				if err != nil {
+					return nil, err
+				}
+				loaded[snapshot.id] = withoutExcluded(standings, excluded)
+			}
+			samples[i] = loaded[snapshot.id]
+		case q.Live:
+			samples[i], err = GetStandingsAt(t)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	last := samples[len(samples)-1]
+	if len(last) > q.Top {
+		last = last[:q.Top]
+	}
+	players := make([]LeaderboardSeries, len(last))
+	index := map[string]int{}
+	for i, s := range last {
+		players[i] = LeaderboardSeries{Gmail: s.Gmail, Points: make([]*HistoryPoint, len(times))}
+		index[s.Gmail] = i
+	}
+	for i, sample := range samples {
+		for _, s := range sample {
+			if p, ok := index[s.Gmail]; ok {
+				players[p].Points[i] = &HistoryPoint{Rank: s.Rank, On: s.On, Score: s.Score}
+			}
+		}
+	}
+
+	return &LeaderboardHistory{
+		From:    q.From.UTC(),
+		To:      q.To.UTC(),
+		Step:    int(q.Step / time.Second),
+		Times:   times,
+		Players: players,
+	}, nil
+}
+
+// withoutExcluded drops players since banned or disqualified from a
+// snapshot's standings and ranks the rest again.
+func withoutExcluded(standings []Sucker, excluded map[string]bool) []Sucker {
+	if len(excluded) == 0 {
+		return standings
+	}
+	kept := standings[:0]
+	for _, s := range standings {
+		if !excluded[s.Gmail] {
+			kept = append(kept, s)
+		}
+	}
+	rankSuckers(kept)
+	return kept
+}
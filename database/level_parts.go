@@ -0,0 +1,271 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var partNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// LevelPart is one named flag of a multi-part level. A level without parts
+// is checked against its single answer as before.
+type LevelPart struct {
+	Name     string `json:"name"`
+	Answer   string `json:"answer,omitempty"`
+	Position int    `json:"position"`
+	Required bool   `json:"required"`
+	Weight   int    `json:"weight"`
+}
+
+// LevelPartsConfig is the admin view of a level's parts. When Ordered is
+// set a part only accepts answers once every required part before it is
+// solved.
+type LevelPartsConfig struct {
+	LevelNumber int         `json:"levelNumber"`
+	Ordered     bool        `json:"ordered"`
+	Parts       []LevelPart `json:"parts"`
+}
+
+// LevelPartStatus is the player view of a part; answers are never included.
+type LevelPartStatus struct {
+	Name     string     `json:"name"`
+	Position int        `json:"position"`
+	Required bool       `json:"required"`
+	Weight   int        `json:"weight"`
+	Solved   bool       `json:"solved"`
+	Locked   bool       `json:"locked,omitempty"`
+	SolvedAt *time.Time `json:"solvedAt,omitempty"`
+}
+
+func migrateLevelParts() {
+	if !columnExists("levels", "parts_ordered") {
+		db.Exec("ALTER TABLE levels ADD COLUMN parts_ordered BOOLEAN DEFAULT FALSE")
+	}
+}
+
+func GetLevelPartsConfig(levelNumber int) (*LevelPartsConfig, error) {
+	config := LevelPartsConfig{LevelNumber: levelNumber}
+	var ordered sql.NullBool
+	err := db.QueryRow("SELECT parts_ordered FROM levels WHERE level_number = ?", levelNumber).Scan(&ordered)
+	if err != nil {
+		return nil, err
+	}
+	config.Ordered = ordered.Bool
+
+	config.Parts, err = getLevelParts(levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func getLevelParts(levelNumber int) ([]LevelPart, error) {
+	rows, err := db.Query("SELECT name, answer, position, required, weight FROM level_parts WHERE level_number = ? ORDER BY position", levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parts := []LevelPart{}
+	for rows.Next() {
+		var p LevelPart
+		if err := rows.Scan(&p.Name, &p.Answer, &p.Position, &p.Required, &p.Weight); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// SetLevelParts replaces a level's parts. Positions follow the order given.
+// Progress on parts that keep their name is kept; progress on removed parts
+// is dropped. An empty list turns the level back into a single-answer level.
+func SetLevelParts(levelNumber int, ordered bool, parts []LevelPart) (*LevelPartsConfig, error) {
+	seen := map[string]bool{}
+	required := 0
+	for i := range parts {
+		parts[i].Name = strings.ToLower(strings.TrimSpace(parts[i].Name))
+		parts[i].Answer = strings.TrimSpace(parts[i].Answer)
+		if !partNamePattern.MatchString(parts[i].Name) {
+			return nil, fmt.Errorf("part names must be 1-32 lowercase letters, digits, dashes or underscores")
+		}
+		if seen[parts[i].Name] {
+			return nil, fmt.Errorf("duplicate part name %q", parts[i].Name)
+		}
+		seen[parts[i].Name] = true
+		if parts[i].Answer == "" {
+			return nil, fmt.Errorf("part %q needs an answer", parts[i].Name)
+		}
+		if parts[i].Weight < 0 {
+			return nil, fmt.Errorf("part %q has a negative weight", parts[i].Name)
+		}
+		if parts[i].Required {
+			required++
+		}
+		parts[i].Position = i + 1
+	}
+	if len(parts) > 0 && required == 0 {
+		return nil, fmt.Errorf("at least one part must be required")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE levels SET parts_ordered = ? WHERE level_number = ?", ordered, levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec("DELETE FROM level_parts WHERE level_number = ?", levelNumber); err != nil {
+		return nil, err
+	}
+
+	names := []interface{}{levelNumber}
+	placeholders := []string{}
+	for _, p := range parts {
+		_, err := tx.Exec("INSERT INTO level_parts (level_number, name, answer, position, required, weight) VALUES (?, ?, ?, ?, ?, ?)",
+			levelNumber, p.Name, p.Answer, p.Position, p.Required, p.Weight)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, p.Name)
+		placeholders = append(placeholders, "?")
+	}
+
+	cleanup := "DELETE FROM part_completions WHERE level_number = ?"
+	if len(placeholders) > 0 {
+		cleanup += " AND part_name NOT IN (" + strings.Join(placeholders, ",") + ")"
+	}
+	if _, err := tx.Exec(cleanup, names...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	// Weights feed every player's partial credit on the level
+	reloadLeaderboardCache()
+	return GetLevelPartsConfig(levelNumber)
+}
+
+// GetLevelPartStatuses reports which parts of a level a player has solved.
+// It returns nil for single-answer levels.
+func GetLevelPartStatuses(levelNumber int, userEmail string, practice bool) ([]LevelPartStatus, error) {
+	config, err := GetLevelPartsConfig(levelNumber)
+	if err != nil || len(config.Parts) == 0 {
+		return nil, err
+	}
+
+	solved, err := solvedParts(levelNumber, userEmail, practice)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]LevelPartStatus, 0, len(config.Parts))
+	for _, p := range config.Parts {
+		status := LevelPartStatus{Name: p.Name, Position: p.Position, Required: p.Required, Weight: p.Weight}
+		if solvedAt, ok := solved[p.Name]; ok {
+			t := solvedAt
+			status.Solved = true
+			status.SolvedAt = &t
+		} else {
+			status.Locked = !partUnlocked(config, p, solved)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func solvedParts(levelNumber int, userEmail string, practice bool) (map[string]time.Time, error) {
+	rows, err := db.Query("SELECT part_name, completed_at FROM part_completions WHERE user_email = ? AND level_number = ? AND practice = ?",
+		userEmail, levelNumber, practice)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	solved := map[string]time.Time{}
+	for rows.Next() {
+		var name string
+		var completedAt time.Time
+		if err := rows.Scan(&name, &completedAt); err != nil {
+			return nil, err
+		}
+		solved[name] = completedAt
+	}
+	return solved, nil
+}
+
+// partUnlocked reports whether a part accepts answers yet. Unordered levels
+// accept every part; ordered levels wait on the required parts before it.
+func partUnlocked(config *LevelPartsConfig, part LevelPart, solved map[string]time.Time) bool {
+	if !config.Ordered {
+		return true
+	}
+	for _, p := range config.Parts {
+		if p.Position >= part.Position {
+			break
+		}
+		if _, ok := solved[p.Name]; p.Required && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// solveLevelPart matches an answer against the open parts of a level and
+// records the part it solves. complete reports whether every required part
+// is now solved. A nil part means the answer matched nothing open.
+func solveLevelPart(config *LevelPartsConfig, lv *LevelValidator, userEmail, answer string, practice bool) (part *LevelPart, complete bool, err error) {
+	solved, err := solvedParts(config.LevelNumber, userEmail, practice)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for i := range config.Parts {
+		p := config.Parts[i]
+		if _, ok := solved[p.Name]; ok || !partUnlocked(config, p, solved) {
+			continue
+		}
+		verdict, err := matchAnswer(lv, Submission{LevelNumber: config.LevelNumber, Part: p.Name, UserEmail: userEmail, Answer: answer, Expected: p.Answer})
+		if err != nil {
+			return nil, false, err
+		}
+		if verdict.Correct {
+			part = &p
+			break
+		}
+	}
+	if part == nil {
+		return nil, false, nil
+	}
+
+	now := time.Now().UTC()
+	_, err = db.Exec("INSERT OR IGNORE INTO part_completions (user_email, level_number, part_name, practice, completed_at) VALUES (?, ?, ?, ?, ?)",
+		userEmail, config.LevelNumber, part.Name, practice, now)
+	if err != nil {
+		return nil, false, err
+	}
+	solved[part.Name] = now
+	if !practice {
+		refreshLeaderboardPlayer(userEmail)
+	}
+
+	complete = true
+	for _, p := range config.Parts {
+		if _, ok := solved[p.Name]; p.Required && !ok {
+			complete = false
+			break
+		}
+	}
+	return part, complete, nil
+}
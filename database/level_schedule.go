@@ -0,0 +1,217 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Level publication states. Only published levels are active and visible
+// to players; scheduled levels become published at their publish time.
+const (
+	LevelDraft     = "draft"
+	LevelScheduled = "scheduled"
+	LevelPublished = "published"
+)
+
+type LevelSchedule struct {
+	LevelNumber int        `json:"levelNumber"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publishAt,omitempty"`
+}
+
+func GetLevelSchedule(levelNumber int) (*LevelSchedule, error) {
+	var s LevelSchedule
+	var publishAt sql.NullTime
+	err := db.QueryRow("SELECT level_number, status, publish_at FROM levels WHERE level_number = ?", levelNumber).Scan(&s.LevelNumber, &s.Status, &publishAt)
+	if err != nil {
+		return nil, err
+	}
+	if publishAt.Valid {
+		t := publishAt.Time
+		s.PublishAt = &t
+	}
+	return &s, nil
+}
+
+func GetLevelSchedules() (map[int]LevelSchedule, error) {
+	rows, err := db.Query("SELECT level_number, status, publish_at FROM levels")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := map[int]LevelSchedule{}
+	for rows.Next() {
+		var s LevelSchedule
+		var publishAt sql.NullTime
+		if err := rows.Scan(&s.LevelNumber, &s.Status, &publishAt); err != nil {
+			return nil, err
+		}
+		if publishAt.Valid {
+			t := publishAt.Time
+			s.PublishAt = &t
+		}
+		schedules[s.LevelNumber] = s
+	}
+	return schedules, nil
+}
+
+// SetLevelStatus moves a level between draft, scheduled and published,
+// keeping the active flag the player queries rely on in step.
+func SetLevelStatus(levelNumber int, status string, publishAt *time.Time) error {
+	var publishValue interface{}
+	active := false
+
+	switch status {
+	case LevelDraft:
+	case LevelScheduled:
+		if publishAt == nil {
+			return fmt.Errorf("publishAt is required to schedule a level")
+		}
+		if !publishAt.After(time.Now()) {
+			return fmt.Errorf("publishAt must be in the future")
+		}
+		publishValue = publishAt.UTC()
+	case LevelPublished:
+		active = true
+		publishValue = time.Now().UTC()
+	default:
+		return fmt.Errorf("invalid level status: %s", status)
+	}
+
+	result, err := db.Exec("UPDATE levels SET status = ?, publish_at = ?, active = ? WHERE level_number = ?", status, publishValue, active, levelNumber)
+	if err != nil {
+		return err
+	}
+	maxLevelCache.invalidate()
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("level %d not found", levelNumber)
+	}
+	return nil
+}
+
+// PublishDueLevels publishes every scheduled level whose publish time has
+// passed and announces each wave once. It returns the published levels.
+func PublishDueLevels() ([]int, error) {
+	now := time.Now().UTC()
+
+	rows, err := db.Query("SELECT level_number FROM levels WHERE status = ? AND publish_at <= ?", LevelScheduled, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var due []int
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, n)
+	}
+	rows.Close()
+
+	if len(due) == 0 {
+		return nil, nil
+	}
+	sort.Ints(due)
+
+	var published []int
+	for _, n := range due {
+		result, err := db.Exec("UPDATE levels SET status = ?, active = TRUE WHERE level_number = ? AND status = ?", LevelPublished, n, LevelScheduled)
+		if err != nil {
+			log.Printf("ERROR: Failed to publish level %d: %v", n, err)
+			continue
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+			published = append(published, n)
+		}
+	}
+
+	if len(published) == 0 {
+		return nil, nil
+	}
+	maxLevelCache.invalidate()
+
+	// Each event's players only hear about their own levels
+	byEvent := map[int][]string{}
+	var eventIDs []int
+	for _, n := range published {
+		eventID := EventIDForLevel(n)
+		if _, ok := byEvent[eventID]; !ok {
+			eventIDs = append(eventIDs, eventID)
+		}
+		byEvent[eventID] = append(byEvent[eventID], strconv.Itoa(n))
+	}
+
+	for _, eventID := range eventIDs {
+		names := byEvent[eventID]
+		var heading string
+		if len(names) == 1 {
+			heading = fmt.Sprintf("Level %s is now live!", names[0])
+		} else {
+			heading = fmt.Sprintf("Levels %s are now live!", strings.Join(names, ", "))
+		}
+		if err := CreateEventAnnouncement(eventID, heading); err != nil {
+			log.Printf("ERROR: Failed to announce published levels: %v", err)
+		}
+	}
+
+	log.Printf("INFO: Published scheduled levels %v", published)
+	return published, nil
+}
+
+// migrateLevelSchedule adds the status columns to databases created before
+// levels had a publication workflow, deriving status from the active flag.
+func migrateLevelSchedule() {
+	if !columnExists("levels", "status") {
+		db.Exec("ALTER TABLE levels ADD COLUMN status TEXT DEFAULT 'published'")
+		db.Exec("UPDATE levels SET status = CASE WHEN active THEN 'published' ELSE 'draft' END")
+	}
+	if !columnExists("levels", "publish_at") {
+		db.Exec("ALTER TABLE levels ADD COLUMN publish_at DATETIME")
+	}
+}
+
+// levelStatusFromActive derives a level's status when the active flag is
+// toggled directly: activating publishes it, deactivating a published level
+// returns it to draft, and a pending schedule is otherwise left alone.
+const levelStatusFromActive = `CASE WHEN ? THEN 'published' WHEN status = 'published' THEN 'draft' ELSE status END`
+
+// GetLevelPreview builds the player view of a level regardless of its
+// publication status, for admins checking drafts before release.
+func GetLevelPreview(levelNumber int, viewerEmail string) (*GameLevel, error) {
+	var markdown sql.NullString
+	err := db.QueryRow("SELECT markdown FROM levels WHERE level_number = ?", levelNumber).Scan(&markdown)
+	if err != nil {
+		return nil, err
+	}
+
+	gameLevel := &GameLevel{
+		ID:          levelNumber,
+		Number:      levelNumber,
+		Description: markdown.String,
+		Markdown:    markdown.String,
+	}
+
+	attachments, err := GetAttachmentLinks(levelNumber, viewerEmail)
+	if err != nil {
+		log.Printf("WARNING: Failed to load attachments for level %d: %v", levelNumber, err)
+	} else if len(attachments) > 0 {
+		gameLevel.Attachments = attachments
+		gameLevel.MediaURL = attachments[0].URL
+		gameLevel.MediaType = attachments[0].ContentType
+	}
+	renderLevelHTML(gameLevel, viewerEmail)
+
+	return gameLevel, nil
+}
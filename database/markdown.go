@@ -0,0 +1,136 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// attachmentScheme lets level authors reference an attachment by filename
+// or ID, e.g. ![map](attachment:map.png). References are swapped for the
+// viewer's signed link after the shared rendering is taken from the cache.
+const attachmentScheme = "attachment"
+
+var (
+	markdownRenderer = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+	markdownPolicy = newMarkdownPolicy()
+
+	attachmentRefPattern = regexp.MustCompile(`(src|href)="` + attachmentScheme + `:([^"]*)"`)
+
+	renderCacheMu sync.RWMutex
+	renderCache   = map[int]renderedLevel{}
+)
+
+type renderedLevel struct {
+	revision string
+	html     string
+}
+
+// newMarkdownPolicy allows the usual user-generated content elements plus
+// fenced code language classes and attachment references. Raw HTML in
+// level Markdown passes through the same allowlist.
+func newMarkdownPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowURLSchemes("mailto", "http", "https", attachmentScheme)
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowElements("details", "summary")
+	return p
+}
+
+// RenderMarkdown converts Markdown to sanitized HTML. Attachment references
+// are left unresolved.
+func RenderMarkdown(markdown string) string {
+	var buf bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(markdown), &buf); err != nil {
+		log.Printf("WARNING: Failed to render markdown: %v", err)
+		return html.EscapeString(markdown)
+	}
+	return markdownPolicy.Sanitize(buf.String())
+}
+
+// markdownRevision identifies a revision of level content.
+func markdownRevision(markdown string) string {
+	sum := sha256.Sum256([]byte(markdown))
+	return hex.EncodeToString(sum[:8])
+}
+
+// renderLevelMarkdown returns the sanitized HTML for a level's current
+// content, rendering it only when the level has changed since it was cached.
+func renderLevelMarkdown(levelNumber int, markdown string) (string, string) {
+	revision := markdownRevision(markdown)
+
+	renderCacheMu.RLock()
+	cached, ok := renderCache[levelNumber]
+	renderCacheMu.RUnlock()
+	if ok && cached.revision == revision {
+		return cached.html, revision
+	}
+
+	rendered := RenderMarkdown(markdown)
+
+	renderCacheMu.Lock()
+	renderCache[levelNumber] = renderedLevel{revision: revision, html: rendered}
+	renderCacheMu.Unlock()
+
+	return rendered, revision
+}
+
+// resolveAttachmentRefs points attachment references at the viewer's signed
+// links. References to missing attachments are emptied.
+func resolveAttachmentRefs(rendered string, links []AttachmentLink) string {
+	if !strings.Contains(rendered, attachmentScheme+":") {
+		return rendered
+	}
+
+	return attachmentRefPattern.ReplaceAllStringFunc(rendered, func(match string) string {
+		parts := attachmentRefPattern.FindStringSubmatch(match)
+		ref, err := url.PathUnescape(html.UnescapeString(parts[2]))
+		if err != nil {
+			ref = parts[2]
+		}
+		for _, link := range links {
+			if link.Filename == ref || strconv.Itoa(link.ID) == ref {
+				return parts[1] + `="` + html.EscapeString(link.URL) + `"`
+			}
+		}
+		return parts[1] + `=""`
+	})
+}
+
+// renderLevelHTML fills in the rendered HTML for a viewer's copy of a level.
+func renderLevelHTML(level *GameLevel, viewerEmail string) {
+	rendered, revision := renderLevelMarkdown(level.Number, level.Markdown)
+	level.HTML = resolveAttachmentRefs(rendered, level.Attachments)
+	level.Revision = revision
+	applyPlayerToken(level, viewerEmail)
+}
+
+// RenderMarkdownPreview renders unsaved level content for an admin, with
+// attachment references resolved against the level's attachments when a
+// level number is given.
+func RenderMarkdownPreview(markdown string, levelNumber int, viewerEmail string) string {
+	rendered := RenderMarkdown(markdown)
+	if levelNumber <= 0 {
+		return rendered
+	}
+	links, err := GetAttachmentLinks(levelNumber, viewerEmail)
+	if err != nil {
+		return rendered
+	}
+	return resolveAttachmentRefs(rendered, links)
+}
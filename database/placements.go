@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// LevelPlacement records an admin moving a player to a level directly,
+// along with the completions that were filled in or taken away.
+type LevelPlacement struct {
+	ID                 int       `json:"id"`
+	UserEmail          string    `json:"userEmail"`
+	FromLevel          int       `json:"fromLevel"`
+	ToLevel            int       `json:"toLevel"`
+	Reason             string    `json:"reason"`
+	PlacedBy           string    `json:"placedBy"`
+	PlacedAt           time.Time `json:"placedAt"`
+	CompletionsAdded   int       `json:"completionsAdded"`
+	CompletionsRemoved int       `json:"completionsRemoved"`
+}
+
+// migratePlacements marks the completions placements fill in, so they are
+// not mistaken for real solves. Completions from placements made before the
+// column existed are matched to their placement by time.
+func migratePlacements() {
+	if columnExists("level_completions", "placed") {
+		return
+	}
+	if _, err := db.Exec("ALTER TABLE level_completions ADD COLUMN placed BOOLEAN DEFAULT FALSE"); err != nil {
+		log.Printf("ERROR: Failed to add placed column to level_completions: %v", err)
+		return
+	}
+	_, err := db.Exec(`UPDATE level_completions SET placed = TRUE WHERE EXISTS (
+		SELECT 1 FROM level_placements p
+		WHERE p.user_email = level_completions.user_email
+			AND p.completions_added > 0
+			AND level_completions.level_number < p.to_level
+			AND ABS(strftime('%s', level_completions.completed_at) - strftime('%s', substr(p.placed_at, 1, 19))) <= 1)`)
+	if err != nil {
+		log.Printf("ERROR: Failed to mark placed completions: %v", err)
+	}
+}
+
+// PlacePlayer moves a player onto any level in their range. Every existing
+// level before the target is marked completed, completions from the target
+// onwards are removed, and the move is kept in the placement audit trail.
+// The completions it fills in are flagged as placed so analytics, collusion
+// checks and badges only count real solves.
+func PlacePlayer(userEmail string, level int, reason, actor string) (*LevelPlacement, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required")
+	}
+
+	first, last, _, err := playerLevelRange(userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxLevel sql.NullInt64
+	err = db.QueryRow("SELECT MAX(level_number) FROM levels WHERE level_number BETWEEN ? AND ?", first, last).Scan(&maxLevel)
+	if err != nil {
+		return nil, err
+	}
+	if !maxLevel.Valid {
+		return nil, fmt.Errorf("there are no levels in this player's range")
+	}
+	if level < first || level > int(maxLevel.Int64)+1 {
+		return nil, fmt.Errorf("level must be between %d and %d", first, maxLevel.Int64+1)
+	}
+	if level <= int(maxLevel.Int64) {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM levels WHERE level_number = ?)", level).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("level %d does not exist", level)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	placement := &LevelPlacement{
+		UserEmail: userEmail,
+		ToLevel:   level,
+		Reason:    reason,
+		PlacedBy:  actor,
+		PlacedAt:  time.Now().UTC(),
+	}
+
+	err = tx.QueryRow("SELECT \"on\" FROM logins WHERE gmail = ?", userEmail).Scan(&placement.FromLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec("DELETE FROM level_completions WHERE user_email = ? AND level_number BETWEEN ? AND ?", userEmail, level, last)
+	if err != nil {
+		return nil, err
+	}
+	removed, _ := result.RowsAffected()
+	placement.CompletionsRemoved = int(removed)
+
+	// Parts of the target level and beyond start over as well
+	_, err = tx.Exec("DELETE FROM part_completions WHERE user_email = ? AND level_number BETWEEN ? AND ? AND practice = FALSE", userEmail, level, last)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = tx.Exec(`INSERT OR IGNORE INTO level_completions (user_email, level_number, placed)
+		SELECT ?, level_number, TRUE FROM levels WHERE level_number BETWEEN ? AND ?`, userEmail, first, level-1)
+	if err != nil {
+		return nil, err
+	}
+	added, _ := result.RowsAffected()
+	placement.CompletionsAdded = int(added)
+
+	_, err = tx.Exec("UPDATE logins SET \"on\" = ? WHERE gmail = ?", level, userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = tx.Exec(`INSERT INTO level_placements (user_email, from_level, to_level, reason, placed_by, placed_at, completions_added, completions_removed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userEmail, placement.FromLevel, level, reason, actor, placement.PlacedAt, placement.CompletionsAdded, placement.CompletionsRemoved)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	placement.ID = int(id)
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	refreshLeaderboardPlayer(userEmail)
+
+	log.Printf("INFO: %s placed %s on level %d (was %d, +%d/-%d completions): %s",
+		actor, userEmail, level, placement.FromLevel, placement.CompletionsAdded, placement.CompletionsRemoved, reason)
+
+	Create("notification", map[string]interface{}{
+		"userEmail": userEmail,
+		"message":   fmt.Sprintf("An administrator moved you to Level %d. Reason: %s", level, reason),
+		"type":      "info",
+	})
+
+	return placement, nil
+}
+
+// GetLevelPlacements returns placements for one player, or for everyone
+// when userEmail is empty, newest first.
+func GetLevelPlacements(userEmail string) ([]LevelPlacement, error) {
+	query := `SELECT id, user_email, from_level, to_level, reason, placed_by, placed_at, completions_added, completions_removed
+		FROM level_placements`
+	args := []interface{}{}
+	if userEmail != "" {
+		query += " WHERE user_email = ?"
+		args = append(args, userEmail)
+	}
+	query += " ORDER BY placed_at DESC, id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	placements := []LevelPlacement{}
+	for rows.Next() {
+		var p LevelPlacement
+		err := rows.Scan(&p.ID, &p.UserEmail, &p.FromLevel, &p.ToLevel, &p.Reason, &p.PlacedBy, &p.PlacedAt,
+			&p.CompletionsAdded, &p.CompletionsRemoved)
+		if err != nil {
+			return nil, err
+		}
+		placements = append(placements, p)
+	}
+	return placements, nil
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"intrasudo25/config"
+	"strings"
+	"time"
+)
+
+const practiceModeSetting = "practice_mode"
+
+// PracticeLevel is a player's practice standing on one published level.
+// Practice progress lives apart from logins and level_completions so the
+// final leaderboard stays frozen.
+type PracticeLevel struct {
+	Number   int        `json:"number"`
+	Solved   bool       `json:"solved"`
+	Attempts int        `json:"attempts"`
+	SolvedAt *time.Time `json:"solvedAt,omitempty"`
+}
+
+func IsPracticeModeEnabled() bool {
+	result, err := Get("system_setting", map[string]interface{}{"key": practiceModeSetting})
+	if err != nil {
+		return false
+	}
+	setting, ok := result.(*SystemSetting)
+	return ok && setting.Value == "true"
+}
+
+// IsPracticeModeActive reports whether players may practice right now:
+// admins have switched it on and the countdown says the competition is over.
+func IsPracticeModeActive() bool {
+	return IsPracticeModeEnabled() && config.IsCountdownEnabled() && time.Now().After(config.GetCompetitionEndTime())
+}
+
+func SetPracticeMode(enabled bool) error {
+	value := "false"
+	if enabled {
+		value = "true"
+	}
+	if _, err := Get("system_setting", map[string]interface{}{"key": practiceModeSetting}); err == sql.ErrNoRows {
+		return Create("system_setting", map[string]interface{}{"key": practiceModeSetting, "value": value})
+	}
+	return Update("system_setting", map[string]interface{}{"key": practiceModeSetting}, map[string]interface{}{"value": value})
+}
+
+func GetPracticeLevels(userEmail string) ([]PracticeLevel, error) {
+	rows, err := db.Query(`SELECT l.level_number, COALESCE(p.attempts, 0), p.solved_at FROM levels l
+		LEFT JOIN practice_progress p ON p.level_number = l.level_number AND p.user_email = ?
+		WHERE l.active = 1 ORDER BY l.level_number`, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := []PracticeLevel{}
+	for rows.Next() {
+		var level PracticeLevel
+		var solvedAt sql.NullTime
+		if err := rows.Scan(&level.Number, &level.Attempts, &solvedAt); err != nil {
+			return nil, err
+		}
+		if solvedAt.Valid {
+			t := solvedAt.Time
+			level.Solved = true
+			level.SolvedAt = &t
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// GetPracticeLevel returns the player view of any published level.
+func GetPracticeLevel(levelNumber int, userEmail string) (*GameLevel, error) {
+	var active bool
+	err := db.QueryRow("SELECT active FROM levels WHERE level_number = ?", levelNumber).Scan(&active)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, sql.ErrNoRows
+	}
+	level, err := GetLevelPreview(levelNumber, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	level.Parts, err = GetLevelPartStatuses(levelNumber, userEmail, true)
+	if err != nil {
+		return nil, err
+	}
+	return level, nil
+}
+
+// CheckPracticeAnswer checks an answer against a published level the way
+// CheckAnswer would and records the attempt in the player's practice
+// progress only.
+func CheckPracticeAnswer(userEmail string, levelNumber int, answer string) (*SubmitAnswerResult, error) {
+	var correctAnswer string
+	var answerMode, answerSecret sql.NullString
+	err := db.QueryRow("SELECT answer, answer_mode, answer_secret FROM levels WHERE level_number = ? AND active = 1", levelNumber).Scan(&correctAnswer, &answerMode, &answerSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, rejected, err := precheckAnswer(levelNumber, answer)
+	if err != nil {
+		return nil, err
+	}
+	if rejected != nil {
+		return rejected, nil
+	}
+	answer = strings.TrimSpace(answer)
+
+	partsConfig, err := GetLevelPartsConfig(levelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var correct bool
+	var solvedPart string
+	if len(partsConfig.Parts) > 0 {
+		part, complete, err := solveLevelPart(partsConfig, validator, userEmail, answer, true)
+		if errors.Is(err, ErrCheckerUnavailable) {
+			return checkerUnavailableResult(userEmail, levelNumber, err), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part != nil {
+			solvedPart = part.Name
+		}
+		correct = complete
+	} else {
+		verdict, err := matchAnswer(validator, Submission{
+			LevelNumber: levelNumber,
+			UserEmail:   userEmail,
+			Answer:      answer,
+			Expected:    expectedAnswer(levelNumber, correctAnswer, answerMode, answerSecret, userEmail),
+		})
+		if err != nil {
+			return checkerUnavailableResult(userEmail, levelNumber, err), nil
+		}
+		correct = verdict.Correct
+	}
+
+	var solvedAt interface{}
+	if correct {
+		solvedAt = time.Now().UTC()
+	}
+	_, err = db.Exec(`INSERT INTO practice_progress (user_email, level_number, attempts, solved_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT(user_email, level_number) DO UPDATE SET attempts = attempts + 1, solved_at = COALESCE(solved_at, excluded.solved_at)`,
+		userEmail, levelNumber, solvedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if correct {
+		return &SubmitAnswerResult{Correct: true, Message: "Correct! Practice progress saved.", Part: solvedPart}, nil
+	}
+	if solvedPart != "" {
+		parts, _ := GetLevelPartStatuses(levelNumber, userEmail, true)
+		return &SubmitAnswerResult{Correct: true, Message: fmt.Sprintf("Part %s solved! Keep going.", solvedPart), Part: solvedPart, Parts: parts}, nil
+	}
+	return &SubmitAnswerResult{Correct: false, Message: "Incorrect answer. Try again!"}, nil
+}
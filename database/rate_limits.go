@@ -0,0 +1,213 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"intrasudo25/config"
+	"log"
+	"math"
+	"time"
+)
+
+// SubmissionLimit caps how many answers a player may submit for a level in
+// a sliding window. Repeatedly hitting the cap locks the player out for
+// BaseLockoutSeconds, doubling with each strike up to MaxLockoutSeconds.
+// Level 0 holds the defaults used by levels without an override.
+type SubmissionLimit struct {
+	LevelNumber        int `json:"levelNumber"`
+	MaxAttempts        int `json:"maxAttempts"`
+	WindowSeconds      int `json:"windowSeconds"`
+	BaseLockoutSeconds int `json:"baseLockoutSeconds"`
+	MaxLockoutSeconds  int `json:"maxLockoutSeconds"`
+	AlertAfterStrikes  int `json:"alertAfterStrikes"`
+}
+
+type SubmissionLockout struct {
+	UserEmail    string    `json:"userEmail"`
+	LevelNumber  int       `json:"levelNumber"`
+	Strikes      int       `json:"strikes"`
+	LockedUntil  time.Time `json:"lockedUntil"`
+	LastStrikeAt time.Time `json:"lastStrikeAt"`
+}
+
+type RateLimitStatus struct {
+	Allowed    bool
+	RetryAfter int
+	RetryAt    time.Time
+	Strikes    int
+}
+
+// Strikes are forgiven once a player has gone this long without tripping
+// the limit again.
+const strikeDecay = time.Hour
+
+var defaultSubmissionLimit = SubmissionLimit{
+	MaxAttempts:        10,
+	WindowSeconds:      60,
+	BaseLockoutSeconds: 30,
+	MaxLockoutSeconds:  3600,
+	AlertAfterStrikes:  3,
+}
+
+// GetSubmissionLimit returns the effective limit for a level, falling back
+// to the stored default and then the built-in one.
+func GetSubmissionLimit(levelNumber int) SubmissionLimit {
+	for _, level := range []int{levelNumber, 0} {
+		var l SubmissionLimit
+		err := db.QueryRow("SELECT level_number, max_attempts, window_seconds, base_lockout_seconds, max_lockout_seconds, alert_after_strikes FROM submission_limits WHERE level_number = ?", level).
+			Scan(&l.LevelNumber, &l.MaxAttempts, &l.WindowSeconds, &l.BaseLockoutSeconds, &l.MaxLockoutSeconds, &l.AlertAfterStrikes)
+		if err == nil {
+			return l
+		}
+	}
+	l := defaultSubmissionLimit
+	l.LevelNumber = 0
+	return l
+}
+
+func SetSubmissionLimit(l SubmissionLimit) error {
+	if l.MaxAttempts <= 0 || l.WindowSeconds <= 0 || l.BaseLockoutSeconds <= 0 || l.MaxLockoutSeconds < l.BaseLockoutSeconds {
+		return fmt.Errorf("limits must be positive and maxLockoutSeconds must be at least baseLockoutSeconds")
+	}
+	_, err := db.Exec(`INSERT INTO submission_limits (level_number, max_attempts, window_seconds, base_lockout_seconds, max_lockout_seconds, alert_after_strikes)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT(level_number) DO UPDATE SET max_attempts = excluded.max_attempts, window_seconds = excluded.window_seconds,
+		base_lockout_seconds = excluded.base_lockout_seconds, max_lockout_seconds = excluded.max_lockout_seconds, alert_after_strikes = excluded.alert_after_strikes`,
+		l.LevelNumber, l.MaxAttempts, l.WindowSeconds, l.BaseLockoutSeconds, l.MaxLockoutSeconds, l.AlertAfterStrikes)
+	return err
+}
+
+func DeleteSubmissionLimit(levelNumber int) error {
+	_, err := db.Exec("DELETE FROM submission_limits WHERE level_number = ?", levelNumber)
+	return err
+}
+
+// CheckSubmissionRateLimit decides whether a player may submit another
+// answer for a level right now. An allowed attempt is reserved in the same
+// statement that counts the window, so concurrent submissions can not all
+// slip under the limit. An attempt over the limit registers a strike and
+// lockout instead.
+func CheckSubmissionRateLimit(userEmail string, levelNumber int) (*RateLimitStatus, error) {
+	now := time.Now().UTC()
+	limit := GetSubmissionLimit(levelNumber)
+
+	lockout, err := getSubmissionLockout(userEmail, levelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockout != nil && now.Before(lockout.LockedUntil) {
+		return &RateLimitStatus{
+			Allowed:    false,
+			RetryAfter: int(math.Ceil(lockout.LockedUntil.Sub(now).Seconds())),
+			RetryAt:    lockout.LockedUntil,
+			Strikes:    lockout.Strikes,
+		}, nil
+	}
+
+	windowStart := now.Add(-time.Duration(limit.WindowSeconds) * time.Second)
+	result, err := db.Exec(`INSERT INTO submission_attempts (user_email, level_number, attempted_at)
+		SELECT ?, ?, ? WHERE (SELECT COUNT(*) FROM submission_attempts
+			WHERE user_email = ? AND level_number = ? AND attempted_at > ?) < ?`,
+		userEmail, levelNumber, now, userEmail, levelNumber, windowStart, limit.MaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if reserved, _ := result.RowsAffected(); reserved > 0 {
+		// Attempts that have left the window are never counted again
+		_, err := db.Exec("DELETE FROM submission_attempts WHERE user_email = ? AND level_number = ? AND attempted_at <= ?",
+			userEmail, levelNumber, windowStart)
+		if err != nil {
+			log.Printf("WARNING: Failed to prune submission attempts for %s: %v", userEmail, err)
+		}
+		return &RateLimitStatus{Allowed: true}, nil
+	}
+
+	strikes := 1
+	if lockout != nil && now.Sub(lockout.LastStrikeAt) < strikeDecay {
+		strikes = lockout.Strikes + 1
+	}
+
+	backoff := float64(limit.BaseLockoutSeconds) * math.Pow(2, float64(strikes-1))
+	backoff = math.Min(backoff, float64(limit.MaxLockoutSeconds))
+	lockedUntil := now.Add(time.Duration(backoff) * time.Second)
+
+	_, err = db.Exec(`INSERT INTO submission_lockouts (user_email, level_number, strikes, locked_until, last_strike_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_email, level_number) DO UPDATE SET strikes = excluded.strikes, locked_until = excluded.locked_until, last_strike_at = excluded.last_strike_at`,
+		userEmail, levelNumber, strikes, lockedUntil, now)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("WARNING: %s hit the submission limit on level %d (strike %d, locked for %.0fs)", userEmail, levelNumber, strikes, backoff)
+
+	if limit.AlertAfterStrikes > 0 && strikes >= limit.AlertAfterStrikes {
+		alertAdmins(fmt.Sprintf("%s keeps hitting the submission limit on level %d (strike %d, locked until %s)",
+			userEmail, levelNumber, strikes, lockedUntil.Format(time.RFC3339)))
+	}
+
+	return &RateLimitStatus{
+		Allowed:    false,
+		RetryAfter: int(backoff),
+		RetryAt:    lockedUntil,
+		Strikes:    strikes,
+	}, nil
+}
+
+func GetActiveSubmissionLockouts() ([]SubmissionLockout, error) {
+	rows, err := db.Query("SELECT user_email, level_number, strikes, locked_until, last_strike_at FROM submission_lockouts WHERE last_strike_at > ? ORDER BY locked_until DESC",
+		time.Now().UTC().Add(-strikeDecay))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := []SubmissionLockout{}
+	for rows.Next() {
+		var l SubmissionLockout
+		if err := rows.Scan(&l.UserEmail, &l.LevelNumber, &l.Strikes, &l.LockedUntil, &l.LastStrikeAt); err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, l)
+	}
+	return lockouts, nil
+}
+
+func ClearSubmissionLockouts(userEmail string) error {
+	_, err := db.Exec("DELETE FROM submission_lockouts WHERE user_email = ?", userEmail)
+	return err
+}
+
+func getSubmissionLockout(userEmail string, levelNumber int) (*SubmissionLockout, error) {
+	var l SubmissionLockout
+	err := db.QueryRow("SELECT user_email, level_number, strikes, locked_until, last_strike_at FROM submission_lockouts WHERE user_email = ? AND level_number = ?", userEmail, levelNumber).
+		Scan(&l.UserEmail, &l.LevelNumber, &l.Strikes, &l.LockedUntil, &l.LastStrikeAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// alertAdmins leaves a notification for every configured admin.
+func alertAdmins(message string) {
+	for _, email := range config.GetAdminEmails() {
+		if email == "" {
+			continue
+		}
+		Create("notification", map[string]interface{}{
+			"userEmail": email,
+			"message":   message,
+			"type":      "alert",
+		})
+	}
+}
+
+func recordSubmission(userEmail string, levelNumber int, answer string, correct bool) {
+	_, err := db.Exec("INSERT INTO submissions (user_email, level_number, answer, correct, submitted_at) VALUES (?, ?, ?, ?, ?)",
+		userEmail, levelNumber, answer, correct, time.Now().UTC())
+	if err != nil {
+		log.Printf("ERROR: Failed to record submission for %s: %v", userEmail, err)
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// kindRegistry maps the kinds an admin can pick, such as answer validators
+// or badge rules, to constructors that build one from its JSON settings.
+type kindRegistry[T any] struct {
+	// noun names a kind in errors, for example "validator".
+	noun      string
+	factories map[string]func(settings json.RawMessage) (T, error)
+}
+
+// kinds lists the registered kinds in a stable order.
+func (r kindRegistry[T]) kinds() []string {
+	kinds := make([]string, 0, len(r.factories))
+	for kind := range r.factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (r kindRegistry[T]) has(kind string) bool {
+	_, ok := r.factories[kind]
+	return ok
+}
+
+// build constructs a kind from its settings.
+func (r kindRegistry[T]) build(kind string, settings json.RawMessage) (T, error) {
+	factory, ok := r.factories[kind]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("unknown %s %q, expected one of %s", r.noun, kind, strings.Join(r.kinds(), ", "))
+	}
+	return factory(settings)
+}
+
+// decodeSettings reads a kind's JSON settings strictly, so a misspelt field
+// is an error rather than silently ignored. Missing settings mean all
+// defaults; what names the settings in errors.
+func decodeSettings(settings json.RawMessage, into interface{}, what string) error {
+	if len(settings) == 0 || string(settings) == "null" {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(settings)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(into); err != nil {
+		return fmt.Errorf("invalid %s: %v", what, err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Release modes for scheduled hints. "at" releases everywhere at a fixed
+// wall-clock time; "after_reach" releases per player a number of minutes
+// after they reached the level.
+const (
+	HintReleaseAt         = "at"
+	HintReleaseAfterReach = "after_reach"
+)
+
+type ScheduledHint struct {
+	ID           int        `json:"id"`
+	LevelNumber  int        `json:"levelNumber"`
+	Message      string     `json:"message"`
+	ReleaseMode  string     `json:"releaseMode"`
+	ReleaseAt    *time.Time `json:"releaseAt,omitempty"`
+	DelayMinutes int        `json:"delayMinutes,omitempty"`
+	CreatedBy    string     `json:"createdBy"`
+	CreatedAt    string     `json:"createdAt"`
+}
+
+type ScheduledHintStatus struct {
+	ScheduledHint
+	Status         string     `json:"status"`
+	PlayersOnLevel int        `json:"playersOnLevel"`
+	ReleasedFor    int        `json:"releasedFor"`
+	PendingFor     int        `json:"pendingFor"`
+	NextReleaseAt  *time.Time `json:"nextReleaseAt,omitempty"`
+}
+
+func CreateScheduledHint(levelNumber int, message, mode string, releaseAt *time.Time, delayMinutes int, createdBy string) (*ScheduledHint, error) {
+	switch mode {
+	case HintReleaseAt:
+		if releaseAt == nil {
+			return nil, fmt.Errorf("releaseAt is required for wall-clock hints")
+		}
+	case HintReleaseAfterReach:
+		if delayMinutes < 0 {
+			return nil, fmt.Errorf("delayMinutes cannot be negative")
+		}
+		releaseAt = nil
+	default:
+		return nil, fmt.Errorf("invalid release mode: %s", mode)
+	}
+
+	var releaseAtValue interface{}
+	if releaseAt != nil {
+		releaseAtValue = releaseAt.UTC()
+	}
+
+	result, err := db.Exec("INSERT INTO scheduled_hints (level_number, message, release_mode, release_at, delay_minutes, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+		levelNumber, message, mode, releaseAtValue, delayMinutes, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO: %s scheduled a %s hint for level %d", createdBy, mode, levelNumber)
+	return GetScheduledHint(int(id))
+}
+
+func GetScheduledHint(id int) (*ScheduledHint, error) {
+	row := db.QueryRow("SELECT id, level_number, message, release_mode, release_at, delay_minutes, created_by, created_at FROM scheduled_hints WHERE id = ?", id)
+	return scanScheduledHint(row)
+}
+
+func DeleteScheduledHint(id int) error {
+	result, err := db.Exec("DELETE FROM scheduled_hints WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("scheduled hint %d not found", id)
+	}
+	return nil
+}
+
+// GetScheduledHints returns every scheduled hint for a level, or for all
+// levels when levelNumber is 0.
+func GetScheduledHints(levelNumber int) ([]ScheduledHint, error) {
+	query := "SELECT id, level_number, message, release_mode, release_at, delay_minutes, created_by, created_at FROM scheduled_hints"
+	args := []interface{}{}
+	if levelNumber > 0 {
+		query += " WHERE level_number = ?"
+		args = append(args, levelNumber)
+	}
+	query += " ORDER BY level_number ASC, id ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hints := []ScheduledHint{}
+	for rows.Next() {
+		hint, err := scanScheduledHint(rows)
+		if err != nil {
+			return nil, err
+		}
+		hints = append(hints, *hint)
+	}
+	return hints, nil
+}
+
+// GetScheduledHintStatuses reports, for admins, which scheduled hints are
+// pending and which have been released, broken down per player for hints
+// that release relative to when a player reached the level.
+func GetScheduledHintStatuses(levelNumber int) ([]ScheduledHintStatus, error) {
+	hints, err := GetScheduledHints(levelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	statuses := []ScheduledHintStatus{}
+	reachedCache := map[int][]time.Time{}
+
+	for _, hint := range hints {
+		status := ScheduledHintStatus{ScheduledHint: hint}
+
+		if hint.ReleaseMode == HintReleaseAt {
+			if now.Before(*hint.ReleaseAt) {
+				status.Status = "pending"
+				status.NextReleaseAt = hint.ReleaseAt
+			} else {
+				status.Status = "released"
+			}
+			statuses = append(statuses, status)
+			continue
+		}
+
+		reached, ok := reachedCache[hint.LevelNumber]
+		if !ok {
+			reached, err = playersReachedAt(hint.LevelNumber)
+			if err != nil {
+				return nil, err
+			}
+			reachedCache[hint.LevelNumber] = reached
+		}
+
+		delay := time.Duration(hint.DelayMinutes) * time.Minute
+		status.PlayersOnLevel = len(reached)
+		for _, at := range reached {
+			release := at.Add(delay)
+			if now.Before(release) {
+				status.PendingFor++
+				if status.NextReleaseAt == nil || release.Before(*status.NextReleaseAt) {
+					next := release
+					status.NextReleaseAt = &next
+				}
+			} else {
+				status.ReleasedFor++
+			}
+		}
+
+		switch {
+		case status.PendingFor > 0 && status.ReleasedFor > 0:
+			status.Status = "partially_released"
+		case status.PendingFor > 0:
+			status.Status = "pending"
+		case status.ReleasedFor > 0:
+			status.Status = "released"
+		default:
+			status.Status = "waiting"
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// GetReleasedScheduledHints returns the scheduled hints a player on the
+// given level can currently see, shaped like relayed Discord hints so they
+// share the same feed.
+func GetReleasedScheduledHints(userEmail string, levelNumber int) ([]HintMessage, error) {
+	hints, err := GetScheduledHints(levelNumber)
+	if err != nil || len(hints) == 0 {
+		return []HintMessage{}, err
+	}
+
+	reachedAt, err := LevelReachedAt(userEmail, levelNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	released := []HintMessage{}
+	for _, hint := range hints {
+		var release time.Time
+		if hint.ReleaseMode == HintReleaseAt {
+			release = *hint.ReleaseAt
+		} else {
+			release = reachedAt.Add(time.Duration(hint.DelayMinutes) * time.Minute)
+		}
+		if now.Before(release) {
+			continue
+		}
+		released = append(released, HintMessage{
+			ID:          -hint.ID,
+			Message:     hint.Message,
+			LevelNumber: hint.LevelNumber,
+			Timestamp:   release.UTC().Format(time.RFC3339),
+			SentBy:      "scheduled",
+		})
+	}
+	return released, nil
+}
+
+// LevelReachedAt is when a player arrived on a level: the time they solved
+// the previous one, or the start of their event or the competition for the
+// first level in their range.
+func LevelReachedAt(userEmail string, levelNumber int) (time.Time, error) {
+	first, _, _, err := playerLevelRange(userEmail)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if levelNumber <= first {
+		return GetPlayerWindow(userEmail).Start, nil
+	}
+
+	var completedAt time.Time
+	err = db.QueryRow("SELECT completed_at FROM level_completions WHERE user_email = ? AND level_number = ?", userEmail, levelNumber-1).Scan(&completedAt)
+	if err == sql.ErrNoRows {
+		return GetPlayerWindow(userEmail).Start, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return completedAt, nil
+}
+
+func playersReachedAt(levelNumber int) ([]time.Time, error) {
+	users, err := Get("users_at_level", map[string]interface{}{"level": levelNumber})
+	if err != nil {
+		return nil, err
+	}
+
+	reached := []time.Time{}
+	if emails, ok := users.([]string); ok {
+		for _, email := range emails {
+			at, err := LevelReachedAt(email, levelNumber)
+			if err != nil {
+				continue
+			}
+			reached = append(reached, at)
+		}
+	}
+	return reached, nil
+}
+
+func scanScheduledHint(row rowScanner) (*ScheduledHint, error) {
+	var hint ScheduledHint
+	var releaseAt sql.NullTime
+	err := row.Scan(&hint.ID, &hint.LevelNumber, &hint.Message, &hint.ReleaseMode, &releaseAt, &hint.DelayMinutes, &hint.CreatedBy, &hint.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if releaseAt.Valid {
+		t := releaseAt.Time
+		hint.ReleaseAt = &t
+	}
+	return &hint, nil
+}
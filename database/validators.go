@@ -0,0 +1,370 @@
+package database
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Validator kinds a level can choose through the admin API.
+const (
+	ValidatorExact    = "exact"
+	ValidatorRegex    = "regex"
+	ValidatorNumeric  = "numeric"
+	ValidatorTokens   = "tokens"
+	ValidatorExternal = "external"
+)
+
+// Submission is what a validator gets to judge. Expected is the stored
+// answer for the level or part, already resolved for dynamic levels. For
+// file uploads Answer is the file's SHA-256 and File its path on disk.
+type Submission struct {
+	LevelNumber int    `json:"level"`
+	Part        string `json:"part,omitempty"`
+	UserEmail   string `json:"player"`
+	Answer      string `json:"answer"`
+	File        string `json:"file,omitempty"`
+	Expected    string `json:"-"`
+}
+
+// Validator decides whether a submission is correct. An error means the
+// answer could not be judged, not that it is wrong.
+type Validator interface {
+	Validate(sub Submission) (bool, error)
+}
+
+var validatorKinds = kindRegistry[Validator]{
+	noun: "validator",
+	factories: map[string]func(config json.RawMessage) (Validator, error){
+		ValidatorExact:    newExactValidator,
+		ValidatorRegex:    newRegexValidator,
+		ValidatorNumeric:  newNumericValidator,
+		ValidatorTokens:   newTokenSetValidator,
+		ValidatorExternal: newExternalValidator,
+	},
+}
+
+// ValidatorKinds lists the available validator kinds in a stable order.
+func ValidatorKinds() []string {
+	return validatorKinds.kinds()
+}
+
+// LevelValidator is a level's validator choice as stored on the level.
+type LevelValidator struct {
+	LevelNumber int             `json:"levelNumber"`
+	Kind        string          `json:"kind"`
+	Config      json.RawMessage `json:"config,omitempty"`
+}
+
+func migrateValidators() {
+	if !columnExists("levels", "validator") {
+		db.Exec("ALTER TABLE levels ADD COLUMN validator TEXT DEFAULT 'exact'")
+	}
+	if !columnExists("levels", "validator_config") {
+		db.Exec("ALTER TABLE levels ADD COLUMN validator_config TEXT")
+	}
+}
+
+func GetLevelValidator(levelNumber int) (*LevelValidator, error) {
+	lv := LevelValidator{LevelNumber: levelNumber}
+	var kind, config sql.NullString
+	err := db.QueryRow("SELECT validator, validator_config FROM levels WHERE level_number = ?", levelNumber).Scan(&kind, &config)
+	if err != nil {
+		return nil, err
+	}
+	lv.Kind = ValidatorExact
+	if validatorKinds.has(kind.String) {
+		lv.Kind = kind.String
+	}
+	if config.String != "" {
+		lv.Config = json.RawMessage(config.String)
+	}
+	return &lv, nil
+}
+
+// SetLevelValidator picks a level's validator. The configuration is checked
+// by building the validator before anything is stored.
+func SetLevelValidator(levelNumber int, kind string, config json.RawMessage) (*LevelValidator, error) {
+	lv := &LevelValidator{LevelNumber: levelNumber, Kind: kind, Config: config}
+	if _, err := lv.build(); err != nil {
+		return nil, err
+	}
+
+	if kind == ValidatorNumeric {
+		var answer string
+		if err := db.QueryRow("SELECT answer FROM levels WHERE level_number = ?", levelNumber).Scan(&answer); err != nil {
+			return nil, err
+		}
+		parts, err := getLevelParts(levelNumber)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(answer), 64); err != nil {
+				return nil, fmt.Errorf("numeric validator needs a numeric level answer")
+			}
+		}
+		for _, p := range parts {
+			if _, err := strconv.ParseFloat(p.Answer, 64); err != nil {
+				return nil, fmt.Errorf("numeric validator needs a numeric answer for part %s", p.Name)
+			}
+		}
+	}
+
+	var stored interface{}
+	if len(config) > 0 && string(config) != "null" {
+		stored = string(config)
+	}
+	result, err := db.Exec("UPDATE levels SET validator = ?, validator_config = ? WHERE level_number = ?", kind, stored, levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return GetLevelValidator(levelNumber)
+}
+
+func (lv *LevelValidator) build() (Validator, error) {
+	return validatorKinds.build(lv.Kind, lv.Config)
+}
+
+// strictFormat reports whether the no-spaces and lowercase-only rules apply.
+// They belong to the plain exact comparison players are told about; any
+// other validator defines the answer format itself.
+func (lv *LevelValidator) strictFormat() bool {
+	if lv.Kind != ValidatorExact {
+		return false
+	}
+	v, err := lv.build()
+	if err != nil {
+		return true
+	}
+	return v.(*exactValidator).plain()
+}
+
+// exactValidator compares answers after trimming, with optional extra
+// normalization.
+type exactValidator struct {
+	IgnoreCase        bool `json:"ignoreCase"`
+	IgnoreWhitespace  bool `json:"ignoreWhitespace"`
+	IgnorePunctuation bool `json:"ignorePunctuation"`
+}
+
+func newExactValidator(config json.RawMessage) (Validator, error) {
+	v := &exactValidator{}
+	if err := decodeSettings(config, v, "validator config"); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *exactValidator) plain() bool {
+	return !v.IgnoreCase && !v.IgnoreWhitespace && !v.IgnorePunctuation
+}
+
+func (v *exactValidator) normalize(s string) string {
+	s = strings.TrimSpace(s)
+	if v.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	if v.IgnoreWhitespace || v.IgnorePunctuation {
+		s = strings.Map(func(r rune) rune {
+			if v.IgnoreWhitespace && unicode.IsSpace(r) {
+				return -1
+			}
+			if v.IgnorePunctuation && (unicode.IsPunct(r) || unicode.IsSymbol(r)) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	return s
+}
+
+func (v *exactValidator) Validate(sub Submission) (bool, error) {
+	return v.normalize(sub.Answer) == v.normalize(sub.Expected), nil
+}
+
+// regexValidator accepts answers fully matching a pattern. Without a
+// configured pattern the stored answer is used as the pattern.
+type regexValidator struct {
+	Pattern    string `json:"pattern"`
+	IgnoreCase bool   `json:"ignoreCase"`
+	compiled   *regexp.Regexp
+}
+
+func newRegexValidator(config json.RawMessage) (Validator, error) {
+	v := &regexValidator{}
+	if err := decodeSettings(config, v, "validator config"); err != nil {
+		return nil, err
+	}
+	if v.Pattern != "" {
+		compiled, err := v.compile(v.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		v.compiled = compiled
+	}
+	return v, nil
+}
+
+func (v *regexValidator) compile(pattern string) (*regexp.Regexp, error) {
+	flags := ""
+	if v.IgnoreCase {
+		flags = "(?i)"
+	}
+	compiled, err := regexp.Compile(flags + `^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	return compiled, nil
+}
+
+func (v *regexValidator) Validate(sub Submission) (bool, error) {
+	compiled := v.compiled
+	if compiled == nil {
+		var err error
+		compiled, err = v.compile(strings.TrimSpace(sub.Expected))
+		if err != nil {
+			return false, err
+		}
+	}
+	return compiled.MatchString(strings.TrimSpace(sub.Answer)), nil
+}
+
+// numericValidator accepts numbers within an absolute tolerance of the
+// stored answer, or a fraction of it when Relative is set.
+type numericValidator struct {
+	Tolerance float64 `json:"tolerance"`
+	Relative  bool    `json:"relative"`
+}
+
+func newNumericValidator(config json.RawMessage) (Validator, error) {
+	v := &numericValidator{}
+	if err := decodeSettings(config, v, "validator config"); err != nil {
+		return nil, err
+	}
+	if v.Tolerance < 0 || math.IsNaN(v.Tolerance) {
+		return nil, fmt.Errorf("tolerance must not be negative")
+	}
+	return v, nil
+}
+
+func (v *numericValidator) Validate(sub Submission) (bool, error) {
+	expected, err := strconv.ParseFloat(strings.TrimSpace(sub.Expected), 64)
+	if err != nil {
+		return false, fmt.Errorf("stored answer %q is not a number", sub.Expected)
+	}
+	got, err := strconv.ParseFloat(strings.TrimSpace(sub.Answer), 64)
+	if err != nil || math.IsNaN(got) || math.IsInf(got, 0) {
+		return false, nil
+	}
+	tolerance := v.Tolerance
+	if v.Relative {
+		tolerance *= math.Abs(expected)
+	}
+	return math.Abs(got-expected) <= tolerance, nil
+}
+
+// tokenSetValidator splits answers on a separator and accepts them when
+// they hold the same set of tokens as the stored answer, in any order.
+type tokenSetValidator struct {
+	Separator  string `json:"separator"`
+	IgnoreCase bool   `json:"ignoreCase"`
+}
+
+func newTokenSetValidator(config json.RawMessage) (Validator, error) {
+	v := &tokenSetValidator{}
+	if err := decodeSettings(config, v, "validator config"); err != nil {
+		return nil, err
+	}
+	if v.Separator == "" {
+		v.Separator = ","
+	}
+	return v, nil
+}
+
+func (v *tokenSetValidator) tokens(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, token := range strings.Split(s, v.Separator) {
+		token = strings.TrimSpace(token)
+		if v.IgnoreCase {
+			token = strings.ToLower(token)
+		}
+		if token != "" {
+			set[token] = true
+		}
+	}
+	return set
+}
+
+func (v *tokenSetValidator) Validate(sub Submission) (bool, error) {
+	want, got := v.tokens(sub.Expected), v.tokens(sub.Answer)
+	if len(want) != len(got) {
+		return false, nil
+	}
+	for token := range want {
+		if !got[token] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// externalValidator asks a checker listening on a local Unix socket. It
+// writes the submission as one JSON line and expects {"correct": bool} back.
+// The expected answer is not sent; the checker owns the logic.
+type externalValidator struct {
+	Socket    string `json:"socket"`
+	TimeoutMs int    `json:"timeoutMs"`
+}
+
+func newExternalValidator(config json.RawMessage) (Validator, error) {
+	v := &externalValidator{}
+	if err := decodeSettings(config, v, "validator config"); err != nil {
+		return nil, err
+	}
+	if v.Socket == "" || !filepath.IsAbs(v.Socket) {
+		return nil, fmt.Errorf("external validator needs an absolute socket path")
+	}
+	if v.TimeoutMs <= 0 {
+		v.TimeoutMs = 2000
+	}
+	return v, nil
+}
+
+func (v *externalValidator) Validate(sub Submission) (bool, error) {
+	timeout := time.Duration(v.TimeoutMs) * time.Millisecond
+	conn, err := net.DialTimeout("unix", v.Socket, timeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := json.NewEncoder(conn).Encode(sub); err != nil {
+		return false, err
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return false, err
+	}
+	var reply struct {
+		Correct *bool `json:"correct"`
+	}
+	if err := json.Unmarshal(line, &reply); err != nil || reply.Correct == nil {
+		return false, fmt.Errorf("external checker sent an invalid reply")
+	}
+	return *reply.Correct, nil
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AnyMethod matches a virtual route regardless of request method.
+const AnyMethod = "*"
+
+// reservedRoutePrefixes are served by the application itself, so virtual
+// routes under them would never be reached.
+var reservedRoutePrefixes = []string{"/api/", "/admin", "/static/", "/assets/", "/css/", "/js/"}
+
+// forbiddenRouteHeaders would break the response or the player's session.
+var forbiddenRouteHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Set-Cookie":        true,
+}
+
+// VirtualRoute is a response an admin hides at a path for one level. It is
+// served only to players currently on that level; everyone else gets the
+// usual 404.
+type VirtualRoute struct {
+	ID          int               `json:"id"`
+	LevelNumber int               `json:"levelNumber"`
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Status      int               `json:"status"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	CreatedBy   string            `json:"createdBy"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+func validateVirtualRoute(route *VirtualRoute) error {
+	route.Method = strings.ToUpper(strings.TrimSpace(route.Method))
+	if route.Method == "" {
+		route.Method = http.MethodGet
+	}
+	switch route.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions, AnyMethod:
+	default:
+		return fmt.Errorf("unsupported method %q", route.Method)
+	}
+
+	route.Path = strings.TrimSpace(route.Path)
+	if !strings.HasPrefix(route.Path, "/") || route.Path == "/" {
+		return fmt.Errorf("path must start with / and name a resource")
+	}
+	if strings.ContainsAny(route.Path, "?# \t\r\n") {
+		return fmt.Errorf("path must not contain a query, fragment or whitespace")
+	}
+	for _, prefix := range reservedRoutePrefixes {
+		if strings.HasPrefix(route.Path, prefix) {
+			return fmt.Errorf("paths under %s are reserved", prefix)
+		}
+	}
+
+	if route.Status == 0 {
+		route.Status = http.StatusOK
+	}
+	if route.Status < 200 || route.Status > 599 {
+		return fmt.Errorf("status must be between 200 and 599")
+	}
+
+	headers := map[string]string{}
+	for name, value := range route.Headers {
+		canonical := http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if canonical == "" || strings.ContainsAny(canonical+value, "\r\n") {
+			return fmt.Errorf("invalid header %q", name)
+		}
+		if forbiddenRouteHeaders[canonical] {
+			return fmt.Errorf("header %s cannot be set", canonical)
+		}
+		headers[canonical] = value
+	}
+	route.Headers = headers
+	return nil
+}
+
+func CreateVirtualRoute(route VirtualRoute) (*VirtualRoute, error) {
+	if err := validateVirtualRoute(&route); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM levels WHERE level_number = ?)", route.LevelNumber).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	headers, err := json.Marshal(route.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.Exec(`INSERT INTO virtual_routes (level_number, method, path, status, headers, body, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		route.LevelNumber, route.Method, route.Path, route.Status, string(headers), route.Body, route.CreatedBy, time.Now().UTC())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("level %d already has a %s route at %s", route.LevelNumber, route.Method, route.Path)
+		}
+		return nil, err
+	}
+
+	id, _ := result.LastInsertId()
+	return GetVirtualRoute(int(id))
+}
+
+func UpdateVirtualRoute(route VirtualRoute) (*VirtualRoute, error) {
+	if err := validateVirtualRoute(&route); err != nil {
+		return nil, err
+	}
+
+	headers, err := json.Marshal(route.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.Exec("UPDATE virtual_routes SET method = ?, path = ?, status = ?, headers = ?, body = ? WHERE id = ? AND level_number = ?",
+		route.Method, route.Path, route.Status, string(headers), route.Body, route.ID, route.LevelNumber)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, fmt.Errorf("level %d already has a %s route at %s", route.LevelNumber, route.Method, route.Path)
+		}
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return GetVirtualRoute(route.ID)
+}
+
+func DeleteVirtualRoute(levelNumber, id int) error {
+	result, err := db.Exec("DELETE FROM virtual_routes WHERE id = ? AND level_number = ?", id, levelNumber)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const virtualRouteColumns = "id, level_number, method, path, status, headers, body, created_by, created_at"
+
+func scanVirtualRoute(row rowScanner) (*VirtualRoute, error) {
+	var route VirtualRoute
+	var headers string
+	err := row.Scan(&route.ID, &route.LevelNumber, &route.Method, &route.Path, &route.Status, &headers, &route.Body, &route.CreatedBy, &route.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	route.Headers = map[string]string{}
+	if headers != "" {
+		json.Unmarshal([]byte(headers), &route.Headers)
+	}
+	return &route, nil
+}
+
+func GetVirtualRoute(id int) (*VirtualRoute, error) {
+	return scanVirtualRoute(db.QueryRow("SELECT "+virtualRouteColumns+" FROM virtual_routes WHERE id = ?", id))
+}
+
+func GetVirtualRoutes(levelNumber int) ([]VirtualRoute, error) {
+	rows, err := db.Query("SELECT "+virtualRouteColumns+" FROM virtual_routes WHERE level_number = ? ORDER BY path, method", levelNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []VirtualRoute{}
+	for rows.Next() {
+		route, err := scanVirtualRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, *route)
+	}
+	return routes, nil
+}
+
+// MatchVirtualRoute finds the route a player should be served for a
+// request, if any. Only routes on the player's current, published level
+// match; an exact method wins over AnyMethod.
+func MatchVirtualRoute(userEmail, method, path string) (*VirtualRoute, error) {
+	row := db.QueryRow(`SELECT `+virtualRouteColumns+` FROM virtual_routes
+		WHERE path = ? AND (method = ? OR method = ?)
+			AND level_number = (SELECT "on" FROM logins WHERE gmail = ?)
+			AND level_number IN (SELECT level_number FROM levels WHERE active = 1)
+		ORDER BY method = ? ASC LIMIT 1`,
+		path, method, AnyMethod, userEmail, AnyMethod)
+	return scanVirtualRoute(row)
+}
@@ -100,6 +100,7 @@ function renderLevels(levels) {
                     </div>
                     <div class="level-actions-compact">
                         <button class="btn-secondary" onclick="toggleEditLevel(${level.id})">Edit</button>
+                        <a class="btn-secondary" href="/admin/levels/${level.number}/preview" target="_blank">Preview</a>
                         <button class="btn-danger" onclick="deleteLevel(${level.id})">Delete</button>
                     </div>
                 </div>
@@ -135,7 +136,8 @@ function renderLevels(levels) {
                     </div>
                     <div class="form-group">
                         <label class="form-label">Question:</label>
-                        <textarea class="form-input form-textarea edit-question-input" id="editQuestion_${level.id}" placeholder="Enter level question">${level.question || ''}</textarea>
+                        <textarea class="form-input form-textarea edit-question-input" id="editQuestion_${level.id}" placeholder="Enter level question (Markdown)" oninput="scheduleMarkdownPreview('editQuestion_${level.id}', 'editPreview_${level.id}', 'editNumber_${level.id}')">${level.question || ''}</textarea>
+                        <div id="editPreview_${level.id}" class="markdown-preview"></div>
                     </div>
                     <div class="form-group">
                         <label class="form-label">Answer:</label>
@@ -160,6 +162,40 @@ function renderLevels(levels) {
     }).join('');
 }
 
+const markdownPreviewTimers = {};
+
+// scheduleMarkdownPreview renders the question as players will see it once
+// the admin pauses typing.
+function scheduleMarkdownPreview(inputId, previewId, levelNumberId) {
+    clearTimeout(markdownPreviewTimers[previewId]);
+    markdownPreviewTimers[previewId] = setTimeout(async () => {
+        const input = document.getElementById(inputId);
+        const preview = document.getElementById(previewId);
+        if (!input || !preview) return;
+
+        const levelNumberInput = document.getElementById(levelNumberId);
+        try {
+            const response = await fetch('/api/admin/markdown/preview', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'CSRFtok': getCookie('X-CSRF_COOKIE') || userSession?.csrfToken || ''
+                },
+                body: JSON.stringify({
+                    markdown: input.value,
+                    levelNumber: parseInt(levelNumberInput?.value, 10) || 0
+                })
+            });
+            if (response.ok) {
+                const data = await response.json();
+                preview.innerHTML = data.html;
+            }
+        } catch (error) {
+            console.error('Failed to render preview:', error);
+        }
+    }, 300);
+}
+
 async function createLevel() {
     const levelNumber = document.getElementById('levelNumber').value;
     const levelQuestion = document.getElementById('levelQuestion').value.trim();
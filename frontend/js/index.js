@@ -2,6 +2,7 @@ let currentLevel = null;
 let userSession = null;
 let isSubmitting = false;
 let isRedirecting = false;
+let previewHint = '';
 
 async function initializePage() {
     if (isRedirecting) return;
@@ -80,7 +81,12 @@ async function checkAdminAccess() {
 
 async function loadCurrentLevel() {
     try {
-        const response = await fetch('/api/user/current-level?' + Date.now(), {
+        // Admin previews load any level from the preview endpoint instead of
+        // the admin's own progress
+        const levelUrl = window.previewLevel
+            ? `/api/admin/levels/${window.previewLevel}/preview?`
+            : '/api/user/current-level?';
+        const response = await fetch(levelUrl + Date.now(), {
             headers: {
                 'CSRFtok': getCookie('X-CSRF_COOKIE') || '',
                 'Cache-Control': 'no-cache'
@@ -91,7 +97,11 @@ async function loadCurrentLevel() {
             throw new Error(`API returned status ${response.status}`);
         }
 
-        const newLevel = await response.json();
+        const data = await response.json();
+        const newLevel = window.previewLevel ? data.level : data;
+        if (window.previewLevel) {
+            previewHint = data.hint || '';
+        }
         currentLevel = newLevel;
         updateLevelDisplay();
         updateHintsDisplay();
@@ -167,7 +177,11 @@ function updateLevelDisplay() {
     
     const levelQuestion = document.getElementById('levelQuestion');
     if (levelQuestion) {
-        if (currentLevel.markdown && currentLevel.markdown.trim()) {
+        if (currentLevel.html && currentLevel.html.trim()) {
+            // Rendered and sanitized by the server
+            levelQuestion.innerHTML = currentLevel.html;
+            levelQuestion.style.display = 'block';
+        } else if (currentLevel.markdown && currentLevel.markdown.trim()) {
             levelQuestion.textContent = currentLevel.markdown.trim();
             levelQuestion.style.display = 'block';
         } else {
@@ -175,6 +189,8 @@ function updateLevelDisplay() {
         }
     }
     
+    renderLevelParts(currentLevel.parts);
+    
     if (currentLevel.mediaUrl) {
         if (mediaContainer) {
             if (currentLevel.mediaType === 'image') {
@@ -193,14 +209,81 @@ function updateLevelDisplay() {
         feedback.textContent = '';
     }
     
-    if (answerInput) {
-        answerInput.value = '';
-        answerInput.focus();
+    const uploadForm = document.getElementById('uploadForm');
+    if (currentLevel.upload && !window.previewLevel) {
+        if (answerInput) {
+            answerInput.style.display = 'none';
+        }
+        if (uploadForm) {
+            uploadForm.style.display = 'flex';
+            document.getElementById('fileInput').accept = currentLevel.upload.allowedTypes.join(',');
+        }
+        if (feedback && currentLevel.pendingReview) {
+            feedback.textContent = 'Your upload is awaiting review.';
+        }
+    } else {
+        if (uploadForm) {
+            uploadForm.style.display = 'none';
+        }
+        if (answerInput) {
+            answerInput.style.display = '';
+            answerInput.value = '';
+            answerInput.focus();
+        }
     }
     
     updateHintsDisplay();
 }
 
+async function handleFileSubmit() {
+    const fileInput = document.getElementById('fileInput');
+    const feedback = document.getElementById('feedback');
+    const file = fileInput.files[0];
+
+    if (!file || !currentLevel || isSubmitting) return;
+
+    if (currentLevel.upload.maxSize && file.size > currentLevel.upload.maxSize) {
+        feedback.textContent = `File is too large (max ${Math.floor(currentLevel.upload.maxSize / 1024)} KB).`;
+        feedback.style.color = '#dc3545';
+        return;
+    }
+
+    isSubmitting = true;
+    feedback.textContent = 'Uploading...';
+    feedback.style.color = 'var(--primary)';
+
+    const form = new FormData();
+    form.append('levelId', currentLevel.id);
+    form.append('file', file);
+
+    try {
+        const response = await fetch('/api/submit-file', {
+            method: 'POST',
+            headers: { 'CSRFtok': getCookie('X-CSRF_COOKIE') || '' },
+            body: form
+        });
+        const result = await response.json();
+
+        if (result.correct || result.reload_page) {
+            feedback.textContent = result.message || 'Correct! Loading next level...';
+            feedback.style.color = '#28a745';
+            setTimeout(() => {
+                window.location.replace(window.location.pathname + '?v=' + Date.now());
+            }, 800);
+            return;
+        }
+
+        feedback.textContent = result.message || result.error || 'Upload failed. Try again.';
+        feedback.style.color = response.ok ? 'var(--primary)' : '#dc3545';
+    } catch (error) {
+        console.error('Error uploading file:', error);
+        feedback.textContent = 'Error uploading file. Please try again.';
+        feedback.style.color = '#dc3545';
+    }
+    fileInput.value = '';
+    isSubmitting = false;
+}
+
 function handleLevelLoadError(error) {
     const levelTitle = document.getElementById('levelTitle');
     const levelDescription = document.getElementById('levelDescription');
@@ -314,7 +397,10 @@ async function handleSubmit() {
         }
         feedback.style.color = 'var(--primary)';
         
-        const response = await fetch('/api/submit-answer', {
+        const submitUrl = window.previewLevel
+            ? `/api/admin/levels/${window.previewLevel}/dry-run`
+            : '/api/submit-answer';
+        const response = await fetch(submitUrl, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -335,13 +421,35 @@ async function handleSubmit() {
                 }, 1000);
                 return;
             }
+            if (response.status === 429) {
+                const cooldown = await response.json();
+                feedback.textContent = cooldown.message || 'Too many attempts. Please wait before trying again.';
+                feedback.style.color = '#dc3545';
+
+                setTimeout(() => {
+                    const submitButton = document.querySelector('button[onclick="handleSubmit()"]');
+                    if (submitButton) {
+                        submitButton.disabled = false;
+                        submitButton.textContent = 'Submit Answer';
+                    }
+                    feedback.textContent = '';
+                    feedback.style.color = 'var(--primary)';
+                    isSubmitting = false;
+                }, (cooldown.retryAfter || 5) * 1000);
+                return;
+            }
             throw new Error(`Server error: ${response.status}`);
         }
 
         const result = await response.json();
+
+        if (window.previewLevel) {
+            showDryRunResult(result);
+            return;
+        }
         
         if (result.correct) {
-            feedback.textContent = 'Correct! Loading next level...';
+            feedback.textContent = result.parts ? result.message : 'Correct! Loading next level...';
             feedback.style.color = '#28a745';
             
             setTimeout(() => {
@@ -388,6 +496,20 @@ async function handleSubmit() {
     }
 }
 
+function showDryRunResult(result) {
+    const feedback = document.getElementById('feedback');
+    const verdict = result.correct ? 'Correct' : 'Incorrect';
+    feedback.textContent = `Dry run: ${verdict} (rule: ${result.rule}). ${result.message || ''}`;
+    feedback.style.color = result.correct ? '#28a745' : '#dc3545';
+
+    const submitButton = document.querySelector('button[onclick="handleSubmit()"]');
+    if (submitButton) {
+        submitButton.disabled = false;
+        submitButton.textContent = 'Submit Answer';
+    }
+    isSubmitting = false;
+}
+
 async function handleLogout() {
     try {
         await fetch('/api/auth/logout', { 
@@ -480,11 +602,20 @@ async function updateSourceHint() {
             return;
         }
 
-        const response = await fetch(`/api/user/level-hint/${currentLevel.number}`, {
-            headers: {
-                'CSRFtok': getCookie('X-CSRF_COOKIE') || ''
+        let hint = '';
+        if (window.previewLevel) {
+            hint = previewHint;
+        } else {
+            const response = await fetch(`/api/user/level-hint/${currentLevel.number}`, {
+                headers: {
+                    'CSRFtok': getCookie('X-CSRF_COOKIE') || ''
+                }
+            });
+            if (response.ok) {
+                const data = await response.json();
+                hint = data.hint || '';
             }
-        });
+        }
 
         const head = document.head;
         
@@ -498,22 +629,19 @@ async function updateSourceHint() {
             }
         }
 
-        if (response.ok) {
-            const data = await response.json();
-            if (data.hint && data.hint.trim() !== '') {
-                const hintMeta = document.createElement('meta');
-                hintMeta.setAttribute('name', 'level-hint');
-                hintMeta.setAttribute('content', data.hint);
-                hintMeta.setAttribute('data-level', currentLevel.number);
-                head.appendChild(hintMeta);
-                
-                const hintComment = document.createComment(' ' + data.hint + ' ');
-                head.appendChild(hintComment);
-                
-                console.log(`Updated source hint for level ${currentLevel.number}:`, data.hint);
-            } else {
-                console.log(`No source hint for level ${currentLevel.number}`);
-            }
+        if (hint.trim() !== '') {
+            const hintMeta = document.createElement('meta');
+            hintMeta.setAttribute('name', 'level-hint');
+            hintMeta.setAttribute('content', hint);
+            hintMeta.setAttribute('data-level', currentLevel.number);
+            head.appendChild(hintMeta);
+            
+            const hintComment = document.createComment(' ' + hint + ' ');
+            head.appendChild(hintComment);
+            
+            console.log(`Updated source hint for level ${currentLevel.number}:`, hint);
+        } else {
+            console.log(`No source hint for level ${currentLevel.number}`);
         }
     } catch (error) {
         console.error('Failed to update source hint:', error);
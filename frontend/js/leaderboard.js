@@ -5,52 +5,101 @@ function getCookie(name) {
     return null;
 }
 
+const leaderboardState = {
+    query: '',
+    nextCursor: '',
+    loaded: 0
+};
+
+function renderLeaderboardEntry(entry) {
+    const rank = entry.Rank;
+    let rankClass = '';
+
+    if (rank === 1) {
+        rankClass = 'rank-first';
+    } else if (rank === 2) {
+        rankClass = 'rank-second';
+    } else if (rank === 3) {
+        rankClass = 'rank-third';
+    }
+
+    const username = entry.Gmail.split('@')[0];
+
+    return `
+        <div class="leaderboard-entry ${rank <= 3 ? 'top-three' : ''}">
+            <span class="rank ${rankClass}">${rank}</span>
+            <span class="name" title="${username}">${username.length > 12 ? username.substring(0, 12) + '...' : username}${renderBadgeIcons(entry.Badges)}</span>
+            <span class="level">${entry.On || 1}</span>
+        </div>
+    `;
+}
+
+async function fetchLeaderboardPage(cursor, limit) {
+    const params = new URLSearchParams();
+    if (leaderboardState.query) params.set('q', leaderboardState.query);
+    if (cursor) params.set('cursor', cursor);
+    if (limit) params.set('limit', limit);
+
+    const response = await fetch(`/api/leaderboard?${params.toString()}`, {
+        headers: {
+            'CSRFtok': getCookie('X-CSRF_COOKIE') || ''
+        }
+    });
+
+    if (!response.ok) {
+        const errorText = await response.text();
+        throw new Error(`Failed to fetch leaderboard data: ${response.status} ${errorText}`);
+    }
+    return response.json();
+}
+
+function updateLoadMore() {
+    const button = document.getElementById('leaderboardLoadMore');
+    if (button) {
+        button.style.display = leaderboardState.nextCursor ? 'block' : 'none';
+    }
+}
+
+// updateFrozenBanner shows when the standings were frozen. Admins still get
+// live standings, so their banner says what players see instead.
+function updateFrozenBanner(data) {
+    const banner = document.getElementById('leaderboardFrozen');
+    if (!banner) return;
+    if (!data.frozen) {
+        banner.style.display = 'none';
+        return;
+    }
+    const frozenAt = new Date(data.frozenAt).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+    const isAdmin = document.getElementById('adminLink').style.display === 'block';
+    banner.textContent = isAdmin
+        ? `Live standings. Players see the leaderboard frozen at ${frozenAt} until you reveal it.`
+        : `The leaderboard was frozen at ${frozenAt}. Final standings will be revealed after the event.`;
+    banner.style.display = 'block';
+}
+
+// loadLeaderboard redraws the pages loaded so far, so polling keeps any
+// extra pages the player asked for.
 async function loadLeaderboard() {
     try {
-        const response = await fetch('/api/leaderboard', {
-            headers: {
-                'CSRFtok': getCookie('X-CSRF_COOKIE') || ''
-            }
-        });
-        
-        if (!response.ok) {
-            const errorText = await response.text();
-            throw new Error(`Failed to fetch leaderboard data: ${response.status} ${errorText}`);
-        }
-        
-        const data = await response.json();
+        const limit = Math.min(Math.max(leaderboardState.loaded, 50), 200);
+        const data = await fetchLeaderboardPage('', limit);
         const leaderboardData = data.leaderboard || [];
         const listContainer = document.getElementById('leaderboardList');
         
         await checkAdminAccess();
+        updateFrozenBanner(data);
+
+        leaderboardState.nextCursor = data.nextCursor || '';
+        leaderboardState.loaded = leaderboardData.length;
+        updateLoadMore();
         
         if (leaderboardData.length === 0) {
-            listContainer.innerHTML = '<div class="leaderboard-entry" style="text-align: center; padding: 2rem; color: rgba(255, 255, 255, 0.7);">No participants yet</div>';
+            const message = leaderboardState.query ? 'No matching players' : 'No participants yet';
+            listContainer.innerHTML = `<div class="leaderboard-entry" style="text-align: center; padding: 2rem; color: rgba(255, 255, 255, 0.7);">${message}</div>`;
             return Promise.resolve();
         }
         
-        listContainer.innerHTML = leaderboardData.map((entry, index) => {
-            const rank = index + 1;
-            let rankClass = '';
-            
-            if (rank === 1) {
-                rankClass = 'rank-first';
-            } else if (rank === 2) {
-                rankClass = 'rank-second';
-            } else if (rank === 3) {
-                rankClass = 'rank-third';
-            }
-            
-            const username = entry.Gmail.split('@')[0];
-            
-            return `
-                <div class="leaderboard-entry ${rank <= 3 ? 'top-three' : ''}">
-                    <span class="rank ${rankClass}">${rank}</span>
-                    <span class="name" title="${username}">${username.length > 12 ? username.substring(0, 12) + '...' : username}</span>
-                    <span class="level">${entry.On || 1}</span>
-                </div>
-            `;
-        }).join('');
+        listContainer.innerHTML = leaderboardData.map(renderLeaderboardEntry).join('');
     } catch (error) {
         console.error('Failed to load leaderboard:', error);
         document.getElementById('leaderboardList').innerHTML = 
@@ -60,6 +109,112 @@ async function loadLeaderboard() {
     return Promise.resolve();
 }
 
+async function loadMoreLeaderboard() {
+    if (!leaderboardState.nextCursor) return;
+    try {
+        const data = await fetchLeaderboardPage(leaderboardState.nextCursor);
+        const leaderboardData = data.leaderboard || [];
+        document.getElementById('leaderboardList').insertAdjacentHTML('beforeend', leaderboardData.map(renderLeaderboardEntry).join(''));
+        leaderboardState.nextCursor = data.nextCursor || '';
+        leaderboardState.loaded += leaderboardData.length;
+        updateLoadMore();
+    } catch (error) {
+        console.error('Failed to load more of the leaderboard:', error);
+    }
+}
+
+async function loadMyRank() {
+    const container = document.getElementById('leaderboardMe');
+    if (!container) return;
+    try {
+        const response = await fetch('/api/leaderboard/me?radius=0', {
+            headers: {
+                'CSRFtok': getCookie('X-CSRF_COOKIE') || ''
+            }
+        });
+        if (!response.ok) {
+            container.style.display = 'none';
+            return;
+        }
+        const data = await response.json();
+        container.textContent = `You are ranked #${data.rank} of ${data.total}`;
+        container.style.display = 'block';
+    } catch (error) {
+        container.style.display = 'none';
+    }
+}
+
+function setupLeaderboardSearch() {
+    const input = document.getElementById('leaderboardSearch');
+    if (!input) return;
+
+    let timer = null;
+    input.addEventListener('input', () => {
+        clearTimeout(timer);
+        timer = setTimeout(() => {
+            leaderboardState.query = input.value.trim();
+            leaderboardState.loaded = 0;
+            loadLeaderboard();
+        }, 300);
+    });
+
+    const button = document.getElementById('leaderboardLoadMore');
+    if (button) {
+        button.addEventListener('click', loadMoreLeaderboard);
+    }
+}
+
+async function loadFirstSolvers() {
+    try {
+        const response = await fetch('/api/leaderboard/first-solvers', {
+            headers: {
+                'CSRFtok': getCookie('X-CSRF_COOKIE') || ''
+            }
+        });
+        if (!response.ok) return;
+
+        const data = await response.json();
+        const solves = data.firstSolvers || [];
+        const section = document.getElementById('firstSolvers');
+        const list = document.getElementById('firstSolversList');
+        if (!section || !list) return;
+
+        list.innerHTML = '';
+        if (solves.length === 0) {
+            section.style.display = 'none';
+            return;
+        }
+
+        const byLevel = new Map();
+        solves.forEach(solve => {
+            if (!byLevel.has(solve.levelNumber)) byLevel.set(solve.levelNumber, []);
+            byLevel.get(solve.levelNumber).push(solve);
+        });
+
+        byLevel.forEach((levelSolves, level) => {
+            const row = document.createElement('div');
+            row.className = 'first-solvers-row';
+
+            const label = document.createElement('span');
+            label.className = 'first-solvers-level';
+            label.textContent = `Level ${level}`;
+            row.appendChild(label);
+
+            levelSolves.forEach(solve => {
+                const name = document.createElement('span');
+                name.className = 'first-solver' + (solve.position === 1 ? ' first-blood' : '');
+                name.textContent = `${solve.position === 1 ? '🩸 ' : `#${solve.position} `}${solve.userEmail.split('@')[0]}`;
+                name.title = new Date(solve.solvedAt).toLocaleString();
+                row.appendChild(name);
+            });
+            list.appendChild(row);
+        });
+        section.style.display = 'block';
+    } catch (error) {
+        console.error('Failed to load first solvers:', error);
+    }
+}
+
 async function checkNotifications() {
     try {
         const response = await fetch('/api/notifications/unread-count', {
@@ -178,12 +333,17 @@ function forceReflow() {
 
 document.addEventListener('DOMContentLoaded', function() {
     checkAdminAccess();
+    setupLeaderboardSearch();
+    loadMyRank();
     loadLeaderboard().then(() => {
         setTimeout(forceReflow, 100);
         setTimeout(forceReflow, 500);
     });
+    loadFirstSolvers();
     checkNotifications();
     setInterval(loadLeaderboard, 30000);
+    setInterval(loadFirstSolvers, 30000);
+    setInterval(loadMyRank, 30000);
     setInterval(checkNotifications, 30000);
     window.addEventListener('resize', forceReflow);
     window.addEventListener('load', function() {
@@ -0,0 +1,100 @@
+let currentPracticeLevel = null;
+
+async function loadPracticeLevels() {
+    const progress = document.getElementById('practiceProgress');
+    const list = document.getElementById('practiceLevels');
+
+    try {
+        const response = await fetch('/api/practice');
+        const data = await response.json();
+        if (!response.ok) {
+            progress.textContent = data.error || 'Practice mode is not open.';
+            return;
+        }
+
+        progress.textContent = `Solved ${data.solved} of ${data.total} levels in practice`;
+        list.innerHTML = '';
+        data.levels.forEach(level => {
+            const button = document.createElement('button');
+            button.className = 'nav-link' + (level.number === currentPracticeLevel ? ' active' : '');
+            button.textContent = `Level ${level.number}` + (level.solved ? ' ✓' : '');
+            button.onclick = () => openPracticeLevel(level.number);
+            list.appendChild(button);
+        });
+    } catch (error) {
+        console.error('Error loading practice levels:', error);
+        progress.textContent = 'Unable to load practice levels.';
+    }
+}
+
+async function openPracticeLevel(number) {
+    try {
+        const response = await fetch(`/api/practice/levels/${number}`);
+        const level = await response.json();
+        if (!response.ok) {
+            throw new Error(level.error || 'Failed to load level');
+        }
+
+        currentPracticeLevel = number;
+        document.getElementById('practiceLevel').style.display = 'block';
+        document.getElementById('levelTitle').textContent = `Level ${level.number}`;
+        const question = document.getElementById('levelQuestion');
+        if (level.html) {
+            question.style.whiteSpace = 'normal';
+            question.innerHTML = level.html;
+        } else {
+            question.textContent = level.markdown || '';
+        }
+        renderLevelParts(level.parts);
+        document.getElementById('feedback').textContent = '';
+        document.getElementById('answerInput').value = '';
+
+        const media = document.getElementById('levelMedia');
+        media.innerHTML = '';
+        (level.attachments || []).forEach(attachment => {
+            const link = document.createElement('a');
+            link.href = attachment.url;
+            link.textContent = attachment.filename;
+            link.target = '_blank';
+            media.appendChild(link);
+        });
+
+        loadPracticeLevels();
+    } catch (error) {
+        console.error('Error loading practice level:', error);
+    }
+}
+
+async function submitPracticeAnswer() {
+    if (currentPracticeLevel === null) return;
+
+    const input = document.getElementById('answerInput');
+    const feedback = document.getElementById('feedback');
+    const answer = input.value.trim();
+    if (!answer) return;
+
+    try {
+        const response = await fetch(`/api/practice/levels/${currentPracticeLevel}/submit`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'CSRFtok': getCookie('X-CSRF_COOKIE') || ''
+            },
+            body: JSON.stringify({ answer })
+        });
+        const result = await response.json();
+        feedback.textContent = result.message || result.error || '';
+        if (result.correct) {
+            input.value = '';
+            if (result.parts) {
+                renderLevelParts(result.parts);
+            }
+            loadPracticeLevels();
+        }
+    } catch (error) {
+        console.error('Error submitting practice answer:', error);
+        feedback.textContent = 'Failed to submit answer.';
+    }
+}
+
+document.addEventListener('DOMContentLoaded', loadPracticeLevels);
@@ -268,3 +268,41 @@ function parseMarkdown(text) {
     
     return html;
 }
+
+// Lists the parts of a multi-part level and which of them are solved
+function renderLevelParts(parts) {
+    const container = document.getElementById('levelParts');
+    if (!container) return;
+
+    container.innerHTML = '';
+    if (!parts || parts.length === 0) {
+        container.style.display = 'none';
+        return;
+    }
+
+    parts.forEach(part => {
+        const item = document.createElement('span');
+        item.className = 'level-part' + (part.solved ? ' solved' : '') + (part.locked ? ' locked' : '');
+        let label = part.solved ? `✓ ${part.name}` : part.name;
+        if (!part.required) label += ' (optional)';
+        if (part.weight) label += ` · ${part.weight} pts`;
+        item.textContent = label;
+        container.appendChild(item);
+    });
+    container.style.display = 'flex';
+}
+
+function renderBadgeIcons(badges) {
+    if (!badges || badges.length === 0) return '';
+
+    const container = document.createElement('span');
+    container.className = 'badge-icons';
+    badges.forEach(badge => {
+        const icon = document.createElement('span');
+        icon.className = 'badge-icon';
+        icon.textContent = badge.icon || '★';
+        icon.title = badge.description ? `${badge.name}: ${badge.description}` : badge.name;
+        container.appendChild(icon);
+    });
+    return container.outerHTML;
+}
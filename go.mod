@@ -8,4 +8,14 @@ require (
 	golang.org/x/crypto v0.36.0
 )
 
-require github.com/resend/resend-go/v2 v2.20.0
+require (
+	github.com/microcosm-cc/bluemonday v1.0.27
+	github.com/resend/resend-go/v2 v2.20.0
+	github.com/yuin/goldmark v1.7.8
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+)
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type badgeRequest struct {
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	Icon              string          `json:"icon"`
+	Rule              string          `json:"rule"`
+	Params            json.RawMessage `json:"params"`
+	ShowOnLeaderboard bool            `json:"showOnLeaderboard"`
+	Active            *bool           `json:"active"`
+}
+
+// toBadge builds the badge to store. Badges are active unless the request
+// says otherwise.
+func (req badgeRequest) toBadge(id int, createdBy string) database.Badge {
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	return database.Badge{
+		ID:                id,
+		Name:              req.Name,
+		Description:       req.Description,
+		Icon:              req.Icon,
+		Rule:              req.Rule,
+		Params:            req.Params,
+		ShowOnLeaderboard: req.ShowOnLeaderboard,
+		Active:            active,
+		CreatedBy:         createdBy,
+	}
+}
+
+// GetBadgesHandler lists every badge definition along with the rule kinds
+// a badge can use.
+func GetBadgesHandler(w http.ResponseWriter, r *http.Request) {
+	badges, err := database.GetBadges()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve badges"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"badges": badges,
+		"rules":  database.BadgeRuleKinds(),
+		"count":  len(badges),
+	})
+}
+
+func CreateBadgeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData badgeRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	badge, err := database.CreateBadge(requestData.toBadge(0, user.Gmail))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(badge)
+}
+
+// AdminBadgeHandler serves /api/admin/badges/{id} and its holders
+// sub-resource, through which admins award and revoke badges by hand.
+func AdminBadgeHandler(w http.ResponseWriter, r *http.Request, badgePath string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(badgePath, "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid badge ID"})
+		return
+	}
+
+	badge, err := database.GetBadge(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Badge not found"})
+		return
+	}
+
+	if len(parts) >= 2 && parts[1] == "holders" {
+		badgeHolders(w, r, user, badge)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(badge)
+	case http.MethodPut:
+		var requestData badgeRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+			return
+		}
+		updated, err := database.UpdateBadge(requestData.toBadge(id, badge.CreatedBy))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	case http.MethodDelete:
+		if err := database.DeleteBadge(id); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete badge"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Badge deleted successfully"})
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+	}
+}
+
+// badgeHolders lists who holds a badge, awards it on POST and revokes it on
+// DELETE.
+func badgeHolders(w http.ResponseWriter, r *http.Request, admin *database.Login, badge *database.Badge) {
+	if r.Method == http.MethodGet {
+		holders, err := database.GetBadgeHolders(badge.ID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve badge holders"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"holders": holders,
+			"count":   len(holders),
+		})
+		return
+	}
+
+	var requestData struct {
+		Email string `json:"email"`
+		Level int    `json:"level"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	email := strings.ToLower(strings.TrimSpace(requestData.Email))
+	if err != nil || email == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		awarded, err := database.AwardBadge(badge.ID, email, requestData.Level, admin.Gmail)
+		if err == sql.ErrNoRows {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to award badge"})
+			return
+		}
+		if !awarded {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "User already holds this badge"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Badge awarded successfully"})
+	case http.MethodDelete:
+		err := database.RevokeBadge(badge.ID, email)
+		if err == sql.ErrNoRows {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "User does not hold this badge"})
+			return
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to revoke badge"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Badge revoked successfully"})
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+	}
+}
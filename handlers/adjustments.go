@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func CreateAdjustmentHandler(w http.ResponseWriter, r *http.Request, email string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData struct {
+		Kind   string `json:"kind"`
+		Amount int    `json:"amount"`
+		Reason string `json:"reason"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if requestData.Kind == "" {
+		requestData.Kind = database.AdjustmentPoints
+	}
+
+	if strings.TrimSpace(requestData.Reason) == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A reason is required"})
+		return
+	}
+
+	adjustment, err := database.CreateScoreAdjustment(email, requestData.Kind, requestData.Amount, strings.TrimSpace(requestData.Reason), user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create adjustment: " + err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Adjustment recorded successfully",
+		"adjustment": adjustment,
+	})
+}
+
+func GetAdjustmentsHandler(w http.ResponseWriter, r *http.Request, email string) {
+	adjustments, err := database.GetScoreAdjustments(email)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve adjustments"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"adjustments": adjustments,
+		"count":       len(adjustments),
+	})
+}
+
+func RevertAdjustmentHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid adjustment ID"})
+		return
+	}
+
+	var requestData struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&requestData)
+
+	adjustment, err := database.RevertScoreAdjustment(idInt, user.Gmail, strings.TrimSpace(requestData.Reason))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to revert adjustment: " + err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Adjustment reverted successfully",
+		"adjustment": adjustment,
+	})
+}
+
+func GetUserHistoryHandler(w http.ResponseWriter, r *http.Request, email string) {
+	history, err := database.GetPlayerHistory(email)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// GetMyHistoryHandler returns the authenticated player's completions and adjustments
+func GetMyHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	GetUserHistoryHandler(w, r, user.Gmail)
+}
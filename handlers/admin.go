@@ -1,72 +1,16 @@
 package handlers
 
 import (
-	"bytes"
-	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"intrasudo25/config"
 	"intrasudo25/database"
-	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func refreshDiscordChannels() error {
-	botSocketPath := config.GetDiscordBotURL()
-	if botSocketPath == "" {
-		return fmt.Errorf("discord bot URL/socket path not configured")
-	}
-
-	if strings.HasPrefix(botSocketPath, "http") {
-		// Fallback to HTTP if configured that way
-		resp, err := http.Post(
-			fmt.Sprintf("%s/discord/refresh", botSocketPath),
-			"application/json",
-			bytes.NewBuffer([]byte("{}")),
-		)
-		if err != nil {
-			return fmt.Errorf("failed to refresh discord channels: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("discord bot returned status %d", resp.StatusCode)
-		}
-		return nil
-	}
-
-	// Use Unix socket connection
-	if botSocketPath == "" {
-		return fmt.Errorf("bot socket path not configured")
-	}
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return net.Dial("unix", botSocketPath)
-			},
-		},
-	}
-
-	resp, err := client.Post(
-		"http://unix/discord/refresh",
-		"application/json",
-		bytes.NewBuffer([]byte("{}")),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to refresh discord channels via socket: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("discord bot returned status %d", resp.StatusCode)
-	}
-
-	return nil
-}
-
 func CreateLvlHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "application/json")
@@ -125,7 +69,7 @@ func CreateLvlHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Refresh Discord channels after creating a level
-	err = refreshDiscordChannels()
+	err = RefreshDiscordChannels()
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -193,7 +137,7 @@ func UpdateLvlHandler(w http.ResponseWriter, r *http.Request, id string) {
 	}
 
 	// Refresh Discord channels after updating a level
-	err = refreshDiscordChannels()
+	err = RefreshDiscordChannels()
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -238,7 +182,7 @@ func DeleteLvlHandler(w http.ResponseWriter, r *http.Request, id string) {
 	}
 
 	// Refresh Discord channels after deleting a level
-	err = refreshDiscordChannels()
+	err = RefreshDiscordChannels()
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -431,6 +375,67 @@ func ResetUserLevelHandler(w http.ResponseWriter, r *http.Request, email string)
 	json.NewEncoder(w).Encode(map[string]string{"message": "User level reset successfully"})
 }
 
+// PlaceUserLevelHandler moves a user to any level, backfilling or removing
+// completions to match.
+func PlaceUserLevelHandler(w http.ResponseWriter, r *http.Request, email string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData struct {
+		Level  int    `json:"level"`
+		Reason string `json:"reason"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	placement, err := database.PlacePlayer(email, requestData.Level, strings.TrimSpace(requestData.Reason), user.Gmail)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to place user: " + err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "User placed successfully",
+		"placement": placement,
+	})
+}
+
+func GetPlacementsHandler(w http.ResponseWriter, r *http.Request, email string) {
+	placements, err := database.GetLevelPlacements(email)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve placements"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"placements": placements,
+		"count":      len(placements),
+	})
+}
+
 func BanUserEmailHandler(w http.ResponseWriter, r *http.Request, email string) {
 	user, err := GetUserFromSession(r)
 	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
@@ -454,14 +459,70 @@ func BanUserEmailHandler(w http.ResponseWriter, r *http.Request, email string) {
 		return
 	}
 
-	err = database.BanEmail(email, user.Gmail)
+	var requestData struct {
+		Reason    string     `json:"reason"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+			return
+		}
+	}
+
+	err = database.BanEmailUntil(email, user.Gmail, strings.TrimSpace(requestData.Reason), requestData.ExpiresAt)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to ban email"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to ban email: " + err.Error()})
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Email banned successfully"})
 }
+
+func UnbanUserEmailHandler(w http.ResponseWriter, r *http.Request, email string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	err = database.UnbanEmail(email)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Email is not banned"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to unban email"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email unbanned successfully"})
+}
+
+func GetBansHandler(w http.ResponseWriter, r *http.Request) {
+	bans, err := database.GetActiveBans()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve bans"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bans":  bans,
+		"count": len(bans),
+	})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+)
+
+// GetLevelAnalyticsHandler reports solve funnels and timings for one level,
+// or for every level when id is empty.
+func GetLevelAnalyticsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum := 0
+	if id != "" {
+		var err error
+		levelNum, err = strconv.Atoi(id)
+		if err != nil || levelNum <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+			return
+		}
+	}
+
+	analytics, err := database.GetLevelAnalytics(levelNum)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to compute analytics"})
+		return
+	}
+
+	if levelNum != 0 {
+		if len(analytics) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(analytics[0])
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"levels": analytics,
+	})
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"intrasudo25/database"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxAttachmentSize = 50 << 20
+
+var allowedArchiveTypes = map[string]bool{
+	"application/zip":              true,
+	"application/x-gzip":           true,
+	"application/gzip":             true,
+	"application/x-tar":            true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/vnd.rar":          true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+}
+
+func isAllowedAttachmentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "audio/") || allowedArchiveTypes[contentType]
+}
+
+// detectUploadType sniffs an upload's content type, falling back to the
+// file extension when the content alone is not conclusive. Read the upload
+// through the returned reader.
+func detectUploadType(file io.Reader, filename string) (*bufio.Reader, string) {
+	reader := bufio.NewReader(file)
+	head, _ := reader.Peek(512)
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+			contentType = byExt
+		}
+	}
+	return reader, strings.TrimSpace(strings.Split(contentType, ";")[0])
+}
+
+func UploadLevelAttachmentHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+(1<<20))
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A file is required (max 50MB)"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxAttachmentSize {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{"error": "File exceeds the 50MB limit"})
+		return
+	}
+
+	reader, contentType := detectUploadType(file, header.Filename)
+
+	if !isAllowedAttachmentType(contentType) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Only images, audio and archives can be attached"})
+		return
+	}
+
+	attachment, err := database.SaveLevelAttachment(levelNum, filepath.Base(header.Filename), contentType, reader, user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Failed to save attachment: %v", err)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Attachment uploaded successfully",
+		"attachment": attachment,
+	})
+}
+
+func GetLevelAttachmentsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	attachments, err := database.GetLevelAttachments(levelNum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve attachments"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attachments": attachments,
+		"count":       len(attachments),
+	})
+}
+
+func DeleteLevelAttachmentHandler(w http.ResponseWriter, r *http.Request, id, attachmentID string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	attID, err := strconv.Atoi(attachmentID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid attachment ID"})
+		return
+	}
+
+	err = database.DeleteLevelAttachment(levelNum, attID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Attachment deleted successfully"})
+}
+
+// DownloadAttachmentHandler serves a level attachment through a signed,
+// expiring link. Players only get files for levels they have reached.
+func DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	attID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/attachments/"))
+	if err != nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if !database.VerifyAttachmentSignature(attID, user.Gmail, query.Get("exp"), query.Get("sig")) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Link is invalid or has expired"})
+		return
+	}
+
+	attachment, err := database.GetLevelAttachment(attID)
+	if err != nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	practicing := false
+	if database.IsPracticeModeActive() {
+		_, err := database.GetPracticeLevel(attachment.LevelNumber, user.Gmail)
+		practicing = err == nil
+	}
+
+	reached := isAdminEmail(user.Gmail) || practicing
+	if !reached {
+		reached, _ = database.PlayerReachedLevel(user.Gmail, int(user.On), attachment.LevelNumber)
+	}
+	if !reached {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	f, err := os.Open(database.AttachmentBlobPath(attachment))
+	if err != nil {
+		NotFoundHandler(w, r)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, attachment.Filename, time.Time{}, f)
+}
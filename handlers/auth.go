@@ -126,7 +126,7 @@ func sendVerificationEmail(email string, codeToSend string) error {
 	params := &resend.SendEmailRequest{
 		From:    from,
 		To:      []string{email},
-		Subject: "Intra Sudo v6.0 - Verification Code",
+		Subject: config.GetEventName() + " - Verification Code",
 		Html:    otp_str,
 	}
 	_, err := client.Emails.Send(params)
@@ -187,6 +187,12 @@ func LoginF(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isBanned, _ := database.IsEmailBanned(gmail); isBanned {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "This email has been banned from the platform"})
+		return
+	}
+
 	seshT := generateTok(32)
 	csrf := generateTok(32)
 	http.SetCookie(w, &http.Cookie{
@@ -204,6 +210,7 @@ func LoginF(w http.ResponseWriter, r *http.Request) {
 	})
 	database.Update("login_field", map[string]interface{}{"gmail": gmail, "field": "seshTok"}, seshT)
 	database.Update("login_field", map[string]interface{}{"gmail": gmail, "field": "CSRFtok"}, csrf)
+	recordPlayerSession(w, r, gmail)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Successfully logged in"})
@@ -402,6 +409,12 @@ func EmailVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isBanned, _ := database.IsEmailBanned(gmail); isBanned {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "This email has been banned from the platform"})
+		return
+	}
+
 	database.Update("login_field", map[string]interface{}{"gmail": gmail, "field": "verified"}, true)
 
 	leaderboardResult, _ := database.Get("leaderboard", map[string]interface{}{"gmail": gmail})
@@ -426,6 +439,7 @@ func EmailVerify(w http.ResponseWriter, r *http.Request) {
 	})
 	database.Update("login_field", map[string]interface{}{"gmail": gmail, "field": "seshTok"}, seshT)
 	database.Update("login_field", map[string]interface{}{"gmail": gmail, "field": "CSRFtok"}, csrf)
+	recordPlayerSession(w, r, gmail)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Login successful! Welcome to Intra Sudo"})
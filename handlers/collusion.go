@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/config"
+	"intrasudo25/database"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const deviceCookieName = "exun_device"
+
+// recordPlayerSession tags the browser with a long-lived device cookie and
+// logs the device and address the player is using.
+func recordPlayerSession(w http.ResponseWriter, r *http.Request, email string) {
+	if isAdminEmail(email) {
+		return
+	}
+
+	deviceID := ""
+	if cookie, err := r.Cookie(deviceCookieName); err == nil && cookie.Value != "" {
+		deviceID = cookie.Value
+	} else {
+		deviceID = generateTok(24)
+		http.SetCookie(w, &http.Cookie{
+			Name:     deviceCookieName,
+			Value:    deviceID,
+			MaxAge:   60 * 60 * 24 * 365,
+			Path:     "/",
+			HttpOnly: true,
+		})
+	}
+
+	err := database.RecordPlayerSession(email, deviceID, getClientIP(r), r.UserAgent())
+	if err != nil {
+		log.Printf("ERROR: Failed to record session for %s: %v", email, err)
+	}
+}
+
+// getClientIP returns the player's address. Forwarded headers are only
+// believed from a trusted proxy, and then only the entries that proxy and
+// the ones behind it appended; anything further left came from the client.
+func getClientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+
+	proxies := config.GetTrustedProxies()
+	if isTrustedProxy(peer, proxies) {
+		forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(forwarded[i])
+			if hop == "" || net.ParseIP(hop) == nil {
+				break
+			}
+			if !isTrustedProxy(hop, proxies) {
+				return hop
+			}
+		}
+	}
+
+	if net.ParseIP(peer) == nil {
+		return ""
+	}
+	return peer
+}
+
+// isTrustedProxy reports whether addr is one of the configured proxies. A
+// peer on the unix socket has no address, so it matches "unix".
+func isTrustedProxy(addr string, proxies []string) bool {
+	ip := net.ParseIP(addr)
+	for _, proxy := range proxies {
+		if proxy == "unix" {
+			if addr == "" || addr == "@" {
+				return true
+			}
+			continue
+		}
+		if ip == nil {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func GetSuspicionReportHandler(w http.ResponseWriter, r *http.Request) {
+	minScore := database.DefaultSuspicionThreshold
+	if v := r.URL.Query().Get("min"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			minScore = parsed
+		}
+	}
+	includeReviewed := r.URL.Query().Get("all") == "1"
+
+	report, err := database.GetSuspicionReport(minScore, includeReviewed)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to build suspicion report"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flags":     report,
+		"count":     len(report),
+		"threshold": minScore,
+	})
+}
+
+func DismissSuspicionHandler(w http.ResponseWriter, r *http.Request, email string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData struct {
+		Note string `json:"note"`
+	}
+	json.NewDecoder(r.Body).Decode(&requestData)
+
+	flag, ok := loadSuspicionFlag(w, email)
+	if !ok {
+		return
+	}
+
+	err = database.ReviewSuspicion(flag.UserEmail, database.SuspicionReviewDismissed, flag.Score, strings.TrimSpace(requestData.Note), user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to dismiss flag"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Flag dismissed successfully"})
+}
+
+// ActOnSuspicionHandler bans or adjusts a flagged player and marks the flag
+// as handled.
+func ActOnSuspicionHandler(w http.ResponseWriter, r *http.Request, email string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData struct {
+		Action string `json:"action"`
+		Kind   string `json:"kind"`
+		Amount int    `json:"amount"`
+		Reason string `json:"reason"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	reason := strings.TrimSpace(requestData.Reason)
+	if reason == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A reason is required"})
+		return
+	}
+
+	if isAdminEmail(email) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Cannot act on admin email"})
+		return
+	}
+
+	flag, ok := loadSuspicionFlag(w, email)
+	if !ok {
+		return
+	}
+
+	var message string
+	switch requestData.Action {
+	case "ban":
+		err = database.BanEmail(flag.UserEmail, user.Gmail)
+		message = "Player banned successfully"
+	case "adjust":
+		if requestData.Kind == "" {
+			requestData.Kind = database.AdjustmentPoints
+		}
+		_, err = database.CreateScoreAdjustment(flag.UserEmail, requestData.Kind, requestData.Amount, reason, user.Gmail)
+		message = "Adjustment recorded successfully"
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Action must be ban or adjust"})
+		return
+	}
+
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to act on flag: " + err.Error()})
+		return
+	}
+
+	err = database.ReviewSuspicion(flag.UserEmail, database.SuspicionReviewActioned, flag.Score, requestData.Action+": "+reason, user.Gmail)
+	if err != nil {
+		log.Printf("ERROR: Failed to record review for %s: %v", flag.UserEmail, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+func loadSuspicionFlag(w http.ResponseWriter, email string) (*database.SuspicionFlag, bool) {
+	flag, err := database.GetSuspicionFlag(email)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No suspicion flag for this player"})
+		return nil, false
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to build suspicion report"})
+		return nil, false
+	}
+	return flag, true
+}
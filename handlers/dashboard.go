@@ -62,6 +62,16 @@ func SubmitAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordPlayerSession(w, r, login.Gmail)
+
+	if !enforceEligibility(w, login) {
+		return
+	}
+
+	if !enforceSubmissionLimit(w, login, int(login.On)) {
+		return
+	}
+
 	currentLevel := int(login.On)
 
 	userAnswerTrimmed := strings.TrimSpace(userAnswer)
@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 )
 
 type DiscordMessage struct {
@@ -281,14 +282,7 @@ func ChatChecksumHandler(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
-			result, err = database.Get("hint_messages", map[string]interface{}{
-				"level": level,
-			})
-			if err == nil {
-				if hintMsgs, ok := result.([]database.HintMessage); ok {
-					hints = hintMsgs
-				}
-			}
+			hints = getHintsForUser(user.Gmail, level)
 		}
 	}
 
@@ -309,25 +303,61 @@ func calculateHintsHash(userEmail string) string {
 	}
 
 	if level, ok := userLevel.(int); ok {
-		result, err := database.Get("hint_messages", map[string]interface{}{
-			"level": level,
-		})
-		if err != nil {
-			return ""
+		hints := getHintsForUser(userEmail, level)
+		var hintData []string
+		for _, hint := range hints {
+			hintData = append(hintData, fmt.Sprintf("%d:%d:%s", hint.ID, hint.LevelNumber, hint.Message))
 		}
+		sort.Strings(hintData)
+		combined := strings.Join(hintData, "|")
+		hash := md5.Sum([]byte(combined))
+		return hex.EncodeToString(hash[:])
+	}
+	return ""
+}
 
-		if hints, ok := result.([]database.HintMessage); ok {
-			var hintData []string
-			for _, hint := range hints {
-				hintData = append(hintData, fmt.Sprintf("%d:%d:%s", hint.ID, hint.LevelNumber, hint.Message))
-			}
-			sort.Strings(hintData)
-			combined := strings.Join(hintData, "|")
-			hash := md5.Sum([]byte(combined))
-			return hex.EncodeToString(hash[:])
+// getHintsForUser merges hints relayed from Discord with scheduled hints
+// that have been released for this player, oldest first.
+func getHintsForUser(userEmail string, level int) []database.HintMessage {
+	hints := []database.HintMessage{}
+
+	result, err := database.Get("hint_messages", map[string]interface{}{
+		"level": level,
+	})
+	if err == nil {
+		if hintMsgs, ok := result.([]database.HintMessage); ok {
+			hints = append(hints, hintMsgs...)
 		}
 	}
-	return ""
+
+	scheduled, err := database.GetReleasedScheduledHints(userEmail, level)
+	if err == nil && len(scheduled) > 0 {
+		hints = append(hints, scheduled...)
+		sort.SliceStable(hints, func(i, j int) bool {
+			return parseHintTime(hints[i].Timestamp).Before(parseHintTime(hints[j].Timestamp))
+		})
+	}
+
+	return hints
+}
+
+// hintTimeLayouts are the forms hint timestamps come in: RFC3339 from
+// scheduled hints and SQLite's own formats from hint messages.
+var hintTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05",
+}
+
+// parseHintTime reads a hint timestamp, treating one it can not read as
+// the oldest.
+func parseHintTime(value string) time.Time {
+	for _, layout := range hintTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
 }
 
 func calculateLeadsHash(userEmail string) string {
@@ -552,6 +582,50 @@ type DiscordMessageRequest struct {
 	Level     int    `json:"level"`
 }
 
+// botRequestTimeout bounds every request to the Discord bot so a hung bot
+// cannot stall the caller.
+const botRequestTimeout = 10 * time.Second
+
+// botHTTPClient talks to the bot when it is configured with an http URL.
+var botHTTPClient = &http.Client{Timeout: botRequestTimeout}
+
+// botSocketClient talks to the bot over its unix socket.
+var botSocketClient = &http.Client{
+	Timeout: botRequestTimeout,
+	Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", config.GetDiscordBotURL())
+		},
+	},
+}
+
+// postToBot posts a JSON body to the Discord bot, over HTTP when the bot URL
+// is an http address and over the unix socket otherwise. A non-200 reply is
+// returned as an error.
+func postToBot(path string, body []byte) (*http.Response, error) {
+	botSocketPath := config.GetDiscordBotURL()
+	if botSocketPath == "" {
+		return nil, fmt.Errorf("bot socket path not configured")
+	}
+
+	var resp *http.Response
+	var err error
+	if strings.HasPrefix(botSocketPath, "http") {
+		resp, err = botHTTPClient.Post(botSocketPath+path, "application/json", bytes.NewReader(body))
+	} else {
+		resp, err = botSocketClient.Post("http://unix"+path, "application/json", bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("discord bot returned status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
 func forwardToDiscord(userEmail, message string, level int) error {
 	req := DiscordMessageRequest{
 		UserEmail: userEmail,
@@ -565,66 +639,12 @@ func forwardToDiscord(userEmail, message string, level int) error {
 		return fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	botSocketPath := config.GetDiscordBotURL()
-	if strings.HasPrefix(botSocketPath, "http") {
-		// Fallback to HTTP if configured that way
-		fmt.Printf("Forwarding message to Discord at %s/discord/forward\n", botSocketPath)
-		resp, err := http.Post(
-			fmt.Sprintf("%s/discord/forward", botSocketPath),
-			"application/json",
-			bytes.NewBuffer(reqBody),
-		)
-		if err != nil {
-			return fmt.Errorf("failed to send request: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("discord bot returned status %d", resp.StatusCode)
-		}
-
-		var result struct {
-			Success      bool   `json:"success"`
-			Message      string `json:"message"`
-			DiscordMsgID string `json:"discordMsgId"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return fmt.Errorf("failed to decode response: %v", err)
-		}
-
-		if result.Success && result.DiscordMsgID != "" {
-			return updateDiscordMessageID(userEmail, level, result.DiscordMsgID)
-		}
-		return nil
-	}
-	// Use Unix socket connection
-	if botSocketPath == "" {
-		return fmt.Errorf("bot socket path not configured")
-	}
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return net.Dial("unix", botSocketPath)
-			},
-		},
-	}
-
-	resp, err := client.Post(
-		"http://unix/discord/forward",
-		"application/json",
-		bytes.NewBuffer(reqBody),
-	)
+	resp, err := postToBot("/discord/forward", reqBody)
 	if err != nil {
-		return fmt.Errorf("failed to send request via socket: %v", err)
+		return fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("discord bot returned status %d", resp.StatusCode)
-	}
-
 	var result struct {
 		Success      bool   `json:"success"`
 		Message      string `json:"message"`
@@ -719,53 +739,27 @@ func GetLevelsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func RefreshDiscordChannels() error {
-	botSocketPath := config.GetDiscordBotURL()
-
-	if strings.HasPrefix(botSocketPath, "http") {
-		// Fallback to HTTP if configured that way
-		resp, err := http.Post(
-			fmt.Sprintf("%s/discord/refresh", botSocketPath),
-			"application/json",
-			bytes.NewBuffer([]byte("{}")),
-		)
-		if err != nil {
-			return fmt.Errorf("failed to refresh discord channels: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("discord bot returned status %d", resp.StatusCode)
-		}
-		return nil
-	}
-
-	if botSocketPath == "" {
-		return fmt.Errorf("bot socket path not configured")
-	}
-
-	// Use Unix socket connection
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return net.Dial("unix", botSocketPath)
-			},
-		},
+	resp, err := postToBot("/discord/refresh", []byte("{}"))
+	if err != nil {
+		return fmt.Errorf("failed to refresh discord channels: %v", err)
 	}
+	resp.Body.Close()
+	return nil
+}
 
-	resp, err := client.Post(
-		"http://unix/discord/refresh",
-		"application/json",
-		bytes.NewBuffer([]byte("{}")),
-	)
+// relayAnnouncementToDiscord posts an announcement to the bot, which sends
+// it to the Discord announcements channel.
+func relayAnnouncementToDiscord(heading string) error {
+	reqBody, err := json.Marshal(map[string]string{"heading": heading})
 	if err != nil {
-		return fmt.Errorf("failed to refresh discord channels via socket: %v", err)
+		return fmt.Errorf("failed to marshal request: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("discord bot returned status %d", resp.StatusCode)
+	resp, err := postToBot("/discord/announce", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to relay announcement: %v", err)
 	}
-
+	resp.Body.Close()
 	return nil
 }
 
@@ -788,16 +782,8 @@ func GetUserHintsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if level, ok := userLevel.(int); ok {
-		result, err := database.Get("hint_messages", map[string]interface{}{
-			"level": level,
-		})
-		if err != nil {
-			http.Error(w, "Failed to get hint messages", http.StatusInternalServerError)
-			return
-		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(result)
+		json.NewEncoder(w).Encode(getHintsForUser(user.Gmail, level))
 	} else {
 		http.Error(w, "Invalid user level", http.StatusInternalServerError)
 	}
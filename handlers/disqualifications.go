@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// enforceEligibility writes a 403 and returns false when the competition is
+// over or the player has been disqualified with submissions blocked.
+func enforceEligibility(w http.ResponseWriter, user *database.Login) bool {
+	window := database.GetPlayerWindow(user.Gmail)
+	if window.Enforced && time.Now().After(window.End) && !isAdminEmail(user.Gmail) {
+		message := window.Name + " has ended and the leaderboard is final."
+		if window.EventID == 0 && database.IsPracticeModeActive() {
+			message += " You can keep playing in practice mode at /practice."
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(database.SubmitAnswerResult{Correct: false, Message: message})
+		return false
+	}
+
+	return enforceNotDisqualified(w, user)
+}
+
+// enforceNotDisqualified rejects answers from players disqualified with
+// their submissions blocked. It applies to practice answers too.
+func enforceNotDisqualified(w http.ResponseWriter, user *database.Login) bool {
+	dq, err := database.GetDisqualification(user.Gmail)
+	if err != nil || dq == nil || !dq.BlockSubmissions {
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(database.SubmitAnswerResult{
+		Correct: false,
+		Message: "You have been disqualified and can no longer submit answers. Reason: " + dq.Reason,
+	})
+	return false
+}
+
+func DisqualifyUserHandler(w http.ResponseWriter, r *http.Request, email string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	if isAdminEmail(email) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Cannot disqualify admin email"})
+		return
+	}
+
+	var requestData struct {
+		Reason           string     `json:"reason"`
+		BlockSubmissions bool       `json:"blockSubmissions"`
+		ExpiresAt        *time.Time `json:"expiresAt"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	reason := strings.TrimSpace(requestData.Reason)
+	if reason == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A reason is required"})
+		return
+	}
+
+	if _, err := database.Get("login", map[string]interface{}{"gmail": email}); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	err = database.DisqualifyPlayer(email, reason, requestData.BlockSubmissions, user.Gmail, requestData.ExpiresAt)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to disqualify player: " + err.Error()})
+		return
+	}
+
+	database.Create("notification", map[string]interface{}{
+		"userEmail": email,
+		"message":   "You have been disqualified from the rankings. Reason: " + reason,
+		"type":      "warning",
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Player disqualified successfully"})
+}
+
+func RequalifyUserHandler(w http.ResponseWriter, r *http.Request, email string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	err = database.RequalifyPlayer(email)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Player is not disqualified"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to requalify player"})
+		return
+	}
+
+	database.Create("notification", map[string]interface{}{
+		"userEmail": email,
+		"message":   "Your disqualification has been lifted.",
+		"type":      "info",
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Player requalified successfully"})
+}
+
+func GetDisqualificationsHandler(w http.ResponseWriter, r *http.Request) {
+	disqualifications, err := database.GetDisqualifications()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve disqualifications"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"disqualifications": disqualifications,
+		"count":             len(disqualifications),
+	})
+}
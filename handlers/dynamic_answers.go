@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GetAnswerModeHandler shows a level's answer mode and secret. With
+// ?player=email it also shows that player's token and expected answer, for
+// support requests.
+func GetAnswerModeHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	config, err := database.GetDynamicAnswerConfig(levelNum)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve answer mode"})
+		return
+	}
+
+	response := map[string]interface{}{
+		"levelNumber": config.LevelNumber,
+		"mode":        config.Mode,
+		"secret":      config.Secret,
+	}
+	if player := strings.TrimSpace(r.URL.Query().Get("player")); player != "" && config.Mode == database.AnswerModeDynamic {
+		response["player"] = player
+		response["token"] = database.PlayerToken(config.Secret, levelNum, player)
+		response["answer"] = database.DynamicAnswer(config.Secret, levelNum, player)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func SetAnswerModeHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	var requestData struct {
+		Mode         string `json:"mode"`
+		RotateSecret bool   `json:"rotateSecret"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	config, err := database.SetAnswerMode(levelNum, requestData.Mode, requestData.RotateSecret)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Answer mode updated successfully",
+		"level":   config,
+	})
+}
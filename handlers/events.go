@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type eventRequest struct {
+	Slug         string    `json:"slug"`
+	Name         string    `json:"name"`
+	FirstLevel   int       `json:"firstLevel"`
+	LastLevel    int       `json:"lastLevel"`
+	StartsAt     time.Time `json:"startsAt"`
+	EndsAt       time.Time `json:"endsAt"`
+	Registration string    `json:"registration"`
+}
+
+func (req eventRequest) toEvent(id int) *database.Event {
+	return &database.Event{
+		ID:           id,
+		Slug:         req.Slug,
+		Name:         req.Name,
+		FirstLevel:   req.FirstLevel,
+		LastLevel:    req.LastLevel,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+		Registration: req.Registration,
+	}
+}
+
+// GetEventsHandler lists every event along with the caller's enrollment.
+func GetEventsHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	events, err := database.GetEvents()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve events"})
+		return
+	}
+
+	current := ""
+	if event, err := database.GetPlayerEvent(user.Gmail); err == nil && event != nil {
+		current = event.Slug
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":  events,
+		"current": current,
+	})
+}
+
+// EventHandler serves GET /api/events/{slug}, POST /api/events/{slug}/enroll,
+// GET /api/events/{slug}/leaderboard and GET /api/events/{slug}/announcements.
+func EventHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/events/"), "/")
+	event, err := database.GetEventBySlug(parts[0])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Event not found"})
+		return
+	}
+
+	action := ""
+	if len(parts) >= 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(event)
+	case action == "enroll" && r.Method == http.MethodPost:
+		enrollInEvent(w, user, event)
+	case action == "leaderboard" && r.Method == http.MethodGet:
+		writeEventLeaderboard(w, event, isAdminEmail(user.Gmail))
+	case action == "announcements" && r.Method == http.MethodGet:
+		announcements, err := database.GetAnnouncementsForEvent(event.ID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to get announcements"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(announcements)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+	}
+}
+
+func enrollInEvent(w http.ResponseWriter, user *database.Login, event *database.Event) {
+	if event.Registration != database.EventRegistrationOpen {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Registration for " + event.Name + " is not open"})
+		return
+	}
+
+	if time.Now().After(event.EndsAt) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": event.Name + " has already ended"})
+		return
+	}
+
+	err := database.EnrollPlayer(event.ID, user.Gmail, user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to enroll: " + err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Enrolled in " + event.Name})
+}
+
+func writeEventLeaderboard(w http.ResponseWriter, event *database.Event, live bool) {
+	top, err := database.GetStandings(event.ID, live)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error fetching leaderboard: " + err.Error()})
+		return
+	}
+	type Entry struct {
+		Rank  int
+		Gmail string
+		Score string
+		On    uint
+	}
+
+	entries := []Entry{}
+	for _, e := range top {
+		level := e.On
+		if int(level) > event.LastLevel {
+			level = uint(event.LastLevel)
+		}
+		entries = append(entries, Entry{
+			Rank:  e.Rank,
+			Gmail: e.Gmail,
+			Score: strconv.Itoa(e.Score),
+			On:    level,
+		})
+	}
+
+	response := map[string]interface{}{
+		"event":       event.Slug,
+		"leaderboard": entries,
+		"count":       len(entries),
+	}
+	addFreezeState(response)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetAdminEventsHandler(w http.ResponseWriter, r *http.Request) {
+	events, err := database.GetEvents()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve events"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+func CreateEventHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData eventRequest
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	id, err := database.CreateEvent(requestData.toEvent(0))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	event, err := database.GetEvent(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load event"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
+
+// AdminEventHandler serves /api/admin/events/{id} and its enrollment and
+// announcement sub-resources.
+func AdminEventHandler(w http.ResponseWriter, r *http.Request, eventPath string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(eventPath, "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid event ID"})
+		return
+	}
+
+	event, err := database.GetEvent(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Event not found"})
+		return
+	}
+
+	if len(parts) >= 2 && parts[1] == "enrollments" {
+		if len(parts) >= 3 && parts[2] != "" && r.Method == http.MethodDelete {
+			unenrollFromEvent(w, event, parts[2])
+			return
+		}
+		adminEnrollments(w, r, user, event)
+		return
+	}
+
+	if len(parts) >= 2 && parts[1] == "announcements" && r.Method == http.MethodPost {
+		createEventAnnouncement(w, r, event)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(event)
+	case http.MethodPut:
+		var requestData eventRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+			return
+		}
+		if err := database.UpdateEvent(requestData.toEvent(id)); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		event, _ = database.GetEvent(id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(event)
+	case http.MethodDelete:
+		if err := database.DeleteEvent(id); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete event: " + err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Event deleted successfully"})
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+	}
+}
+
+// adminEnrollments lists an event's players or enrolls one directly, which
+// is how invite-only and closed events are populated.
+func adminEnrollments(w http.ResponseWriter, r *http.Request, admin *database.Login, event *database.Event) {
+	if r.Method == http.MethodGet {
+		enrollments, err := database.GetEventEnrollments(event.ID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve enrollments"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enrollments": enrollments,
+			"count":       len(enrollments),
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var requestData struct {
+		Email string `json:"email"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	email := strings.TrimSpace(requestData.Email)
+	if err != nil || email == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Email is required"})
+		return
+	}
+
+	err = database.EnrollPlayer(event.ID, email, admin.Gmail)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to enroll: " + err.Error()})
+		return
+	}
+
+	database.Create("notification", map[string]interface{}{
+		"userEmail": email,
+		"message":   "You have been enrolled in " + event.Name + ".",
+		"type":      "info",
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Player enrolled successfully"})
+}
+
+func unenrollFromEvent(w http.ResponseWriter, event *database.Event, email string) {
+	err := database.UnenrollPlayer(event.ID, email)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Player is not enrolled in this event"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to unenroll player"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Player unenrolled successfully"})
+}
+
+func createEventAnnouncement(w http.ResponseWriter, r *http.Request, event *database.Event) {
+	var requestData struct {
+		Heading string `json:"heading"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil || strings.TrimSpace(requestData.Heading) == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Heading is required"})
+		return
+	}
+
+	if err := database.CreateEventAnnouncement(event.ID, requestData.Heading); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create announcement"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Announcement created successfully"})
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubmitFileHandler takes a player's file upload for the level they are on.
+func SubmitFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, database.MaxUploadSize+(1<<20))
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "A file is required"})
+		return
+	}
+	defer file.Close()
+
+	levelID, err := strconv.Atoi(r.FormValue("levelId"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	recordPlayerSession(w, r, user.Gmail)
+
+	if !enforceEligibility(w, user) {
+		return
+	}
+
+	if !enforceSubmissionLimit(w, user, int(user.On)) {
+		return
+	}
+
+	reader, contentType := detectUploadType(file, header.Filename)
+	result, err := database.SubmitFile(user.Gmail, levelID, filepath.Base(header.Filename), contentType, reader)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to check upload"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func GetUploadConfigHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	config, err := database.GetUploadConfig(levelNum)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"upload": nil})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve upload settings"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"upload": config})
+}
+
+// SetUploadConfigHandler makes a level take file uploads, or turns uploads
+// off again on DELETE.
+func SetUploadConfigHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := database.DeleteUploadConfig(levelNum); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to remove upload settings"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Level no longer takes uploads"})
+		return
+	}
+
+	var config database.UploadConfig
+	err = json.NewDecoder(r.Body).Decode(&config)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+	config.LevelNumber = levelNum
+
+	updated, err := database.SetUploadConfig(config)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Upload settings updated successfully",
+		"upload":  updated,
+	})
+}
+
+// GetFileSubmissionsHandler lists uploads; ?status=pending is the review
+// queue.
+func GetFileSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	submissions, err := database.GetFileSubmissions(strings.TrimSpace(r.URL.Query().Get("status")))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve uploads"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"submissions": submissions,
+		"count":       len(submissions),
+	})
+}
+
+func DownloadFileSubmissionHandler(w http.ResponseWriter, r *http.Request, id string) {
+	subID, err := strconv.Atoi(id)
+	if err != nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	submission, err := database.GetFileSubmission(subID)
+	if err != nil {
+		NotFoundHandler(w, r)
+		return
+	}
+
+	f, err := os.Open(database.FileSubmissionPath(submission))
+	if err != nil {
+		NotFoundHandler(w, r)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", submission.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": submission.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, submission.Filename, time.Time{}, f)
+}
+
+func ReviewFileSubmissionHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	subID, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid submission ID"})
+		return
+	}
+
+	var requestData struct {
+		Accept bool   `json:"accept"`
+		Note   string `json:"note"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	submission, err := database.ReviewFileSubmission(subID, requestData.Accept, strings.TrimSpace(requestData.Note), user.Gmail)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Submission not found"})
+		return
+	}
+	if err == database.ErrAlreadyReviewed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to review submission"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Submission reviewed successfully",
+		"submission": submission,
+	})
+}
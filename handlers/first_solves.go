@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"intrasudo25/database"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const announcementRelayInterval = 15 * time.Second
+
+// StartAnnouncementRelay sends queued announcements to Discord. Anything the
+// bot could not take is retried on the next pass.
+func StartAnnouncementRelay() {
+	go func() {
+		ticker := time.NewTicker(announcementRelayInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			relayPendingAnnouncements()
+		}
+	}()
+}
+
+func relayPendingAnnouncements() {
+	pending, err := database.GetPendingDiscordAnnouncements()
+	if err != nil {
+		log.Printf("ERROR: Failed to load announcements for Discord: %v", err)
+		return
+	}
+
+	for _, a := range pending {
+		if err := relayAnnouncementToDiscord(a.Heading); err != nil {
+			log.Printf("WARNING: Failed to relay announcement %d to Discord: %v", a.ID, err)
+			return
+		}
+		if err := database.MarkAnnouncementRelayed(a.ID); err != nil {
+			log.Printf("ERROR: Failed to mark announcement %d as relayed: %v", a.ID, err)
+		}
+	}
+}
+
+// GetFirstSolversHandler lists the first solvers of every level, or of the
+// level given by ?level=, for the leaderboard page. Solves made while the
+// leaderboard is frozen are only shown to admins.
+func GetFirstSolversHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	levelNum := 0
+	if level := r.URL.Query().Get("level"); level != "" {
+		n, err := strconv.Atoi(level)
+		if err != nil || n <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+			return
+		}
+		levelNum = n
+	}
+
+	solves, err := database.GetFirstSolves(levelNum, isAdminEmail(user.Gmail))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve first solvers"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"firstSolvers": solves,
+		"count":        len(solves),
+	})
+}
+
+// FirstSolveSettingsHandler reads or changes how many solvers per level are
+// announced automatically.
+func FirstSolveSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var requestData struct {
+			Announce int `json:"announce"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+			return
+		}
+		if err := database.SetFirstSolveAnnouncements(requestData.Announce); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"announce":  database.GetFirstSolveAnnouncements(),
+		"positions": database.FirstSolvePositions,
+	})
+}
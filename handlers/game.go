@@ -22,6 +22,8 @@ func GetCurrentLevelHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	deliverLevelHints(w, level)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(level)
 }
@@ -53,6 +55,16 @@ func SubmitAnswerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordPlayerSession(w, r, user.Gmail)
+
+	if !enforceEligibility(w, user) {
+		return
+	}
+
+	if !enforceSubmissionLimit(w, user, int(user.On)) {
+		return
+	}
+
 	result, err := database.CheckAnswer(user.Gmail, request.LevelID, request.Answer)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
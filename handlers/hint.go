@@ -44,3 +44,236 @@ func GetLevelHintHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"hint": hint})
 }
+
+// deliverLevelHints sends the header and cookie hint channels for the level
+// the player is currently on, expiring hint cookies left over from other
+// levels, and returns the channels so page handlers can inject the rest.
+func deliverLevelHints(w http.ResponseWriter, level *database.GameLevel) []database.LevelHintChannel {
+	if level == nil || level.AllCompleted {
+		clearHintCookies(w, 0)
+		return nil
+	}
+
+	channels, err := database.GetLevelHintChannels(level.Number)
+	if err != nil {
+		return nil
+	}
+
+	clearHintCookies(w, level.Number)
+
+	for _, c := range channels {
+		switch c.Channel {
+		case database.HintChannelHeader:
+			if name, value, ok := parseHintHeader(c.Content); ok {
+				w.Header().Set(name, value)
+			}
+		case database.HintChannelCookie:
+			if name, value, ok := parseHintCookie(c.Content); ok {
+				http.SetCookie(w, &http.Cookie{Name: name, Value: value, Path: "/"})
+			}
+		}
+	}
+	return channels
+}
+
+// injectLevelHints delivers every hint channel that lives in the page
+// itself: the HTML comment and the browser console message.
+func injectLevelHints(w http.ResponseWriter, htmlContent []byte, level *database.GameLevel) []byte {
+	channels := deliverLevelHints(w, level)
+
+	var injected strings.Builder
+	for _, c := range channels {
+		switch c.Channel {
+		case database.HintChannelHTMLComment:
+			injected.WriteString("\n<!-- " + strings.ReplaceAll(c.Content, "-->", "--&gt;") + " -->")
+		case database.HintChannelConsole:
+			encoded, err := json.Marshal(c.Content)
+			if err == nil {
+				injected.WriteString("\n<script>console.log(" + string(encoded) + ");</script>")
+			}
+		}
+	}
+
+	if injected.Len() == 0 {
+		return htmlContent
+	}
+	return []byte(strings.Replace(string(htmlContent), "</head>", "</head>"+injected.String(), 1))
+}
+
+func clearHintCookies(w http.ResponseWriter, currentLevel int) {
+	cookies, err := database.GetHintChannelsByType(database.HintChannelCookie)
+	if err != nil {
+		return
+	}
+
+	keep := map[string]bool{}
+	for _, c := range cookies {
+		if c.LevelNumber == currentLevel {
+			if name, _, ok := parseHintCookie(c.Content); ok {
+				keep[name] = true
+			}
+		}
+	}
+
+	for _, c := range cookies {
+		name, _, ok := parseHintCookie(c.Content)
+		if !ok || keep[name] || isReservedCookie(name) {
+			continue
+		}
+		http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1})
+	}
+}
+
+// parseHintHeader splits "Name: value" header hint content.
+func parseHintHeader(content string) (string, string, bool) {
+	name, value, found := strings.Cut(content, ":")
+	name = strings.TrimSpace(name)
+	if !found || !isHeaderToken(name) {
+		return "", "", false
+	}
+	lower := strings.ToLower(name)
+	if lower == "content-type" || lower == "content-length" || lower == "set-cookie" || lower == "location" {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(value), true
+}
+
+// parseHintCookie splits "name=value" cookie hint content.
+func parseHintCookie(content string) (string, string, bool) {
+	name, value, found := strings.Cut(content, "=")
+	name = strings.TrimSpace(name)
+	if !found || !isHeaderToken(name) || isReservedCookie(name) {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(value), true
+}
+
+func isReservedCookie(name string) bool {
+	return name == "exun_sesh_cookie" || name == "X-CSRF_COOKIE"
+}
+
+func isHeaderToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// RobotsHandler serves robots.txt, appending the robots hint for the level
+// the requesting player is currently on.
+func RobotsHandler(w http.ResponseWriter, r *http.Request) {
+	content := "User-agent: *\nDisallow: /admin\nDisallow: /api/\n"
+
+	user, err := GetUserFromSession(r)
+	if err == nil && user != nil {
+		currentLevel, err := database.GetCurrentLevelForUser(user.Gmail)
+		if err == nil && !currentLevel.AllCompleted {
+			hint, err := database.GetLevelHintChannel(currentLevel.Number, database.HintChannelRobots)
+			if err == nil && hint != "" {
+				content += strings.TrimRight(hint, "\n") + "\n"
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(content))
+}
+
+func GetHintChannelsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	channels, err := database.GetLevelHintChannels(levelNum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve hint channels"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channels":  channels,
+		"available": database.HintChannels,
+	})
+}
+
+// SetHintChannelsHandler replaces the hint channels configured for a level
+func SetHintChannelsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	var requestData struct {
+		Channels []database.LevelHintChannel `json:"channels"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	seen := map[string]bool{}
+	for i, c := range requestData.Channels {
+		if !database.IsValidHintChannel(c.Channel) || seen[c.Channel] {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or duplicate hint channel: " + c.Channel})
+			return
+		}
+		seen[c.Channel] = true
+
+		if c.Channel == database.HintChannelHeader {
+			if _, _, ok := parseHintHeader(c.Content); !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Header hints must look like \"X-Name: value\""})
+				return
+			}
+		}
+		if c.Channel == database.HintChannelCookie {
+			if _, _, ok := parseHintCookie(c.Content); !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Cookie hints must look like \"name=value\""})
+				return
+			}
+		}
+		requestData.Channels[i].LevelNumber = levelNum
+	}
+
+	err = database.SetLevelHintChannels(levelNum, requestData.Channels)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update hint channels"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Hint channels updated successfully"})
+}
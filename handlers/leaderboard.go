@@ -3,50 +3,180 @@ package handlers
 import (
 	"encoding/json"
 	"intrasudo25/database"
+	"log"
 	"net/http"
 	"strconv"
 )
 
-func LeaderboardPage(w http.ResponseWriter, r *http.Request) {
-	result, err := database.Get("leaderboard", map[string]interface{}{"limit": 0})
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"error": "Error fetching leaderboard: " + err.Error()})
-		return
-	}
-	top := result.([]database.Sucker)
+const (
+	defaultLeaderboardRadius = 5
+	maxLeaderboardRadius     = 25
+)
 
+type leaderboardEntry struct {
+	Rank   int
+	Gmail  string
+	Score  string
+	On     uint
+	Badges []database.PlayerBadge `json:",omitempty"`
+}
+
+// leaderboardEntries shapes ranked players for display, with the given
+// leaderboard badges.
+func leaderboardEntries(suckers []database.Sucker, badges map[string][]database.PlayerBadge) []leaderboardEntry {
 	var maxLevel int
 	maxLevelResult, err := database.Get("max_level", map[string]interface{}{})
 	if err == nil && maxLevelResult != nil {
 		maxLevel = maxLevelResult.(int)
 	}
 
-	type Entry struct {
-		Gmail string
-		Score string
-		On    uint
-	}
-
-	var entries []Entry
-	for _, e := range top {
+	entries := []leaderboardEntry{}
+	for _, e := range suckers {
 		level := e.On
 		// If user has completed all levels (level > maxLevel)
 		// Display them as being on the max level in the leaderboard
 		if maxLevel > 0 && int(level) > maxLevel {
 			level = uint(maxLevel)
 		}
-		entries = append(entries, Entry{
-			Gmail: e.Gmail,
-			Score: strconv.Itoa(e.Score),
-			On:    level,
+		entries = append(entries, leaderboardEntry{
+			Rank:   e.Rank,
+			Gmail:  e.Gmail,
+			Score:  strconv.Itoa(e.Score),
+			On:     level,
+			Badges: badges[e.Gmail],
 		})
 	}
+	return entries
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+// LeaderboardPage returns one page of the leaderboard. ?limit= sets the
+// page size, ?q= searches player names and ?cursor= continues from the
+// nextCursor of the previous page. Players are ranked by level, then
+// score, then who reached their level first; players tied on all three
+// share a rank. While the leaderboard is frozen players get the standings
+// at the freeze; admins always get the live ones.
+func LeaderboardPage(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	query := database.LeaderboardQuery{
+		Search: r.URL.Query().Get("q"),
+		Cursor: r.URL.Query().Get("cursor"),
+		Live:   isAdminEmail(user.Gmail),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid limit"})
+			return
+		}
+		query.Limit = n
+	}
+
+	page, err := database.GetLeaderboardPage(query)
+	if err != nil {
+		if err == database.ErrInvalidCursor {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid cursor"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error fetching leaderboard: " + err.Error()})
+		return
+	}
+
+	entries := leaderboardEntries(page.Entries, leaderboardBadges(query.Live))
+	response := map[string]interface{}{
 		"leaderboard": entries,
 		"count":       len(entries),
-	})
+		"total":       page.Total,
+		"nextCursor":  page.NextCursor,
+	}
+	addFreezeState(response)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func leaderboardBadges(live bool) map[string][]database.PlayerBadge {
+	badges, err := database.GetLeaderboardBadges(live)
+	if err != nil {
+		log.Printf("ERROR: Failed to load leaderboard badges: %v", err)
+	}
+	return badges
+}
+
+// addFreezeState tells the client whether players are seeing frozen
+// standings, and as of when.
+func addFreezeState(response map[string]interface{}) {
+	freeze, err := database.GetLeaderboardFreeze()
+	if err != nil {
+		log.Printf("ERROR: Failed to load leaderboard freeze: %v", err)
+		return
+	}
+	response["frozen"] = freeze.Frozen
+	if freeze.Frozen {
+		response["frozenAt"] = freeze.FrozenAt
+	}
+}
+
+// LeaderboardMeHandler returns the caller's rank and up to ?radius= players
+// on either side of them.
+func LeaderboardMeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	radius := defaultLeaderboardRadius
+	if value := r.URL.Query().Get("radius"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid radius"})
+			return
+		}
+		if n > maxLeaderboardRadius {
+			n = maxLeaderboardRadius
+		}
+		radius = n
+	}
+
+	live := isAdminEmail(user.Gmail)
+	me, around, total, err := database.GetLeaderboardAround(user.Gmail, 0, radius, live)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error fetching leaderboard: " + err.Error()})
+		return
+	}
+	if me == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "You are not on the leaderboard"})
+		return
+	}
+
+	entries := leaderboardEntries(append([]database.Sucker{*me}, around...), leaderboardBadges(live))
+	response := map[string]interface{}{
+		"rank":   me.Rank,
+		"entry":  entries[0],
+		"around": entries[1:],
+		"total":  total,
+	}
+	addFreezeState(response)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"intrasudo25/database"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	leaderboardCacheInterval      = 30 * time.Second
+	leaderboardCacheCheckInterval = 10 * time.Minute
+)
+
+// StartLeaderboardCache loads the leaderboard into memory, then keeps it
+// in step with lapsing bans and checks it against the database now and
+// then, repairing it if it ever drifts.
+func StartLeaderboardCache() {
+	if err := database.LoadLeaderboardCache(); err != nil {
+		log.Printf("ERROR: Failed to load leaderboard cache, reading from the database until it loads: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(leaderboardCacheInterval)
+		defer ticker.Stop()
+		lastCheck := time.Now()
+
+		for range ticker.C {
+			database.MaintainLeaderboardCache()
+
+			if time.Since(lastCheck) < leaderboardCacheCheckInterval {
+				continue
+			}
+			lastCheck = time.Now()
+			check, err := database.CheckLeaderboardCache(true)
+			if err != nil {
+				log.Printf("ERROR: Failed to check leaderboard cache: %v", err)
+				continue
+			}
+			if check.Repaired {
+				log.Printf("WARNING: Leaderboard cache had drifted and was reloaded: %s", strings.Join(check.Mismatches, "; "))
+			}
+		}
+	}()
+}
+
+// LeaderboardCacheHandler serves GET /api/admin/leaderboard/cache, which
+// compares the cached leaderboard with a full recomputation, and POST,
+// which does the same and reloads the cache if they differ.
+func LeaderboardCacheHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	repair := r.Method == http.MethodPost
+	check, err := database.CheckLeaderboardCache(repair)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to check leaderboard cache: " + err.Error()})
+		return
+	}
+	if check.Repaired {
+		log.Printf("INFO: Admin %s reloaded the leaderboard cache", user.Gmail)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(check)
+}
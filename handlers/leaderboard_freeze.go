@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+const leaderboardFreezeInterval = 5 * time.Second
+
+// StartLeaderboardFreeze takes the freeze snapshot as soon as the scheduled
+// freeze time passes, so it does not wait for the next leaderboard request.
+func StartLeaderboardFreeze() {
+	go func() {
+		ticker := time.NewTicker(leaderboardFreezeInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := database.FreezeLeaderboardIfDue(); err != nil {
+				log.Printf("ERROR: Failed to freeze leaderboard: %v", err)
+			}
+		}
+	}()
+}
+
+// LeaderboardFreezeHandler serves GET, PUT and DELETE
+// /api/admin/leaderboard/freeze and POST /api/admin/leaderboard/reveal.
+func LeaderboardFreezeHandler(w http.ResponseWriter, r *http.Request, action string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var freeze *database.LeaderboardFreeze
+	switch {
+	case action == "freeze" && r.Method == http.MethodGet:
+		freeze, err = database.GetLeaderboardFreeze()
+	case action == "freeze" && r.Method == http.MethodPut:
+		var requestData struct {
+			FreezeAt string `json:"freezeAt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+			return
+		}
+		freezeAt, parseErr := time.Parse(time.RFC3339, requestData.FreezeAt)
+		if parseErr != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "freezeAt must be an RFC3339 timestamp"})
+			return
+		}
+		freeze, err = database.ScheduleLeaderboardFreeze(freezeAt, user.Gmail)
+		if err == nil {
+			log.Printf("INFO: Admin %s scheduled the leaderboard freeze for %s", user.Gmail, freezeAt.UTC().Format(time.RFC3339))
+		}
+	case action == "freeze" && r.Method == http.MethodDelete:
+		err = database.CancelLeaderboardFreeze()
+		if err == nil {
+			log.Printf("INFO: Admin %s cancelled the leaderboard freeze", user.Gmail)
+			freeze, err = database.GetLeaderboardFreeze()
+		}
+	case action == "reveal" && r.Method == http.MethodPost:
+		freeze, err = database.RevealLeaderboard()
+		if err == nil {
+			log.Printf("INFO: Admin %s revealed the leaderboard", user.Gmail)
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to update leaderboard freeze: " + err.Error()
+		switch err {
+		case database.ErrLeaderboardFrozen, database.ErrLeaderboardNotFrozen:
+			status, message = http.StatusConflict, err.Error()
+		case sql.ErrNoRows:
+			status, message = http.StatusNotFound, "No leaderboard freeze is scheduled"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": message})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(freeze)
+}
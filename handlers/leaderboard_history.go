@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"intrasudo25/database"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const leaderboardHistoryInterval = 5 * time.Minute
+
+// StartLeaderboardHistory snapshots the standings while a competition is
+// running so the progress graph does not have to rebuild every sample.
+func StartLeaderboardHistory() {
+	go func() {
+		ticker := time.NewTicker(leaderboardHistoryInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := database.TakeHistorySnapshotIfRunning(); err != nil {
+				log.Printf("ERROR: Failed to snapshot leaderboard history: %v", err)
+			}
+		}
+	}()
+}
+
+// LeaderboardHistoryHandler returns how the top ?top= players progressed
+// between ?from= and ?to= (RFC3339, defaulting to the competition window),
+// sampled every ?step= (a duration such as 15m). Players see nothing past
+// a leaderboard freeze and can not sample more often than snapshots are
+// taken.
+func LeaderboardHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	query := database.HistoryQuery{Live: isAdminEmail(user.Gmail)}
+	if !query.Live {
+		query.MinStep = leaderboardHistoryInterval
+	}
+	params := r.URL.Query()
+	for name, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": name + " must be an RFC3339 timestamp"})
+				return
+			}
+			*target = t
+		}
+	}
+	if value := params.Get("step"); value != "" {
+		step, err := time.ParseDuration(value)
+		if err != nil || step <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "step must be a duration such as 15m"})
+			return
+		}
+		query.Step = step
+	}
+	if value := params.Get("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid top"})
+			return
+		}
+		query.Top = n
+	}
+
+	history, err := database.GetLeaderboardHistory(query)
+	if err != nil {
+		if err == database.ErrInvalidHistoryRange {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error fetching leaderboard history: " + err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// LeaderboardAtHandler returns the top ?limit= players as they stood at
+// ?at= (RFC3339). Players get the latest snapshot taken by then, which can
+// not be past the freeze while the leaderboard is frozen; takenAt says when
+// it was taken.
+func LeaderboardAtHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "at must be an RFC3339 timestamp"})
+		return
+	}
+	limit := database.DefaultLeaderboardLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid limit"})
+			return
+		}
+		limit = min(n, database.MaxLeaderboardLimit)
+	}
+
+	// Players are served from snapshots; only admins may have a moment
+	// rebuilt from the progress tables
+	var standings []database.Sucker
+	var takenAt *time.Time
+	if isAdminEmail(user.Gmail) {
+		standings, err = database.GetStandingsAt(at)
+		takenAt = &at
+	} else {
+		standings, takenAt, err = database.GetSnapshotStandingsAt(at)
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Error fetching leaderboard: " + err.Error()})
+		return
+	}
+	total := len(standings)
+	if len(standings) > limit {
+		standings = standings[:limit]
+	}
+
+	entries := leaderboardEntries(standings, nil)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"at":          at.UTC(),
+		"takenAt":     takenAt,
+		"leaderboard": entries,
+		"count":       len(entries),
+		"total":       total,
+	})
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PreviewLevelHandler shows admins a level as players would receive it,
+// whatever its publication status.
+func PreviewLevelHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	level, err := database.GetLevelPreview(levelNum, user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+		return
+	}
+
+	schedule, err := database.GetLevelSchedule(levelNum)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve level status"})
+		return
+	}
+
+	scheduledHints, err := database.GetScheduledHints(levelNum)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve scheduled hints"})
+		return
+	}
+
+	// GetLevelHint hides inactive levels, so read the channel directly to
+	// show the hint on drafts too
+	channels, _ := database.GetLevelHintChannels(levelNum)
+	hint, _ := database.GetLevelHintChannel(levelNum, database.HintChannelHTMLComment)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"level":          level,
+		"status":         schedule.Status,
+		"publishAt":      schedule.PublishAt,
+		"hint":           hint,
+		"hintChannels":   channels,
+		"scheduledHints": scheduledHints,
+	})
+}
+
+// PreviewLevelPageHandler serves the player page for any level, hint
+// channels included, so admins can check it without touching their own
+// progress. The page fetches the level and checks answers through the admin
+// preview and dry-run endpoints.
+func PreviewLevelPageHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		http.Error(w, "Invalid level ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	level, err := database.GetLevelPreview(levelNum, user.Gmail)
+	if err != nil {
+		http.Error(w, "Level not found", http.StatusNotFound)
+		return
+	}
+
+	htmlContent, err := os.ReadFile("./frontend/index.html")
+	if err != nil {
+		http.Error(w, "Failed to load page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate, max-age=0")
+	htmlContent = injectLevelHints(w, htmlContent, level)
+	previewScript := "<script>window.previewLevel = " + strconv.Itoa(levelNum) + ";</script>"
+	htmlContent = []byte(strings.Replace(string(htmlContent), "</head>", previewScript+"</head>", 1))
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(htmlContent)
+}
+
+// DryRunAnswerHandler reports how CheckAnswer would judge a candidate answer
+// for a level without recording a submission or moving anyone.
+func DryRunAnswerHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	var requestData struct {
+		Answer string `json:"answer"`
+		Player string `json:"player"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	// Dynamic answers are per player; check as the admin unless told otherwise
+	asEmail := strings.TrimSpace(requestData.Player)
+	if asEmail == "" {
+		asEmail = user.Gmail
+	}
+
+	verdict, err := database.DryRunAnswer(levelNum, requestData.Answer, asEmail)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to check answer"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verdict)
+}
+
+// MarkdownPreviewHandler renders level content while an admin is editing
+// it, using the same sanitizer players get.
+func MarkdownPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData struct {
+		Markdown    string `json:"markdown"`
+		LevelNumber int    `json:"levelNumber"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"html": database.RenderMarkdownPreview(requestData.Markdown, requestData.LevelNumber, user.Gmail),
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"intrasudo25/database"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const levelPublishInterval = 30 * time.Second
+
+// StartLevelPublisher publishes scheduled levels as their publish time
+// arrives and refreshes the Discord channels after each wave.
+func StartLevelPublisher() {
+	go func() {
+		publishDueLevels()
+
+		ticker := time.NewTicker(levelPublishInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			publishDueLevels()
+		}
+	}()
+}
+
+func publishDueLevels() {
+	published, err := database.PublishDueLevels()
+	if err != nil {
+		log.Printf("ERROR: Failed to publish scheduled levels: %v", err)
+		return
+	}
+	if len(published) == 0 {
+		return
+	}
+
+	go func() {
+		if err := RefreshDiscordChannels(); err != nil {
+			log.Printf("WARNING: Failed to refresh Discord channels after publishing levels: %v", err)
+		}
+	}()
+}
+
+func SetLevelStatusHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	var requestData struct {
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publishAt"`
+		Announce  bool       `json:"announce"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	err = database.SetLevelStatus(levelNum, requestData.Status, requestData.PublishAt)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if requestData.Status == database.LevelPublished {
+		if requestData.Announce {
+			if err := database.CreateEventAnnouncement(database.EventIDForLevel(levelNum), "Level "+id+" is now live!"); err != nil {
+				log.Printf("ERROR: Failed to announce level %d: %v", levelNum, err)
+			}
+		}
+		go func() {
+			if err := RefreshDiscordChannels(); err != nil {
+				log.Printf("WARNING: Failed to refresh Discord channels after publishing level %d: %v", levelNum, err)
+			}
+		}()
+	}
+
+	schedule, err := database.GetLevelSchedule(levelNum)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve level status"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Level status updated successfully",
+		"level":   schedule,
+	})
+}
@@ -100,11 +100,7 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	srcHint, err := database.GetLevelHint(currentLevel.Number)
-	if err == nil && srcHint != "" {
-		hintComment := "<!-- " + srcHint + " -->"
-		htmlContent = []byte(strings.Replace(string(htmlContent), "</head>", "</head>\n"+hintComment, 1))
-	}
+	htmlContent = injectLevelHints(w, htmlContent, currentLevel)
 
 	w.Header().Set("Content-Type", "text/html")
 	w.Write(htmlContent)
@@ -441,6 +437,7 @@ func GetAllAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
 func CreateAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Heading string `json:"heading"`
+		EventID int    `json:"eventId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -453,7 +450,14 @@ func CreateAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := database.CreateAnnouncement(req.Heading); err != nil {
+	if req.EventID > 0 {
+		if _, err := database.GetEvent(req.EventID); err != nil {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if err := database.CreateEventAnnouncement(req.EventID, req.Heading); err != nil {
 		http.Error(w, "Failed to create announcement", http.StatusInternalServerError)
 		return
 	}
@@ -508,9 +512,11 @@ func DeleteAnnouncementHandler(w http.ResponseWriter, r *http.Request, idStr str
 	json.NewEncoder(w).Encode(map[string]string{"message": "Announcement deleted successfully"})
 }
 
-// Public handler for getting announcements (no auth required)
+// Public handler for getting announcements (no auth required). Signed-in
+// players also see their own event's announcements.
 func GetAnnouncementsForPublicHandler(w http.ResponseWriter, r *http.Request) {
-	announcements, err := database.GetAllAnnouncements()
+	window := playerWindow(GetUserFromSession(r))
+	announcements, err := database.GetAnnouncementsForEvent(window.EventID)
 	if err != nil {
 		http.Error(w, "Failed to get announcements", http.StatusInternalServerError)
 		return
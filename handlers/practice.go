@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func PracticePageHandler(w http.ResponseWriter, r *http.Request) {
+	if !database.IsPracticeModeActive() {
+		http.Redirect(w, r, "/status", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	http.ServeFile(w, r, "./frontend/practice.html")
+}
+
+// requirePracticeMode writes a 403 and returns false unless practice mode is
+// open.
+func requirePracticeMode(w http.ResponseWriter) bool {
+	if database.IsPracticeModeActive() {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Practice mode is not open"})
+	return false
+}
+
+func GetPracticeLevelsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requirePracticeMode(w) {
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	levels, err := database.GetPracticeLevels(user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve practice levels"})
+		return
+	}
+
+	solved := 0
+	for _, level := range levels {
+		if level.Solved {
+			solved++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"levels": levels,
+		"solved": solved,
+		"total":  len(levels),
+	})
+}
+
+// PracticeLevelHandler serves GET /api/practice/levels/{n} and
+// POST /api/practice/levels/{n}/submit.
+func PracticeLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if !requirePracticeMode(w) {
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/practice/levels/"), "/")
+	levelNum, err := strconv.Atoi(parts[0])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	if len(parts) >= 2 && parts[1] == "submit" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+			return
+		}
+		submitPracticeAnswer(w, r, user, levelNum)
+		return
+	}
+
+	level, err := database.GetPracticeLevel(levelNum, user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(level)
+}
+
+func submitPracticeAnswer(w http.ResponseWriter, r *http.Request, user *database.Login, levelNum int) {
+	var request struct {
+		Answer string `json:"answer"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || strings.TrimSpace(request.Answer) == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Answer is required"})
+		return
+	}
+
+	if !enforceNotDisqualified(w, user) {
+		return
+	}
+	if !enforceSubmissionLimit(w, user, levelNum) {
+		return
+	}
+
+	result, err := database.CheckPracticeAnswer(user.Gmail, levelNum, request.Answer)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to check answer"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func GetPracticeModeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{
+		"enabled": database.IsPracticeModeEnabled(),
+		"active":  database.IsPracticeModeActive(),
+	})
+}
+
+func SetPracticeModeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	var requestData struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	err = database.SetPracticeMode(requestData.Enabled)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update practice mode"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{
+		"enabled": database.IsPracticeModeEnabled(),
+		"active":  database.IsPracticeModeActive(),
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// enforceSubmissionLimit reserves an attempt at a level, or writes a 429
+// cooldown response and returns false when the player has to wait before
+// submitting again. Admins are exempt.
+func enforceSubmissionLimit(w http.ResponseWriter, user *database.Login, levelNumber int) bool {
+	if isAdminEmail(user.Gmail) {
+		return true
+	}
+
+	status, err := database.CheckSubmissionRateLimit(user.Gmail, levelNumber)
+	if err != nil {
+		// Never block answers because the limiter itself failed
+		return true
+	}
+	if status.Allowed {
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(status.RetryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(database.SubmitAnswerResult{
+		Correct:    false,
+		Message:    fmt.Sprintf("Too many attempts. Please wait %d seconds before trying again.", status.RetryAfter),
+		Cooldown:   true,
+		RetryAfter: status.RetryAfter,
+		RetryAt:    status.RetryAt.Format(time.RFC3339),
+	})
+	return false
+}
+
+// GetSubmissionLimitHandler returns the effective limit for a level, or the
+// default limit when id is empty.
+func GetSubmissionLimitHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum := 0
+	if id != "" {
+		var err error
+		levelNum, err = strconv.Atoi(id)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+			return
+		}
+	}
+
+	limit := database.GetSubmissionLimit(levelNum)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"limit":      limit,
+		"overridden": levelNum != 0 && limit.LevelNumber == levelNum,
+	})
+}
+
+func SetSubmissionLimitHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum := 0
+	if id != "" {
+		levelNum, err = strconv.Atoi(id)
+		if err != nil || levelNum <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+			return
+		}
+	}
+
+	var limit database.SubmissionLimit
+	err = json.NewDecoder(r.Body).Decode(&limit)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+	limit.LevelNumber = levelNum
+
+	err = database.SetSubmissionLimit(limit)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Submission limit updated successfully"})
+}
+
+func DeleteSubmissionLimitHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum, err := strconv.Atoi(id)
+	if err != nil || levelNum <= 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	err = database.DeleteSubmissionLimit(levelNum)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to remove submission limit"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Level now uses the default submission limit"})
+}
+
+func GetSubmissionLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := database.GetActiveSubmissionLockouts()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve lockouts"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"lockouts": lockouts,
+		"count":    len(lockouts),
+	})
+}
+
+func ClearSubmissionLockoutsHandler(w http.ResponseWriter, r *http.Request, email string) {
+	err := database.ClearSubmissionLockouts(email)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to clear lockouts"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Lockouts cleared successfully"})
+}
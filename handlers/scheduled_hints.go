@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"intrasudo25/database"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func CreateScheduledHintHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	var requestData struct {
+		Message      string `json:"message"`
+		ReleaseAt    string `json:"releaseAt"`
+		DelayMinutes *int   `json:"delayMinutes"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if strings.TrimSpace(requestData.Message) == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Message is required"})
+		return
+	}
+
+	var mode string
+	var releaseAt *time.Time
+	delayMinutes := 0
+
+	if requestData.ReleaseAt != "" && requestData.DelayMinutes != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Specify either releaseAt or delayMinutes, not both"})
+		return
+	} else if requestData.ReleaseAt != "" {
+		t, err := time.Parse(time.RFC3339, requestData.ReleaseAt)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "releaseAt must be an RFC3339 timestamp"})
+			return
+		}
+		mode = database.HintReleaseAt
+		releaseAt = &t
+	} else if requestData.DelayMinutes != nil {
+		mode = database.HintReleaseAfterReach
+		delayMinutes = *requestData.DelayMinutes
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Either releaseAt or delayMinutes is required"})
+		return
+	}
+
+	hint, err := database.CreateScheduledHint(levelNum, strings.TrimSpace(requestData.Message), mode, releaseAt, delayMinutes, user.Gmail)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to schedule hint: " + err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Hint scheduled successfully",
+		"hint":    hint,
+	})
+}
+
+// GetScheduledHintsHandler lists scheduled hints with their release status.
+// An empty id lists hints across every level.
+func GetScheduledHintsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum := 0
+	if id != "" {
+		var err error
+		levelNum, err = strconv.Atoi(id)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+			return
+		}
+	}
+
+	statuses, err := database.GetScheduledHintStatuses(levelNum)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve scheduled hints"})
+		return
+	}
+
+	pending, released := 0, 0
+	for _, s := range statuses {
+		if s.PendingFor > 0 || s.Status == "pending" {
+			pending++
+		}
+		if s.ReleasedFor > 0 || s.Status == "released" {
+			released++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hints":    statuses,
+		"count":    len(statuses),
+		"pending":  pending,
+		"released": released,
+	})
+}
+
+func DeleteScheduledHintHandler(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid hint ID"})
+		return
+	}
+
+	err = database.DeleteScheduledHint(idInt)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Scheduled hint deleted successfully"})
+}
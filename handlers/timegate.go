@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"intrasudo25/config"
+	"intrasudo25/database"
 	"net/http"
 	"strings"
 	"time"
@@ -14,13 +15,14 @@ func TimeGateMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Printf("TimeGate: Path=%s\n", r.URL.Path)
 
-		if !config.IsCountdownEnabled() {
+		user, err := GetUserFromSession(r)
+		window := playerWindow(user, err)
+		if !window.Enforced {
 			fmt.Printf("TimeGate: Countdown disabled\n")
 			next(w, r)
 			return
 		}
 
-		user, err := GetUserFromSession(r)
 		if err == nil && user != nil {
 			adminEmails := config.GetAdminEmails()
 			for _, adminEmail := range adminEmails {
@@ -34,12 +36,17 @@ func TimeGateMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 		location, _ := time.LoadLocation("Asia/Kolkata")
 		now := time.Now().In(location)
-		startTime := config.GetCompetitionStartTime()
-		endTime := config.GetCompetitionEndTime()
+		startTime := window.Start
+		endTime := window.End
 
 		fmt.Printf("TimeGate: Now=%s Start=%s End=%s\n", now, startTime, endTime)
 		fmt.Printf("TimeGate: Before=%t After=%t\n", now.Before(startTime), now.After(endTime))
 
+		if now.After(endTime) && r.URL.Path == "/playground" && window.EventID == 0 && database.IsPracticeModeActive() {
+			http.Redirect(w, r, "/practice", http.StatusSeeOther)
+			return
+		}
+
 		if now.Before(startTime) || now.After(endTime) {
 			fmt.Printf("TimeGate: Redirecting to /status\n")
 			w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate, private")
@@ -55,37 +62,50 @@ func TimeGateMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// playerWindow returns the schedule the requesting player is held to: their
+// event's when enrolled, otherwise the deployment-wide countdown.
+func playerWindow(user *database.Login, err error) database.CompetitionWindow {
+	if err != nil || user == nil {
+		return database.DefaultCompetitionWindow()
+	}
+	return database.GetPlayerWindow(user.Gmail)
+}
+
 type CountdownStatus struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
 	Details string `json:"details"`
+	Event   string `json:"event"`
 }
 
 func CountdownStatusHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if !config.IsCountdownEnabled() {
+	window := playerWindow(GetUserFromSession(r))
+
+	if !window.Enforced {
 		json.NewEncoder(w).Encode(CountdownStatus{
 			Status:  "active",
 			Message: "Competition is active",
-			Details: "Welcome to Intra Sudo v6.0!",
+			Details: "Welcome to " + window.Name + "!",
+			Event:   window.Name,
 		})
 		return
 	}
 
 	location, _ := time.LoadLocation("Asia/Kolkata")
 	now := time.Now().In(location)
-	startTime := config.GetCompetitionStartTime()
-	endTime := config.GetCompetitionEndTime()
+	startTime := window.Start.In(location)
+	endTime := window.End.In(location)
 
 	fmt.Printf("DEBUG CountdownStatus: Now=%s Start=%s End=%s\n", now.Format("2006-01-02 15:04:05"), startTime.Format("2006-01-02 15:04:05"), endTime.Format("2006-01-02 15:04:05"))
 	fmt.Printf("DEBUG CountdownStatus: Before=%t After=%t\n", now.Before(startTime), now.After(endTime))
 
-	var response CountdownStatus
+	response := CountdownStatus{Event: window.Name}
 
 	if now.Before(startTime) {
 		response.Status = "not_started"
-		response.Message = "Intra Sudo v6.0 has not begun yet"
+		response.Message = window.Name + " has not begun yet"
 		day := startTime.Day()
 		var suffix string
 		switch {
@@ -105,12 +125,15 @@ func CountdownStatusHandler(w http.ResponseWriter, r *http.Request) {
 		response.Details = "The competition will start on " + startDateFormatted + " at " + startTimeFormatted + " IST. Please check back then!"
 	} else if now.After(endTime) {
 		response.Status = "ended"
-		response.Message = "Intra Sudo v6.0 is now over"
+		response.Message = window.Name + " is now over"
 		response.Details = "Thank you for participating! Results will be announced shortly."
+		if window.EventID == 0 && database.IsPracticeModeActive() {
+			response.Details += " Practice mode is open: you can keep solving every level at /practice."
+		}
 	} else {
 		response.Status = "active"
 		response.Message = "Competition is active"
-		response.Details = "Welcome to Intra Sudo v6.0!"
+		response.Details = "Welcome to " + window.Name + "!"
 	}
 
 	fmt.Printf("DEBUG CountdownStatus: Final status=%s\n", response.Status)
@@ -124,7 +147,9 @@ type CountdownChecksum struct {
 func CountdownChecksumHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if !config.IsCountdownEnabled() {
+	window := playerWindow(GetUserFromSession(r))
+
+	if !window.Enforced {
 		checksum := fmt.Sprintf("%x", md5.Sum([]byte("active")))
 		json.NewEncoder(w).Encode(CountdownChecksum{Checksum: checksum})
 		return
@@ -132,8 +157,8 @@ func CountdownChecksumHandler(w http.ResponseWriter, r *http.Request) {
 
 	location, _ := time.LoadLocation("Asia/Kolkata")
 	now := time.Now().In(location)
-	startTime := config.GetCompetitionStartTime()
-	endTime := config.GetCompetitionEndTime()
+	startTime := window.Start.In(location)
+	endTime := window.End.In(location)
 
 	var status string
 	if now.Before(startTime) {
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"intrasudo25/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// shadowsAppRoute reports whether the application's router already answers
+// a path, in which case a virtual route there would never be reached.
+func shadowsAppRoute(mux *http.ServeMux, method, path string) bool {
+	if method == database.AnyMethod {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		return false
+	}
+	_, pattern := mux.Handler(req)
+	return pattern != "" && pattern != "/"
+}
+
+// ServeVirtualRoute answers a request that fell through to the catch-all
+// route with a level's virtual route, when the player is on that level. It
+// reports whether it wrote a response. Routed paths such as static assets
+// never fall through, so they cost no lookups.
+func ServeVirtualRoute(w http.ResponseWriter, r *http.Request) bool {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil {
+		return false
+	}
+
+	route, err := database.MatchVirtualRoute(user.Gmail, r.Method, r.URL.Path)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("ERROR: Failed to look up virtual route %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		return false
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	for name, value := range route.Headers {
+		w.Header().Set(name, value)
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(route.Status)
+	if r.Method != http.MethodHead {
+		w.Write([]byte(route.Body))
+	}
+
+	database.RecordProgress(database.ProgressEvent{
+		Kind:        database.ProgressHiddenPage,
+		UserEmail:   user.Gmail,
+		LevelNumber: route.LevelNumber,
+		Path:        route.Path,
+	})
+	return true
+}
+
+func GetVirtualRoutesHandler(w http.ResponseWriter, r *http.Request, id string) {
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	routes, err := database.GetVirtualRoutes(levelNum)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to retrieve routes"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"routes": routes,
+		"count":  len(routes),
+	})
+}
+
+// SaveVirtualRouteHandler creates a route on POST and updates one on PUT.
+// Paths already served by mux are refused.
+func SaveVirtualRouteHandler(w http.ResponseWriter, r *http.Request, mux *http.ServeMux, id, routeID string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err := strconv.Atoi(id)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level ID"})
+		return
+	}
+
+	var route database.VirtualRoute
+	err = json.NewDecoder(r.Body).Decode(&route)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request data"})
+		return
+	}
+	route.LevelNumber = levelNum
+	route.CreatedBy = user.Gmail
+
+	if shadowsAppRoute(mux, strings.ToUpper(strings.TrimSpace(route.Method)), strings.TrimSpace(route.Path)) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "That path is already served by the application"})
+		return
+	}
+
+	var saved *database.VirtualRoute
+	if routeID == "" {
+		saved, err = database.CreateVirtualRoute(route)
+	} else {
+		route.ID, err = strconv.Atoi(routeID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid route ID"})
+			return
+		}
+		saved, err = database.UpdateVirtualRoute(route)
+	}
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Level or route not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("INFO: %s saved virtual route %s %s on level %d", user.Gmail, saved.Method, saved.Path, levelNum)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Route saved successfully",
+		"route":   saved,
+	})
+}
+
+func DeleteVirtualRouteHandler(w http.ResponseWriter, r *http.Request, id, routeID string) {
+	user, err := GetUserFromSession(r)
+	if err != nil || user == nil || !isAdminEmail(user.Gmail) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access denied"})
+		return
+	}
+
+	levelNum, err1 := strconv.Atoi(id)
+	rID, err2 := strconv.Atoi(routeID)
+	if err1 != nil || err2 != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid level or route ID"})
+		return
+	}
+
+	err = database.DeleteVirtualRoute(levelNum, rID)
+	if err == sql.ErrNoRows {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Route not found"})
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete route"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Route deleted successfully"})
+}
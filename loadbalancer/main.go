@@ -925,6 +925,14 @@ func (cp *customProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			req.Header.Add(k, v)
 		}
 	}
+	// Append the peer so the backend can tell it from addresses the client
+	// put in the header itself
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := strings.Join(req.Header.Values("X-Forwarded-For"), ", "); prior != "" {
+			host = prior + ", " + host
+		}
+		req.Header.Set("X-Forwarded-For", host)
+	}
 
 	resp, err := cp.client.Do(req)
 	if err != nil {
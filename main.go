@@ -14,6 +14,7 @@ import (
 	"github.com/joho/godotenv"
 
 	"intrasudo25/database"
+	"intrasudo25/handlers"
 	"intrasudo25/routes"
 )
 
@@ -26,6 +27,11 @@ func main() {
 	flag.Parse()
 
 	database.InitDB()
+	handlers.StartLeaderboardCache()
+	handlers.StartLevelPublisher()
+	handlers.StartAnnouncementRelay()
+	handlers.StartLeaderboardFreeze()
+	handlers.StartLeaderboardHistory()
 
 	handler := routes.RegisterRoutes()
 
@@ -46,10 +46,14 @@ func RegisterRoutes() http.Handler {
 		http.ServeFile(w, r, "./frontend/landing.html")
 	})
 	Mux.HandleFunc("/playground", handlers.TimeGateMiddleware(handlers.RequireAuth(handlers.IndexHandler)))
+	Mux.HandleFunc("/practice", handlers.RequireAuth(handlers.PracticePageHandler))
 	Mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/auth", http.StatusMovedPermanently)
 	})
 	Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if handlers.ServeVirtualRoute(w, r) {
+			return
+		}
 		if r.URL.Path != "/" && r.URL.Path != "/auth" && r.URL.Path != "/home" && r.URL.Path != "/landing" && r.URL.Path != "/playground" && r.URL.Path != "/leaderboard" && r.URL.Path != "/announcements" && r.URL.Path != "/hints" && r.URL.Path != "/guidelines" && r.URL.Path != "/status" && r.URL.Path != "/admin" && !strings.HasPrefix(r.URL.Path, "/admin/") && !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/enter") && !strings.HasPrefix(r.URL.Path, "/static/") && !strings.HasPrefix(r.URL.Path, "/assets/") && !strings.HasPrefix(r.URL.Path, "/css/") && !strings.HasPrefix(r.URL.Path, "/js/") && r.URL.Path != "/styles.css" {
 			http.Redirect(w, r, "/404", http.StatusFound)
 			return
@@ -81,6 +85,8 @@ func RegisterRoutes() http.Handler {
 		http.ServeFile(w, r, "./frontend/status.html")
 	})
 
+	Mux.HandleFunc("/robots.txt", handlers.RobotsHandler)
+
 	Mux.HandleFunc("/404", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./frontend/404.html")
 	})
@@ -99,6 +105,9 @@ func RegisterRoutes() http.Handler {
 		} else if strings.HasSuffix(path, "/delete") {
 			levelID := strings.TrimSuffix(strings.TrimPrefix(path, "/admin/levels/"), "/delete")
 			handlers.DeleteLvlHandler(w, r, levelID)
+		} else if strings.HasSuffix(path, "/preview") {
+			levelID := strings.TrimSuffix(strings.TrimPrefix(path, "/admin/levels/"), "/preview")
+			handlers.PreviewLevelPageHandler(w, r, levelID)
 		} else {
 			handlers.AdminHandler(w, r)
 		}
@@ -156,10 +165,21 @@ func RegisterRoutes() http.Handler {
 	Mux.HandleFunc("/api/user/session", handlers.UserSessionHandler)
 	Mux.HandleFunc("/api/user/current-level", handlers.RequireAuth(handlers.GetCurrentLevelHandler))
 	Mux.HandleFunc("/api/user/level-hint/", handlers.RequireAuth(handlers.GetLevelHintHandler))
+	Mux.HandleFunc("/api/user/history", handlers.RequireAuth(handlers.GetMyHistoryHandler))
+	Mux.HandleFunc("/api/attachments/", handlers.RequireAuth(handlers.DownloadAttachmentHandler))
 
 	Mux.HandleFunc("/api/submit-answer", handlers.RequireAuth(handlers.SubmitAnswerHandler))
+	Mux.HandleFunc("/api/submit-file", handlers.RequireAuth(handlers.SubmitFileHandler))
+	Mux.HandleFunc("/api/practice", handlers.RequireAuth(handlers.GetPracticeLevelsHandler))
+	Mux.HandleFunc("/api/practice/levels/", handlers.RequireAuth(handlers.PracticeLevelHandler))
 	Mux.HandleFunc("/api/notifications/unread-count", handlers.RequireAuth(handlers.GetNotificationCountHandler))
 	Mux.HandleFunc("/api/leaderboard", handlers.RequireAuth(handlers.LeaderboardPage))
+	Mux.HandleFunc("/api/leaderboard/me", handlers.RequireAuth(handlers.LeaderboardMeHandler))
+	Mux.HandleFunc("/api/leaderboard/first-solvers", handlers.RequireAuth(handlers.GetFirstSolversHandler))
+	Mux.HandleFunc("/api/leaderboard/history", handlers.RequireAuth(handlers.LeaderboardHistoryHandler))
+	Mux.HandleFunc("/api/leaderboard/at", handlers.RequireAuth(handlers.LeaderboardAtHandler))
+	Mux.HandleFunc("/api/events", handlers.RequireAuth(handlers.GetEventsHandler))
+	Mux.HandleFunc("/api/events/", handlers.RequireAuth(handlers.EventHandler))
 
 	Mux.HandleFunc("/api/admin/", func(w http.ResponseWriter, r *http.Request) {
 		if !handlers.AdminAuth(w, r, config.GetAdminEmails()) {
@@ -172,6 +192,36 @@ func RegisterRoutes() http.Handler {
 			return
 		}
 
+		if path == "/analytics" && r.Method == "GET" {
+			handlers.GetLevelAnalyticsHandler(w, r, "")
+			return
+		}
+
+		if path == "/markdown/preview" && r.Method == "POST" {
+			handlers.MarkdownPreviewHandler(w, r)
+			return
+		}
+
+		if path == "/first-solves/settings" && (r.Method == "GET" || r.Method == "PUT") {
+			handlers.FirstSolveSettingsHandler(w, r)
+			return
+		}
+
+		if path == "/leaderboard/cache" {
+			handlers.LeaderboardCacheHandler(w, r)
+			return
+		}
+
+		if path == "/leaderboard/freeze" || path == "/leaderboard/reveal" {
+			handlers.LeaderboardFreezeHandler(w, r, strings.TrimPrefix(path, "/leaderboard/"))
+			return
+		}
+
+		if path == "/placements" && r.Method == "GET" {
+			handlers.GetPlacementsHandler(w, r, "")
+			return
+		}
+
 		if path == "/stats" {
 			handlers.GetStatsHandler(w, r)
 			return
@@ -197,6 +247,88 @@ func RegisterRoutes() http.Handler {
 						if r.Method == "PATCH" {
 							handlers.ToggleLevelStateHandler(w, r, id)
 						}
+					} else if len(parts) >= 2 && parts[1] == "status" {
+						if r.Method == "PUT" {
+							handlers.SetLevelStatusHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "analytics" {
+						if r.Method == "GET" {
+							handlers.GetLevelAnalyticsHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "preview" {
+						if r.Method == "GET" {
+							handlers.PreviewLevelHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "answer-mode" {
+						if r.Method == "GET" {
+							handlers.GetAnswerModeHandler(w, r, id)
+						} else if r.Method == "PUT" {
+							handlers.SetAnswerModeHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "parts" {
+						if r.Method == "GET" {
+							handlers.GetLevelPartsHandler(w, r, id)
+						} else if r.Method == "PUT" {
+							handlers.SetLevelPartsHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "upload" {
+						if r.Method == "GET" {
+							handlers.GetUploadConfigHandler(w, r, id)
+						} else if r.Method == "PUT" || r.Method == "DELETE" {
+							handlers.SetUploadConfigHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "validator" {
+						if r.Method == "GET" {
+							handlers.GetLevelValidatorHandler(w, r, id)
+						} else if r.Method == "PUT" {
+							handlers.SetLevelValidatorHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "dry-run" {
+						if r.Method == "POST" {
+							handlers.DryRunAnswerHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "rate-limit" {
+						if r.Method == "GET" {
+							handlers.GetSubmissionLimitHandler(w, r, id)
+						} else if r.Method == "PUT" {
+							handlers.SetSubmissionLimitHandler(w, r, id)
+						} else if r.Method == "DELETE" {
+							handlers.DeleteSubmissionLimitHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "hint-channels" {
+						if r.Method == "GET" {
+							handlers.GetHintChannelsHandler(w, r, id)
+						} else if r.Method == "PUT" {
+							handlers.SetHintChannelsHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "hints" {
+						if r.Method == "GET" {
+							handlers.GetScheduledHintsHandler(w, r, id)
+						} else if r.Method == "POST" {
+							handlers.CreateScheduledHintHandler(w, r, id)
+						}
+					} else if len(parts) >= 2 && parts[1] == "routes" {
+						if len(parts) >= 3 && parts[2] != "" {
+							if r.Method == "PUT" {
+								handlers.SaveVirtualRouteHandler(w, r, Mux, id, parts[2])
+							} else if r.Method == "DELETE" {
+								handlers.DeleteVirtualRouteHandler(w, r, id, parts[2])
+							}
+						} else if r.Method == "GET" {
+							handlers.GetVirtualRoutesHandler(w, r, id)
+						} else if r.Method == "POST" {
+							handlers.SaveVirtualRouteHandler(w, r, Mux, id, "")
+						}
+					} else if len(parts) >= 2 && parts[1] == "attachments" {
+						if len(parts) >= 3 && parts[2] != "" {
+							if r.Method == "DELETE" {
+								handlers.DeleteLevelAttachmentHandler(w, r, id, parts[2])
+							}
+						} else if r.Method == "GET" {
+							handlers.GetLevelAttachmentsHandler(w, r, id)
+						} else if r.Method == "POST" {
+							handlers.UploadLevelAttachmentHandler(w, r, id)
+						}
 					} else {
 						if r.Method == "POST" {
 							handlers.UpdateLvlHandler(w, r, id)
@@ -224,9 +356,39 @@ func RegisterRoutes() http.Handler {
 						if r.Method == "POST" {
 							handlers.ResetUserLevelHandler(w, r, email)
 						}
+					} else if len(parts) >= 2 && parts[1] == "place" {
+						if r.Method == "POST" {
+							handlers.PlaceUserLevelHandler(w, r, email)
+						}
+					} else if len(parts) >= 2 && parts[1] == "placements" {
+						if r.Method == "GET" {
+							handlers.GetPlacementsHandler(w, r, email)
+						}
 					} else if len(parts) >= 2 && parts[1] == "ban" {
 						if r.Method == "POST" {
 							handlers.BanUserEmailHandler(w, r, email)
+						} else if r.Method == "DELETE" {
+							handlers.UnbanUserEmailHandler(w, r, email)
+						}
+					} else if len(parts) >= 2 && parts[1] == "disqualify" {
+						if r.Method == "POST" {
+							handlers.DisqualifyUserHandler(w, r, email)
+						} else if r.Method == "DELETE" {
+							handlers.RequalifyUserHandler(w, r, email)
+						}
+					} else if len(parts) >= 2 && parts[1] == "adjustments" {
+						if r.Method == "GET" {
+							handlers.GetAdjustmentsHandler(w, r, email)
+						} else if r.Method == "POST" {
+							handlers.CreateAdjustmentHandler(w, r, email)
+						}
+					} else if len(parts) >= 2 && parts[1] == "lockouts" {
+						if r.Method == "DELETE" {
+							handlers.ClearSubmissionLockoutsHandler(w, r, email)
+						}
+					} else if len(parts) >= 2 && parts[1] == "history" {
+						if r.Method == "GET" {
+							handlers.GetUserHistoryHandler(w, r, email)
 						}
 					} else if r.Method == "DELETE" {
 						handlers.DeleteUserHandler(w, r, email)
@@ -235,6 +397,121 @@ func RegisterRoutes() http.Handler {
 			}
 		}
 
+		if path == "/practice" {
+			if r.Method == "GET" {
+				handlers.GetPracticeModeHandler(w, r)
+			} else if r.Method == "PUT" {
+				handlers.SetPracticeModeHandler(w, r)
+			}
+			return
+		}
+
+		if path == "/bans" && r.Method == "GET" {
+			handlers.GetBansHandler(w, r)
+			return
+		}
+
+		if path == "/disqualifications" && r.Method == "GET" {
+			handlers.GetDisqualificationsHandler(w, r)
+			return
+		}
+
+		if strings.HasPrefix(path, "/events") {
+			eventPath := strings.TrimPrefix(path, "/events")
+			if eventPath == "" || eventPath == "/" {
+				if r.Method == "GET" {
+					handlers.GetAdminEventsHandler(w, r)
+				} else if r.Method == "POST" {
+					handlers.CreateEventHandler(w, r)
+				}
+			} else {
+				handlers.AdminEventHandler(w, r, eventPath)
+			}
+		}
+
+		if strings.HasPrefix(path, "/badges") {
+			badgePath := strings.TrimPrefix(path, "/badges")
+			if badgePath == "" || badgePath == "/" {
+				if r.Method == "GET" {
+					handlers.GetBadgesHandler(w, r)
+				} else if r.Method == "POST" {
+					handlers.CreateBadgeHandler(w, r)
+				}
+			} else {
+				handlers.AdminBadgeHandler(w, r, badgePath)
+			}
+		}
+
+		if strings.HasPrefix(path, "/file-submissions") {
+			uploadPath := strings.TrimPrefix(path, "/file-submissions")
+			if uploadPath == "" || uploadPath == "/" {
+				if r.Method == "GET" {
+					handlers.GetFileSubmissionsHandler(w, r)
+				}
+			} else {
+				parts := strings.Split(strings.TrimPrefix(uploadPath, "/"), "/")
+				if len(parts) >= 2 && parts[1] == "file" && r.Method == "GET" {
+					handlers.DownloadFileSubmissionHandler(w, r, parts[0])
+				} else if len(parts) >= 2 && parts[1] == "review" && r.Method == "POST" {
+					handlers.ReviewFileSubmissionHandler(w, r, parts[0])
+				}
+			}
+		}
+
+		if strings.HasPrefix(path, "/suspicion") {
+			suspicionPath := strings.TrimPrefix(path, "/suspicion")
+			if suspicionPath == "" || suspicionPath == "/" {
+				if r.Method == "GET" {
+					handlers.GetSuspicionReportHandler(w, r)
+				}
+			} else {
+				parts := strings.Split(strings.TrimPrefix(suspicionPath, "/"), "/")
+				if len(parts) >= 2 && parts[1] == "dismiss" && r.Method == "POST" {
+					handlers.DismissSuspicionHandler(w, r, parts[0])
+				} else if len(parts) >= 2 && parts[1] == "action" && r.Method == "POST" {
+					handlers.ActOnSuspicionHandler(w, r, parts[0])
+				}
+			}
+		}
+
+		if strings.HasPrefix(path, "/rate-limits") {
+			limitPath := strings.TrimPrefix(path, "/rate-limits")
+			if limitPath == "" || limitPath == "/" {
+				if r.Method == "GET" {
+					handlers.GetSubmissionLimitHandler(w, r, "")
+				} else if r.Method == "PUT" {
+					handlers.SetSubmissionLimitHandler(w, r, "")
+				}
+			} else if limitPath == "/lockouts" && r.Method == "GET" {
+				handlers.GetSubmissionLockoutsHandler(w, r)
+			}
+		}
+
+		if strings.HasPrefix(path, "/hints") {
+			hintPath := strings.TrimPrefix(path, "/hints")
+			if hintPath == "" || hintPath == "/" {
+				if r.Method == "GET" {
+					handlers.GetScheduledHintsHandler(w, r, "")
+				}
+			} else if r.Method == "DELETE" {
+				handlers.DeleteScheduledHintHandler(w, r, strings.TrimPrefix(hintPath, "/"))
+			}
+		}
+
+		if strings.HasPrefix(path, "/adjustments") {
+			adjustmentPath := strings.TrimPrefix(path, "/adjustments")
+			if adjustmentPath == "" || adjustmentPath == "/" {
+				if r.Method == "GET" {
+					handlers.GetAdjustmentsHandler(w, r, "")
+				}
+			} else {
+				parts := strings.Split(strings.TrimPrefix(adjustmentPath, "/"), "/")
+				if len(parts) >= 2 && parts[1] == "revert" {
+					handlers.RevertAdjustmentHandler(w, r, parts[0])
+				}
+			}
+		}
+
 		if strings.HasPrefix(path, "/announcements") {
 			announcementPath := strings.TrimPrefix(path, "/announcements")
 			if announcementPath == "" || announcementPath == "/" {